@@ -5,5 +5,6 @@ __author__ = "singletoned"
 __description__ = "Smart linter/formatter with automatic tool detection"
 
 from .cli import main
+from .diagnostics import Diagnostic, add_diagnostics_sink, remove_diagnostics_sink
 
-__all__ = ["main"]
+__all__ = ["main", "Diagnostic", "add_diagnostics_sink", "remove_diagnostics_sink"]
@@ -0,0 +1,137 @@
+"""Fetch and unpack an archive of code to lint or format, such as a vendored release or a
+third party's drop, without unpacking it by hand:
+
+    taidy lint --from-archive vendor/widget-1.2.tar.gz
+    taidy lint --from-archive https://example.com/widget-1.2.zip
+    taidy lint --from-archive s3://bucket/drops/widget-1.2.tgz
+
+The archive is unpacked into a temporary directory, which the run treats as its project,
+so paths in findings and reports are relative to the archive's root. Archives that would
+unpack to more than MAX_UNPACKED_SIZE are refused rather than filling the disk.
+"""
+
+import os
+import shutil
+import subprocess
+import tarfile
+import urllib.error
+import urllib.request
+import zipfile
+from pathlib import Path
+from typing import List, Tuple
+
+# Schemes an archive can be downloaded from
+URL_SCHEMES = ["https://", "http://", "s3://"]
+
+# The most an archive may unpack to, in bytes, so a small archive of highly compressible
+# data can't fill the disk
+MAX_UNPACKED_SIZE = 2 * 1024**3
+
+
+def is_url(source: str) -> bool:
+    """Check whether an archive has to be downloaded first"""
+    return any(source.startswith(scheme) for scheme in URL_SCHEMES)
+
+
+def download(source: str, directory: str, timeout: float = 300) -> str:
+    """Download an archive into a directory, returning its path, and raising ValueError
+    if it can't be fetched. s3:// URLs go through the aws CLI when it's installed, for
+    its credentials, and are otherwise fetched from the bucket's public https URL."""
+    path = os.path.join(directory, os.path.basename(source.rstrip("/")) or "archive")
+    if source.startswith("s3://"):
+        if shutil.which("aws"):
+            result = subprocess.run(
+                ["aws", "s3", "cp", "--only-show-errors", source, path],
+                capture_output=True,
+                text=True,
+            )
+            if result.returncode != 0:
+                raise ValueError(f"Couldn't download {source}: {result.stderr.strip()}")
+            return path
+        bucket, _, key = source[len("s3://") :].partition("/")
+        url = f"https://{bucket}.s3.amazonaws.com/{key}"
+    else:
+        url = source
+
+    try:
+        with urllib.request.urlopen(url, timeout=timeout) as response, open(path, "wb") as f:
+            shutil.copyfileobj(response, f)
+    except urllib.error.HTTPError as e:
+        raise ValueError(f"Couldn't download {source}: {url} answered {e.code}") from None
+    except (urllib.error.URLError, OSError) as e:
+        raise ValueError(f"Couldn't download {source}: {e}") from None
+    return path
+
+
+def safe_name(name: str) -> bool:
+    """Check that a member's name stays inside the directory it's unpacked into"""
+    normalised = os.path.normpath(name.replace("\\", "/"))
+    return not (os.path.isabs(normalised) or normalised == ".." or normalised.startswith("../"))
+
+
+def check_size(archive: str, sizes: List[int], limit: int) -> None:
+    """Raise ValueError if the members to be unpacked add up to more than the limit"""
+    if sum(sizes) > limit:
+        raise ValueError(
+            f"{archive} unpacks to {sum(sizes)} bytes, over the limit of {limit} bytes"
+        )
+
+
+def extract(archive: str, directory: str, limit: int = MAX_UNPACKED_SIZE) -> List[str]:
+    """Unpack a tar (plain or compressed) or zip archive into a directory, returning the
+    names of members that were skipped: links, devices and anything that would land
+    outside the directory. Raises ValueError if it isn't an archive, or if its members
+    add up to more than the limit."""
+    skipped = []
+    try:
+        if tarfile.is_tarfile(archive):
+            with tarfile.open(archive) as tar:
+                members = []
+                for member in tar.getmembers():
+                    if (member.isfile() or member.isdir()) and safe_name(member.name):
+                        members.append(member)
+                    else:
+                        skipped.append(member.name)
+                check_size(archive, [member.size for member in members], limit)
+                # Newer Pythons check members again themselves, and warn unless asked to
+                if hasattr(tarfile, "data_filter"):
+                    tar.extractall(directory, members=members, filter="data")
+                else:
+                    tar.extractall(directory, members=members)
+        elif zipfile.is_zipfile(archive):
+            with zipfile.ZipFile(archive) as zip_file:
+                infos = []
+                for info in zip_file.infolist():
+                    # A zip's Unix mode is in the high bits; links would become plain files
+                    is_link = (info.external_attr >> 16) & 0o170000 == 0o120000
+                    if is_link or not safe_name(info.filename):
+                        skipped.append(info.filename)
+                    else:
+                        infos.append(info)
+                # zipfile stops reading each member at the size its header gives
+                check_size(archive, [info.file_size for info in infos], limit)
+                for info in infos:
+                    zip_file.extract(info, directory)
+        else:
+            raise ValueError(f"{archive} isn't a tar or zip archive")
+    except (tarfile.TarError, zipfile.BadZipFile, EOFError) as e:
+        raise ValueError(f"Couldn't unpack {archive}: {e}") from None
+    return skipped
+
+
+def unpack(source: str, directory: str) -> Tuple[str, List[str]]:
+    """Fetch an archive if it's a URL and unpack it under a directory, returning where it
+    was unpacked and the names of the members that were skipped; raises ValueError if
+    either step fails"""
+    if is_url(source):
+        downloads = os.path.join(directory, ".download")
+        os.mkdir(downloads)
+        archive = download(source, downloads)
+    elif Path(source).is_file():
+        archive = source
+    else:
+        raise ValueError(f"{source} doesn't exist")
+
+    workspace = os.path.join(directory, "archive")
+    os.mkdir(workspace)
+    return workspace, extract(archive, workspace)
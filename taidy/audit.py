@@ -0,0 +1,105 @@
+"""Audit a range of commits, linting the files each one touched."""
+
+import subprocess
+import sys
+import tempfile
+from pathlib import Path
+from typing import List, Optional, Tuple
+
+
+def git(args: List[str], cwd: Optional[Path] = None) -> "subprocess.CompletedProcess[str]":
+    """Run a git command, capturing its output"""
+    return subprocess.run(["git"] + args, cwd=cwd, capture_output=True, text=True)
+
+
+def commits_in_range(rev_range: str) -> List[Tuple[str, str]]:
+    """List (sha, subject) for each commit in a range, oldest first"""
+    result = git(["log", "--reverse", "--format=%H %s", rev_range])
+    if result.returncode != 0:
+        raise ValueError(result.stderr.strip() or f"Invalid revision range: {rev_range}")
+
+    commits = []
+    for line in result.stdout.splitlines():
+        sha, _, subject = line.partition(" ")
+        commits.append((sha, subject))
+    return commits
+
+
+def touched_files(sha: str) -> List[str]:
+    """List the files a commit added, copied, modified or renamed"""
+    result = git(
+        ["diff-tree", "--no-commit-id", "--name-only", "-r", "-z", "--root", "--diff-filter=ACMR"]
+        + [sha]
+    )
+    return [name for name in result.stdout.split("\0") if name]
+
+
+def lint_at(worktree: Path, sha: str, files: List[str]) -> Tuple[int, str]:
+    """Check out a commit in the worktree and lint the given files there.
+
+    A commit that can't be checked out, such as the parent of a root commit, has nothing
+    to lint and passes.
+    """
+    # The whole tree is checked out even if the repository uses a sparse checkout
+    checkout = ["-c", "core.sparseCheckout=false", "checkout", "--detach", "--quiet", sha]
+    if git(checkout, cwd=worktree).returncode != 0:
+        return 0, ""
+
+    present = [f for f in files if (worktree / f).is_file()]
+    if not present:
+        return 0, ""
+
+    result = subprocess.run(
+        [sys.executable, "-m", "taidy", "lint"] + present,
+        cwd=worktree,
+        capture_output=True,
+        text=True,
+    )
+    return result.returncode, result.stdout + result.stderr
+
+
+def audit(args: List[str]) -> int:
+    """Lint each commit in a revision range on its own and report the ones with violations"""
+    if len(args) != 1:
+        print("Usage: taidy audit <rev-range>, e.g. taidy audit origin/main..HEAD")
+        return 1
+
+    try:
+        commits = commits_in_range(args[0])
+    except ValueError as e:
+        print(f"Error: {e}", file=sys.stderr)
+        return 1
+
+    if not commits:
+        print(f"No commits in {args[0]}")
+        return 0
+
+    failing = 0
+    with tempfile.TemporaryDirectory(prefix="taidy-audit-") as temp_dir:
+        worktree = Path(temp_dir) / "worktree"
+        # Linked worktrees can add further worktrees, which share the same common dir
+        added = git(["worktree", "add", "--detach", "--no-checkout", "--quiet", str(worktree)])
+        if added.returncode != 0:
+            print(f"Error: could not create a worktree: {added.stderr.strip()}", file=sys.stderr)
+            return 1
+
+        try:
+            for sha, subject in commits:
+                files = touched_files(sha)
+                exit_code, output = lint_at(worktree, sha, files)
+                if exit_code == 0:
+                    print(f"✓ {sha[:10]} {subject}")
+                    continue
+
+                failing += 1
+                # Violations the parent already had weren't introduced by this commit
+                parent_code, _ = lint_at(worktree, f"{sha}^", files)
+                note = " (already failing before this commit)" if parent_code != 0 else ""
+                print(f"✗ {sha[:10]} {subject}{note}")
+                for line in output.rstrip().splitlines():
+                    print(f"    {line}")
+        finally:
+            git(["worktree", "remove", "--force", str(worktree)])
+
+    print(f"{failing} of {len(commits)} commits have lint violations in the files they touch")
+    return 1 if failing else 0
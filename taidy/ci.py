@@ -0,0 +1,47 @@
+"""Detect CI services, and fold tool output into collapsible sections of their logs."""
+
+import itertools
+import os
+import time
+from typing import Optional, Tuple
+
+# Environment for tools under CI: no colour codes in logs, and no prompts, such as npx
+# asking before it installs a package
+CI_TOOL_ENVIRONMENT = {"NO_COLOR": "1", "FORCE_COLOR": "0", "npm_config_yes": "true"}
+
+# Where the JSON report goes under CI, for later steps to pick up, unless --report is given
+CI_REPORT_PATH = ".taidy/report.json"
+
+# GitLab section names must be unique within a job
+_section_ids = itertools.count(1)
+
+
+def detect_ci() -> Optional[str]:
+    """Name the CI service taidy is running under, if it is one taidy can fold logs for"""
+    if os.environ.get("GITHUB_ACTIONS") == "true":
+        return "github"
+    if os.environ.get("TF_BUILD"):
+        return "azure"
+    if os.environ.get("GITLAB_CI"):
+        return "gitlab"
+    return None
+
+
+def section_markers(provider: str, title: str, started: float) -> Tuple[str, str]:
+    """Get the lines that open and close a collapsible log section titled title"""
+    if provider == "github":
+        return f"::group::{title}", "::endgroup::"
+    if provider == "azure":
+        return f"##[group]{title}", "##[endgroup]"
+
+    # GitLab sections are timed by the timestamps in their markers
+    section = f"taidy_{next(_section_ids)}"
+    return (
+        f"\x1b[0Ksection_start:{int(started)}:{section}[collapsed=true]\r\x1b[0K{title}",
+        f"\x1b[0Ksection_end:{int(time.time())}:{section}\r\x1b[0K",
+    )
+
+
+def running_in_ci() -> bool:
+    """Check for the CI variable most services set, or one of the services detect_ci knows"""
+    return os.environ.get("CI", "").lower() not in ["", "0", "false"] or detect_ci() is not None
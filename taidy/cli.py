@@ -1,60 +1,312 @@
 #!/usr/bin/env python3
 """Taidy CLI - Smart linter/formatter with automatic tool detection."""
 
+import contextlib
+import copy
+import difflib
 import fnmatch
+import glob
+import hashlib
+import importlib
+import io
+import itertools
 import json
 import logging
 import os
+import re
+import shlex
 import shutil
+import signal
 import subprocess
 import sys
+import tempfile
 import threading
+import time
+from collections import Counter
 from concurrent.futures import ThreadPoolExecutor, as_completed
-from dataclasses import dataclass
+from dataclasses import dataclass, field, replace
+from datetime import datetime
 from enum import Enum
 from pathlib import Path
-from typing import Any, Callable, Dict, List, Optional, Set, Tuple
+from typing import IO, Any, Callable, Dict, Iterable, Iterator, List, Optional, Set, Tuple
+
+from .archives import unpack
+from .audit import audit
+from .ci import CI_REPORT_PATH, CI_TOOL_ENVIRONMENT, detect_ci, running_in_ci, section_markers
+from .compare import compare
+from .conditions import ConditionContext, current_platform, evaluate_condition
+from .customtools import CustomTool, parse_custom_tools
+from .diagnostics import (
+    STRUCTURED_OUTPUT_ARGS,
+    Diagnostic,
+    add_diagnostics_sink,
+    diagnostics_sinks,
+    format_context,
+    format_diagnostic,
+    load_json,
+    parse_diagnostics,
+    printable_path,
+    remove_diagnostics_sink,
+)
+from .daemon import DEFAULT_SOCKET_PATH, connect, serve_socket
+from .explain import MAX_DIAGNOSTICS, build_prompt, explain_settings, request_explanation
+from .history import record_run, run_summary, trends
+from .hooks import GENERATE_USAGE, generate_hooks, install_hooks
+from .ignores import IgnoreRule, is_ignored, load_ignore_file, sync_ignores
+from .images import IMAGES, INSTALLS, dockerfile
+from .importers import (
+    detect_project_tools,
+    import_lint_staged,
+    import_pre_commit,
+    scaffold_config,
+    to_toml,
+    tool_config_files,
+)
+from .licenses import check_license_headers
+from .lsp import serve_lsp
+from .messages import message
+from .outcomes import (
+    BUILTIN_CRITERIA,
+    Outcome,
+    SuccessCriteria,
+    classify,
+    outcome_exit_code,
+    parse_criteria,
+    threshold_violations,
+)
+from .owners import UNOWNED, load_codeowners, owners_of
+from .plain import NO_COLOUR_ENVIRONMENT, to_plain_verbose
+from .presets import PRESETS, preset_config
+from .prompts import InteractivePrompt, run_unattended, stop_running_tools
+from .protocol import INVALID_PARAMS, METHODS, PROTOCOL_VERSION, ProtocolError, request_files
+from .report import Report, ToolRun
+from .rules import explain_rule
+from .junit import to_junit
+from .runlock import build_lock, lock_differences, read_lock, write_lock
+from .sarif import to_sarif
+from .templates import TARGETS, TEMPLATES, USAGE as INIT_USAGE, Project
+from .toolcache import ToolCache, tool_cache_path
+from .vcs import detect_vcs
 
 # Version information - can be overridden at build time
 VERSION = "0.1.0"
 GIT_COMMIT = "unknown"
 BUILD_DATE = "unknown"
 
+# Exit code when --error-on-empty is given and no supported files were found
+EXIT_NOTHING_TO_DO = 3
+
+# Files that may hold credentials, which are never handed to linters or formatters
+SENSITIVE_PATTERNS = [
+    ".env",
+    ".env.*",
+    "*.env",
+    "*.pem",
+    "*.key",
+    "*.p12",
+    "*.pfx",
+    "id_rsa",
+    "id_dsa",
+    "id_ecdsa",
+    "id_ed25519",
+    ".netrc",
+    ".pgpass",
+    "credentials.json",
+]
+
+# Minified bundles and source maps: build output that's never worth linting or formatting
+MINIFIED_PATTERNS = ["*.min.js", "*.min.mjs", "*.min.css", "*.map"]
+
+# Dependency manifests, linted by their own chains in addition to their file type's
+MANIFEST_FILES = ["go.mod", "package.json", "pyproject.toml", "Cargo.toml"]
+
+# Longest path Windows opens without the \\?\ long path prefix
+WINDOWS_MAX_PATH = 260
+
+# Files per tool run when splitting large batches, so a checkpoint is written as each finishes
+CHECKPOINT_CHUNK_SIZE = 200
+
 # Help text constants
 USAGE_TEXT = """
 Usage: taidy [command] <files_or_directories...>
 
 Commands:
-  lint     Lint files only (no formatting)
-  format   Format files only (no linting)
-  suggest  Analyze project and suggest tools to install
-  docker   Run taidy in Docker with all tools pre-installed
-  (none)   Both lint and format (default)
+  lint          Lint files only (no formatting)
+  format        Format files only (no linting)
+  imports       Organize imports only (ruff/isort, goimports, eslint import/order)
+  fix           Apply linters' safe automatic fixes (ruff, eslint, stylelint, rubocop)
+  spell         Spell-check files with typos, codespell or cspell
+  license       Check (--check) or insert (--fix) license headers from the config template
+  audit         Lint each commit in a revision range and report the ones with violations
+  trends        Show whether lint findings are rising or falling over recent runs
+  compare       Show the findings new in, fixed since and persisting between two JSON
+                reports (--json for a machine-readable listing); fails on new ones
+  suggest       Analyze project and suggest tools to install
+  which         Show the command lines that would lint and format a file, and why other
+                tools in its chains were passed over, without running anything
+  tools         List each file type's tool chain, what's available and what would run
+                (--json for a machine-readable listing)
+  doctor        Check which tools are found (and their versions), which config is read,
+                and which file types have no available tool
+  explain       Ask a configured language model to explain findings and suggest patches
+  explain-rule  Show which tool owns a rule code and link its documentation
+  sync-ignores  Write the config's ignore list into .prettierignore, ruff and eslint config
+  config        Manage configuration (`config import` scaffolds it from existing setups)
+  import        Convert another tool's setup to a .taidy.toml (pre-commit or lint-staged)
+  init          Write a CI workflow or hook config running taidy on this project's
+                languages (`init --template github-actions|gitlab|pre-commit`)
+  export        Export the active tool chains (`export pre-commit` for .pre-commit-config.yaml)
+  hook          Lint and format the files staged for commit, for use as a pre-commit hook
+  install-hooks Install `taidy --staged` as the git pre-commit hook (uninstall, --force)
+  generate      Print config wiring taidy into a hook manager (`generate hooks --manager=`)
+  daemon        Stay resident, answering lint and format requests on a Unix socket
+  client        Send a request to a running daemon and print its result as JSON
+  lsp           Serve editors over the Language Server Protocol on stdin and stdout
+  docker        Run taidy in Docker with all tools pre-installed
+  bundle-image  Generate the Dockerfiles of the taidy/full, taidy/python and taidy/node
+                images from the tool chains (--write DIR to write them all)
+  (none)        Both lint and format (default)
+
+  A command other than lint, format, suggest or docker whose name is also a file or
+  directory here, such as tools/, is processed as that path; run the command from
+  another directory to use it.
 
 Examples:
   taidy file.py               # Lint and format a single file
   taidy .                     # Process all supported files in current directory
+  taidy                       # Inside a git repository, same as `taidy .`
   taidy src/                  # Process all supported files in src/ directory
   taidy lint file1.py file2.js  # Lint multiple files
+  taidy imports src/          # Sort and tidy imports without reformatting
+  taidy fix src/              # Apply lint fixes such as ruff --fix, without reformatting
   taidy suggest               # Analyze project and suggest missing tools
+  taidy explain-rule E501     # Show documentation for a lint rule (--open to browse)
+  taidy explain src/app.py    # Explain a file's findings, once "explain" is configured
+  taidy sync-ignores          # Sync ignore patterns to other tools (--check for CI)
+  taidy audit main..release   # Find the commits on release that introduced lint violations
+  taidy trends --last 20      # Compare lint findings across the last 20 runs
+  taidy compare main.json pr.json  # Fail a pull request only on findings it introduced
+  taidy hook                  # As a pre-commit hook: process staged files only
+  taidy install-hooks         # Make taidy the git pre-commit hook for this repository
+  taidy generate hooks --manager=lefthook  # Run taidy from husky, lefthook or pre-commit
+  taidy daemon                # Serve editors and hooks from one resident process
+  taidy lint --since origin/main  # In CI: lint only the files a pull request changed
+  cat foo.py | taidy lint --stdin --stdin-filename foo.py  # Lint an unsaved buffer
   taidy docker .              # Run taidy in Docker container with all tools
 
 Flags:
-  -h, --help     Show this help message
-  -v, --version  Show version information"""
+  -h, --help        Show this help message
+  -v, --version     Show version information
+  --prefer-fast     Prefer the fastest of equally capable tools, based on past runs,
+                    first trying each installed one that hasn't been timed
+  --show-context    Show findings with their source lines, uniformly for all tools
+  --lang LANGS      Only process the named languages, e.g. --lang python,go
+  --preset NAME     Start from a built-in preset: minimal, standard, strict, frontend or
+                    python-ds (see --help); the project's config still takes precedence
+  --exit-zero       Exit with status 0 even when tools report findings, though not when
+                    a tool fails to run
+  --strict          Fail on any finding, even from tools that exit successfully, such as
+                    eslint warnings (default under CI; --no-strict turns it off)
+  --ci, --no-ci     Use, or don't use, the CI defaults: --strict, a JSON report in
+                    .taidy/report.json, and tools run without colour or prompts.
+                    They apply when $CI is set or GitHub, GitLab or Azure is detected
+  -j, --jobs N      Run up to N tools at once (default: one per CPU; 1 runs them in turn).
+                    KEY=N limits the tools for one file type or language, alone or
+                    alongside the total, e.g. -j 8,.ts=2,rust=1
+  --login-shell     Look up tools missing from PATH through your login shell ($SHELL -lc)
+  --error-on-empty  Exit with status 3 when no supported files are found
+  --quiet-success   Print nothing for tools that found no issues, just a summary line
+  --timings         Show how long each command took, and each language in total
+  --show-env        Show where each tool was found and its version, the directory it
+                    runs in, and the environment variables taidy changed for it
+  --stdout          With format and a single file, print the formatted result instead
+                    of rewriting the file, for pipelines and editors
+  --stdin           With lint or format, process content from stdin, such as an editor's
+                    unsaved buffer; formatting prints the result on stdout
+  --stdin-filename NAME
+                    The file the --stdin content belongs to, which picks the tools and
+                    config to use; it needn't exist
+  --resume          Continue an interrupted run, skipping files it already found clean.
+                    A run stopped by SIGTERM, as on a CI timeout, still prints its
+                    findings and summary so far, then exits with status 130
+  --allow-sensitive Pass secrets files such as .env and *.pem to tools like any other file
+  --scan-sensitive  Send secrets files to the secrets scanner (trufflehog) instead
+  --recurse-submodules
+                    Also process git submodules, each with its own config
+  --shard K/N       Only process the Kth of N stable slices of the files, for parallel CI
+  --no-gitignore    Expand directories and globs to every file, ignoring .gitignore
+  --follow-symlinks Expand symlinked directories too, skipping any link that loops back
+  --staged          Only process files staged for commit (git, hg or jj), restaging fixes
+  --since REF       Only process files changed since diverging from REF (git, hg or jj)
+  --modified-since WHEN
+                    Only process files modified within a duration (30m, 2h, 3d, 1w) or
+                    since an ISO timestamp such as 2024-05-01T09:00, without needing git
+  --ignore-moved-code
+                    Downgrade findings on lines that were only moved since the last commit
+                    (or --since REF) to info, so they don't fail the run (git only)
+  --group-by owner  Group findings by the owners CODEOWNERS assigns their files to
+  --output FORMAT   Print results as text (default), or as a json or sarif report on stdout.
+                    plain-verbose suits screen readers: a PASS or FAIL line per tool run,
+                    file and finding, with no colour or other control sequences
+  --report FORMAT=PATH
+                    Also write a json, sarif, junit or junit-rule (a case per rule) report
+  --record PATH     Write the tools run (paths, versions, arguments) and a hash of each
+                    file before and after the run to a lock file such as run.lock
+  --replay PATH     Run as usual, then warn about every way the run differs from the one
+                    recorded in PATH: tools, versions, inputs, or the same input coming
+                    out differently
+  --from-archive SOURCE
+                    Process the files in a .tar(.gz, .bz2, .xz) or .zip archive, or one
+                    at an https:// or s3:// URL, unpacked into a temporary directory;
+                    file arguments and reported paths are relative to the archive's root.
+                    Config is read from the current directory, not the archive
+  --tool NAMES      Use these tools (e.g. --tool black, or --tool ruff,prettier) in every
+                    chain that has them, rather than the first available, even if the
+                    config disables them; other chains are unaffected
+  --all-tools       Run every available linter for each file type, such as ruff, flake8
+                    and pylint for Python, rather than only the first; formatting still
+                    uses the first available formatter
+  --builtin-only    Run only taidy's own checks (syntax, conflict markers and
+                    indentation), which need nothing but Python, and no installed tools
+  --skip-tool NAMES Leave these tools (e.g. --skip-tool pylint) out of every chain for
+                    this run, so the next available tool is used, as "disable" does
+  --dry-run         Print the command lines the run would execute, with every file and
+                    argument, without running any tool or touching any file
+  --max-changed-files N
+                    Abort formatting, changing nothing, if it would change over N files
+  --max-diff-lines N
+                    Abort formatting, changing nothing, if it would change over N lines
+  --max-file-size SIZE
+                    Skip files over SIZE (e.g. 500k or 2MB), such as generated bundles
+                    and data dumps that tools would take minutes over
+  --                Treat every later argument as a file, even one starting with a
+                    dash, up to a second `--` that passes the rest to the one tool
+                    the run uses, as in `taidy lint -- app.py -- --select E501`"""
 
 DIRECTORY_PROCESSING_TEXT = """Directory Processing:
   When a directory is specified, taidy recursively finds all supported files
   and processes them. Common directories like .git/, node_modules/, and
-  __pycache__/ are automatically ignored."""
+  __pycache__/ are automatically ignored. Inside a git repository only files
+  tracked by git (as listed by `git ls-files`) are processed, and quoted glob
+  patterns such as 'src/**/*.py' skip files matched by .gitignore. Use
+  --no-gitignore to process every file instead. Symlinked directories are
+  skipped unless --follow-symlinks is given, and then each directory is
+  entered once, so links back to a parent don't loop.
+
+  Files matched by a .taidyignore file (gitignore syntax) in the project root
+  are always skipped, even when named explicitly. Minified bundles (*.min.js,
+  *.min.css) and source maps (*.map) found in directories are skipped too, but
+  are processed when named explicitly, or always with "skip_minified": false."""
 
 SUPPORTED_LANGUAGES_TEXT = """Supported file types and linters:
-  Python:       ruff → uvx ruff → black → flake8 → pylint → python -m py_compile
+  Python:       ruff → uvx ruff → black → flake8 → pylint → python -m py_compile →
+                taidy's built-in syntax check
   JavaScript:   eslint → prettier → node --check
-  TypeScript:   eslint → tsc --noEmit → prettier
+  TypeScript:   eslint → tsc --noEmit (with a tsconfig.json) → prettier; .d.ts
+                declarations are linted but not formatted
   Go:           gofmt
   Rust:         rustfmt
-  Ruby:         rubocop
+  Ruby:         rubocop (also Gemfile, Rakefile and other Ruby DSL files)
   PHP:          php-cs-fixer
   Shell:        shellcheck → beautysh (linting), shfmt → beautysh (formatting)
   JSON/CSS:     prettier
@@ -62,21 +314,119 @@ SUPPORTED_LANGUAGES_TEXT = """Supported file types and linters:
   TOML:         taplo check → taplo format
   Terraform:    terraform validate/tflint → terraform fmt
   Justfile:     just --fmt --check → just --fmt
+  Dockerfile:   hadolint (Dockerfile, Dockerfile.*, *.Dockerfile, Containerfile)
+  Makefile:     checkmake
+  Jenkinsfile:  npm-groovy-lint
+  Bazel:        buildifier --lint=warn → buildifier (BUILD, WORKSPACE, MODULE.bazel)
   GitHub Actions: actionlint → yamllint → prettier (.github/workflows/*.yml)
   Security:     trufflehog (scans for secrets across all file types)
+  EditorConfig: editorconfig-checker (all files, when the project has an .editorconfig)
+  Manifests:    go mod tidy -diff (go.mod), publint (package.json),
+                validate-pyproject (pyproject.toml), cargo verify-project (Cargo.toml)
+  Spelling:     typos → codespell → cspell (all files, with `taidy spell`)
+
+Scripts with no extension, such as bin/deploy, are matched by their #! line: sh, bash,
+zsh, python, node, ruby and php scripts get their language's tools. Failing that, and for
+extensions that don't tell (.in, .conf, .tmpl and the like), a Vim modeline such as
+`# vim: ft=python` or an Emacs mode line such as `# -*- mode: sh -*-` decides.
 
 Taidy automatically detects which linters are available and uses the best one for each file type."""
 
 CONFIGURATION_TEXT = """Configuration:
-  Create a .taidy.json file in your project root to customize behavior.
+  Create a .taidy.json, .taidy.toml or taidy.yaml file in your project root to
+  customize behavior. It is found by searching up from the files being processed.
   Example configuration:
     {
       "ignore": [
         "tests/fixtures/*",
         "vendor/**",
         "*.generated.*"
-      ]
+      ],
+      "prefer": ["ruff", "prettier"],
+      "disable": ["pylint"],
+      "args": {"ruff check": ["--select", "E,F"]},
+      "extensions": {".mjs": ".js"}
     }
+
+  "prefer" moves the listed tools to the front of every chain they appear in.
+  "disable" removes tools from every chain ("disabled_tools" is another name for it).
+  Every file is also linted for leftover merge conflict markers; "taidy.conflicts"
+  in "disable" turns that off. Files with no available formatter have their
+  indentation checked for mixed tabs and spaces and uneven widths, as warnings that
+  only fail the run with --strict; "taidy.indentation" in "disable" turns that off.
+  "args" adds arguments to a tool, for every run ("ruff") or one subcommand
+  ("ruff check"). "lint_args" and "format_args" add them only when the tool lints or
+  formats, e.g. {"prettier": ["--prose-wrap", "always"]} in "format_args". An entry
+  of {"replace": [...]} in any of the three replaces taidy's own arguments for the
+  tool (after the subcommand, for a "tool subcommand" key) rather than adding to them;
+  the files are still added at the end. Replacements don't apply to --stdin runs.
+  "extensions" treats files with one extension like another, e.g. .mjs as .js, or
+  like a language's files, e.g. {".pyi": "python", ".mjml": "html"}; "skip" instead,
+  as in {".snap": "skip"}, keeps files with that extension from every tool.
+  "tools" defines tools of your own, run on the files matching their globs alongside
+  the built-in ones, e.g. {"stylelint": {"command": "stylelint --fix", "files":
+  ["*.{css,scss}"], "kind": "format"}}; "kind" is "lint" (the default) or "format",
+  and the files are added to the end of the command. A tool named like a built-in
+  one replaces it. Only files taidy supports are matched.
+  "when" sets conditions a tool needs beyond being installed, keyed like "args",
+  e.g. {"pylint": ["files < 50"], "shellcheck": ["os != windows"], "eslint":
+  ["exists eslint.config.*"]}; "env NAME" needs a variable set, and "not" negates
+  any of them. A tool whose conditions fail is skipped for the next in its chain.
+  "order" is "lint-first" (the default) or "format-first": whether `taidy` without a
+  command lints the files before formatting them, so findings match the code as
+  written, or after, so they match the formatted code. "extension_order" overrides
+  it per extension, e.g. {".go": "format-first"}. Either way the two don't overlap.
+  "success" sets how a tool's runs are judged, keyed like "args", e.g.
+  {"mytool": {"success_codes": [0, 3], "error_codes": [2], "fail_on_output": "stdout"}}:
+  exit codes that mean it passed (default [0]), exit codes that mean the tool itself
+  failed, which fails the run even with --exit-zero, and a stream ("stdout", "stderr"
+  or "any") whose output means findings. "fail_on_findings": true fails the tool on
+  any finding, as --strict does for every tool. Built-in settings cover tools such
+  as gofmt, tflint and pylint.
+  "thresholds" fails a tool on how many findings it reports rather than on any
+  finding, to tighten legacy code gradually, e.g. {"ruff": {"max_errors": 0},
+  "pylint": {"max_errors": 50, "max_warnings": 200}}; "max_findings" counts every
+  severity.
+  "recurse_submodules": true processes git submodules too, like --recurse-submodules;
+  a submodule's own config file takes precedence over the parent project's.
+  .taidy.toml needs Python 3.11+ (or the tomli package) and taidy.yaml needs PyYAML.
+  "sensitive" adds patterns to the built-in list of secrets files (.env, *.pem,
+  id_rsa, ...) that are never passed to linters or formatters.
+  "skip_minified": false stops minified bundles and source maps (*.min.js,
+  *.min.css, *.map) found in directories being skipped; named files never are.
+  "license_header" is the header `taidy license` checks for and inserts, written
+  without comment markers, e.g. "SPDX-License-Identifier: MIT"; {year} is replaced
+  with the current year and matches any year when checking.
+  "path" lists directories searched for tools before PATH, e.g. ["~/.local/bin",
+  "tools/bin"], with relative ones taken from the project root. Tools found there
+  are run by full path; "export_path": true also puts the directories on the
+  tools' own PATH.
+  "login_shell": true looks up tools missing from PATH through your login shell,
+  like --login-shell, for editors that don't see the PATH set by shell init files.
+  "explain" turns on `taidy explain`, which sends findings and the source lines
+  around them to a language model, e.g. {"provider": "anthropic", "model": "...",
+  "api_key_env": "ANTHROPIC_API_KEY"}; provider is anthropic or openai, the key is
+  read from the named environment variable, and "endpoint" overrides the API's URL.
+  Only the user-level config can change "api_key_env" and "endpoint". Nothing is
+  sent without it.
+  "jobs", "prefer_fast", "show_context", "quiet_success", "all_tools",
+  "builtin_only" and "max_file_size" (e.g. "2MB") set defaults for the flags of the same name, and
+  "languages" (e.g. ["python", "go"]) for --lang. "jobs" takes a total or, as
+  --jobs does, limits for file types too, e.g. "8,.ts=2,rust=1".
+  "preset" starts from one of the built-in presets listed below; the config's own
+  keys still win, except that "disable", "ignore" and "sensitive" add to the preset's.
+
+  Personal defaults go in $XDG_CONFIG_HOME/taidy/config.toml (~/.config/taidy/
+  config.toml by default), with the same keys. The project's config is layered on
+  top: its keys win, and tables such as "args" are merged key by key.
+  Run `taidy config import` to scaffold it from pre-commit, package.json scripts,
+  Makefile lint targets and existing tool configuration files, or `taidy import
+  pre-commit` to convert .pre-commit-config.yaml hooks, with their args, into a
+  .taidy.toml. `taidy import lint-staged` converts package.json's lint-staged globs
+  and commands into "tools".
+
+  taidy's own messages are in the language TAIDY_LANG selects: de, en (the default)
+  or es. Tools' output is shown as they print it.
 """.strip()
 
 # Configure logging
@@ -98,6 +448,9 @@ class Mode(Enum):
     BOTH = "both"  # Both lint and format
     LINT = "lint"  # Lint only
     FORMAT = "format"  # Format only
+    IMPORTS = "imports"  # Organize imports only
+    FIX = "fix"  # Apply linters' automatic fixes only
+    SPELL = "spell"  # Spell-check only
 
 
 @dataclass
@@ -107,37 +460,727 @@ class LinterCommand:
     available: Callable[[], bool]
     command: Callable[[List[str]], Tuple[str, List[str]]]
     supports_directories: bool = False
+    # Entries sharing a capability are interchangeable and may be reordered by --prefer-fast
+    capability: Optional[str] = None
+    # Runs once per file, with the path built into its arguments (for project manifests)
+    per_file: bool = False
+    # Reads a file's content on stdin, given the file's name as a hint for its language
+    # and config; formatters write the result to stdout, linters their findings
+    stdin_command: Optional[Callable[[str], Tuple[str, List[str]]]] = None
+    # Conditions that must also hold for the entry to be used, such as "exists
+    # tsconfig.json" (see taidy.conditions); config's "when" adds to them
+    conditions: List[str] = field(default_factory=list)
+
+
+# Language registry: maps language names (and common aliases) to taidy's extension keys
+LANGUAGES: Dict[str, List[str]] = {
+    "python": [".py"],
+    "javascript": [".js", ".jsx"],
+    "typescript": [".ts", ".tsx", ".d.ts"],
+    "go": [".go"],
+    "rust": [".rs"],
+    "ruby": [".rb"],
+    "php": [".php"],
+    "shell": [".sh", ".bash", ".zsh"],
+    "json": [".json"],
+    "css": [".css", ".scss"],
+    "html": [".html"],
+    "markdown": [".md"],
+    "pug": [".pug"],
+    "yaml": [".yaml", ".yml"],
+    "toml": [".toml"],
+    "terraform": [".tf", ".tfvars"],
+    "justfile": ["justfile"],
+    "dockerfile": ["dockerfile"],
+    "makefile": ["makefile"],
+    "jenkinsfile": ["jenkinsfile"],
+    "bazel": ["bazel"],
+    "github-actions": [".github-workflow"],
+    "security": [".security"],
+    "manifests": ["go.mod", "package.json", "pyproject.toml", "Cargo.toml"],
+}
+
+LANGUAGE_ALIASES: Dict[str, str] = {
+    "py": "python",
+    "js": "javascript",
+    "ts": "typescript",
+    "golang": "go",
+    "rs": "rust",
+    "rb": "ruby",
+    "sh": "shell",
+    "bash": "shell",
+    "zsh": "shell",
+    "scss": "css",
+    "md": "markdown",
+    "yml": "yaml",
+    "tf": "terraform",
+    "just": "justfile",
+    "docker": "dockerfile",
+    "make": "makefile",
+    "jenkins": "jenkinsfile",
+    "starlark": "bazel",
+    "actions": "github-actions",
+}
+
+
+def resolve_languages(names: str) -> Set[str]:
+    """Resolve a comma-separated list of language names to extension keys"""
+    extensions: Set[str] = set()
+    for name in names.split(","):
+        name = name.strip().lower()
+        if not name:
+            continue
+        language = LANGUAGE_ALIASES.get(name, name)
+        if language not in LANGUAGES:
+            known = ", ".join(sorted(LANGUAGES))
+            raise ValueError(f"Unknown language: {name} (known languages: {known})")
+        extensions.update(LANGUAGES[language])
+    return extensions
+
+
+def parse_shard(value: str) -> Tuple[int, int]:
+    """Parse a --shard value such as 2/5 into (index, count)"""
+    index, _, count = value.partition("/")
+    try:
+        shard = (int(index), int(count))
+    except ValueError:
+        raise ValueError(f"Invalid shard {value}, expected INDEX/COUNT such as 2/5")
+    if not 1 <= shard[0] <= shard[1]:
+        raise ValueError(f"Invalid shard {value}, the index must be between 1 and the count")
+    return shard
+
+
+def in_shard(file: str, root: Path, shard: Tuple[int, int]) -> bool:
+    """Check whether a file belongs to a shard, by a hash of its path from the project root"""
+    relative = Path(os.path.relpath(os.path.abspath(file), root)).as_posix()
+    digest = int(hashlib.sha1(relative.encode()).hexdigest(), 16)
+    return digest % shard[1] == shard[0] - 1
+
+
+# Formats for --output; all but text print a single report once the run has finished
+OUTPUT_FORMATS = ["text", "json", "sarif", "plain-verbose"]
+
+# Formats for --report; junit has a test case per file, junit-rule one per rule
+REPORT_FORMATS = ["json", "sarif", "junit", "junit-rule"]
+
+# Units for --modified-since durations such as 30m or 2h
+DURATION_UNITS = {"s": 1, "m": 60, "h": 3600, "d": 86400, "w": 604800}
+
+
+def parse_modified_since(flag: str, value: str) -> float:
+    """Parse a duration (2h, 3d) or ISO timestamp into the time it stands for"""
+    unit = DURATION_UNITS.get(value[-1:])
+    if unit is not None and value[:-1].isdigit():
+        return time.time() - int(value[:-1]) * unit
+
+    try:
+        return datetime.fromisoformat(value).timestamp()
+    except ValueError:
+        raise ValueError(
+            f"Invalid {flag} value {value}, expected a duration such as 2h or 3d, "
+            "or a timestamp such as 2024-05-01T09:00"
+        ) from None
+
+
+def parse_count(flag: str, value: str) -> int:
+    """Parse a non-negative count given to a flag"""
+    if not value.isdigit():
+        raise ValueError(f"Invalid {flag} value {value}, expected a whole number")
+    return int(value)
+
+
+def parse_jobs(flag: str, value: str) -> Tuple[Optional[int], Dict[str, int]]:
+    """Parse a --jobs value: a total such as 8, limits for file types or languages such as
+    .ts=2 or rust=1, or both separated by commas. Returns the total, if given, and the
+    limits by extension key."""
+    total = None
+    limits: Dict[str, int] = {}
+    for item in value.split(","):
+        key, separator, count = item.strip().rpartition("=")
+        jobs = parse_count(flag, count)
+        if jobs < 1:
+            raise ValueError(f"Invalid {flag} value {item.strip()}, expected at least 1")
+        if not separator:
+            total = jobs
+            continue
+        extensions = {key.lower()} if key.startswith(".") else resolve_languages(key)
+        for ext in extensions:
+            limits[ext] = jobs
+    return total, limits
+
+
+# Multipliers of the units a size can be given in, as in 500k or 2MB
+SIZE_UNITS = {"": 1, "b": 1, "k": 1024, "kb": 1024, "m": 1024**2, "mb": 1024**2, "g": 1024**3}
+
+
+def parse_size(flag: str, value: str) -> int:
+    """Parse a size in bytes given to a flag, such as 500k, 2MB or 1048576"""
+    match = re.fullmatch(r"(\d+(?:\.\d+)?)\s*([a-z]*)", value.strip().lower())
+    if not match or match.group(2) not in SIZE_UNITS:
+        raise ValueError(f"Invalid {flag} value {value}, expected a size such as 500k or 2MB")
+    return int(float(match.group(1)) * SIZE_UNITS[match.group(2)])
+
+
+def describe_size(size: int) -> str:
+    """Render a size in bytes for people, such as 2.5 MB"""
+    for unit, multiplier in [("GB", 1024**3), ("MB", 1024**2), ("kB", 1024)]:
+        if size >= multiplier:
+            return f"{size / multiplier:.1f} {unit}"
+    return f"{size} bytes"
+
+
+@dataclass
+class RunOptions:
+    """Options for a single taidy run, parsed from command-line flags"""
+
+    prefer_fast: bool = False
+    show_context: bool = False
+    # Extension keys selected with --lang; None means every language
+    language_extensions: Optional[Set[str]] = None
+    # Built-in config preset from --preset
+    preset: Optional[str] = None
+    error_on_empty: bool = False
+    quiet_success: bool = False
+    timings: bool = False
+    # Show each tool's binary, working directory and environment changes, from --show-env
+    show_env: bool = False
+    # Arguments after a second `--` given to the run's one tool, after the config's
+    tool_args: List[str] = field(default_factory=list)
+    # Archive file or URL whose contents are processed instead, from --from-archive
+    from_archive: Optional[str] = None
+    # Tools used in every chain that has them, whatever the chain's order, from --tool
+    tools: List[str] = field(default_factory=list)
+    # Tools left out of every chain for this run, from --skip-tool
+    skip_tools: List[str] = field(default_factory=list)
+    # Run every available linter in each chain rather than the first, from --all-tools
+    all_tools: bool = False
+    # Run only taidy's own checks, none of the installed tools, from --builtin-only
+    builtin_only: bool = False
+    resume: bool = False
+    allow_sensitive: bool = False
+    scan_sensitive: bool = False
+    recurse_submodules: bool = False
+    use_gitignore: bool = True
+    # Enter symlinked directories when expanding directories, from --follow-symlinks
+    follow_symlinks: bool = False
+    staged: bool = False
+    # Git ref from --since; only files changed since it are processed
+    since: Optional[str] = None
+    # Time from --modified-since; only files modified after it are processed
+    modified_since: Optional[float] = None
+    # How findings are grouped in the report, from --group-by (only "owner" so far)
+    group_by: Optional[str] = None
+    # Diff budget for formatting, from --max-changed-files and --max-diff-lines
+    max_changed_files: Optional[int] = None
+    max_diff_lines: Optional[int] = None
+    # Files over this many bytes aren't passed to any tool, from --max-file-size
+    max_file_size: Optional[int] = None
+    exit_zero: bool = False
+    # Print the formatted file instead of rewriting it, from --stdout
+    stdout: bool = False
+    # Read the content to process from stdin, from --stdin, as if it were the file
+    # named by --stdin-filename
+    stdin: bool = False
+    stdin_filename: Optional[str] = None
+    # Whether findings fail a run even when the tool exits successfully, from --strict
+    # and --no-strict; None leaves it to --ci
+    strict: Optional[bool] = None
+    # Whether to use the CI defaults, from --ci and --no-ci; None detects CI
+    ci: Optional[bool] = None
+    # Number of tool runs at once, from --jobs; None means one per CPU
+    jobs: Optional[int] = None
+    # Most runs at once over each extension key's files, from --jobs KEY=N
+    extension_jobs: Dict[str, int] = field(default_factory=dict)
+    login_shell: bool = False
+    # --output format; for json and sarif, results are collected in report and printed at the end
+    output: str = "text"
+    # (format, path) of each --report to write once the run has finished
+    report_files: List[Tuple[str, str]] = field(default_factory=list)
+    report: Optional[Report] = None
+    # (index, count) from --shard, with a 1-based index
+    shard: Optional[Tuple[int, int]] = None
+    # Lock file to write the run's tools and file hashes to, from --record, or to check
+    # them against, from --replay
+    record: Optional[str] = None
+    replay: Optional[str] = None
+    # Print the command lines the run would execute instead of running them, from --dry-run
+    dry_run: bool = False
+    # Downgrade findings on lines that were moved rather than written, from --ignore-moved-code
+    ignore_moved_code: bool = False
+    # Whether a run over the whole project is added to its history; the daemon and
+    # `taidy explain` run on a user's behalf, so leave it alone
+    record_history: bool = True
+
+
+
+def parse_flags(args: List[str]) -> Tuple[RunOptions, List[str]]:
+    """Split command-line arguments into run options and remaining positional arguments"""
+    options = RunOptions()
+    positional = []
+
+    remaining = list(args)
+    while remaining:
+        arg = remaining.pop(0)
+
+        # Flags taking a value accept both `--flag value` and `--flag=value`
+        flag, has_value, value = arg.partition("=")
+
+        def take_value() -> str:
+            if has_value:
+                return value
+            if not remaining:
+                raise ValueError(f"Flag {flag} requires a value")
+            return remaining.pop(0)
+
+        # `--` ends taidy's flags, so later arguments are files even if they start with a
+        # dash, up to a second `--` passing the rest to the tool the run uses
+        if arg == "--":
+            separator = remaining.index("--") if "--" in remaining else len(remaining)
+            positional.extend(remaining[:separator])
+            options.tool_args = remaining[separator + 1 :]
+            break
+        if arg == "--prefer-fast":
+            options.prefer_fast = True
+        elif arg == "--show-context":
+            options.show_context = True
+        elif arg == "--error-on-empty":
+            options.error_on_empty = True
+        elif arg == "--stdout":
+            options.stdout = True
+        elif arg == "--stdin":
+            options.stdin = True
+        elif flag == "--stdin-filename":
+            options.stdin_filename = take_value()
+        elif arg == "--timings":
+            options.timings = True
+        elif arg == "--dry-run":
+            options.dry_run = True
+        elif arg == "--show-env":
+            options.show_env = True
+        elif arg == "--all-tools":
+            options.all_tools = True
+        elif arg == "--builtin-only":
+            options.builtin_only = True
+        elif arg == "--ignore-moved-code":
+            options.ignore_moved_code = True
+        elif arg == "--quiet-success":
+            options.quiet_success = True
+        elif arg == "--resume":
+            options.resume = True
+        elif arg == "--allow-sensitive":
+            options.allow_sensitive = True
+        elif arg == "--scan-sensitive":
+            options.scan_sensitive = True
+        elif arg == "--recurse-submodules":
+            options.recurse_submodules = True
+        elif arg == "--no-gitignore":
+            options.use_gitignore = False
+        elif arg == "--follow-symlinks":
+            options.follow_symlinks = True
+        elif arg in ["--strict", "--no-strict"]:
+            options.strict = arg == "--strict"
+        elif arg in ["--ci", "--no-ci"]:
+            options.ci = arg == "--ci"
+        elif arg == "--exit-zero":
+            options.exit_zero = True
+        elif arg == "--login-shell":
+            options.login_shell = True
+        elif arg == "--staged":
+            options.staged = True
+        elif flag == "--preset":
+            options.preset = take_value()
+            preset_config(options.preset)
+        elif flag == "--lang":
+            options.language_extensions = resolve_languages(take_value())
+        elif flag == "--shard":
+            options.shard = parse_shard(take_value())
+        elif flag == "--since":
+            options.since = take_value()
+        elif flag == "--modified-since":
+            options.modified_since = parse_modified_since(flag, take_value())
+        elif flag == "--max-changed-files":
+            options.max_changed_files = parse_count(flag, take_value())
+        elif flag == "--max-diff-lines":
+            options.max_diff_lines = parse_count(flag, take_value())
+        elif flag == "--max-file-size":
+            options.max_file_size = parse_size(flag, take_value())
+        elif flag == "--jobs" or flag == "-j":
+            total, limits = parse_jobs(flag, take_value())
+            options.jobs = total if total is not None else options.jobs
+            options.extension_jobs.update(limits)
+        elif flag == "--output":
+            options.output = take_value()
+            if options.output not in OUTPUT_FORMATS:
+                raise ValueError(
+                    f"Unknown --output format: {options.output} "
+                    f"(expected {', '.join(OUTPUT_FORMATS)})"
+                )
+        elif flag == "--report":
+            report_format, _, path = take_value().partition("=")
+            if report_format not in REPORT_FORMATS or not path:
+                raise ValueError(
+                    f"Invalid --report value, expected FORMAT=PATH with FORMAT one of "
+                    f"{', '.join(REPORT_FORMATS)}"
+                )
+            options.report_files.append((report_format, path))
+        elif flag == "--tool":
+            options.tools += [name.strip() for name in take_value().split(",") if name.strip()]
+            unknown = unknown_tools(options.tools)
+            if unknown:
+                raise ValueError(f"Unknown tool for --tool: {', '.join(unknown)}")
+        elif flag == "--skip-tool":
+            options.skip_tools += [n.strip() for n in take_value().split(",") if n.strip()]
+            unknown = unknown_tools(options.skip_tools)
+            if unknown:
+                raise ValueError(f"Unknown tool for --skip-tool: {', '.join(unknown)}")
+        elif flag == "--from-archive":
+            options.from_archive = take_value()
+        elif flag == "--record":
+            options.record = take_value()
+        elif flag == "--replay":
+            options.replay = take_value()
+        elif flag == "--group-by":
+            options.group_by = take_value()
+            if options.group_by != "owner":
+                raise ValueError(f"Unknown --group-by value: {options.group_by} (expected owner)")
+        elif arg.startswith("--"):
+            raise ValueError(f"Unknown flag: {arg}")
+        else:
+            positional.append(arg)
+
+    # An archive is processed from a copy that's thrown away, outside any repository
+    if options.from_archive is not None:
+        conflicting = [
+            flag
+            for flag, given in [
+                ("--staged", options.staged),
+                ("--since", options.since),
+                ("--resume", options.resume),
+                ("--stdin", options.stdin),
+                ("--stdout", options.stdout),
+            ]
+            if given
+        ]
+        if conflicting:
+            raise ValueError(f"--from-archive can't be combined with {', '.join(conflicting)}")
+
+    if options.builtin_only and options.tools:
+        raise ValueError("--builtin-only can't be combined with --tool")
+
+    both = sorted(set(options.tools) & set(options.skip_tools))
+    if both:
+        raise ValueError(f"Can't both use and skip {', '.join(both)}")
+
+    # A dry run runs nothing, so it has no results to report, record or print
+    if options.dry_run:
+        conflicting = [
+            flag
+            for flag, given in [
+                ("--output", options.output != "text"),
+                ("--report", options.report_files),
+                ("--record", options.record),
+                ("--replay", options.replay),
+                ("--stdin", options.stdin),
+                ("--stdout", options.stdout),
+            ]
+            if given
+        ]
+        if conflicting:
+            raise ValueError(f"--dry-run can't be combined with {', '.join(conflicting)}")
+
+    # Under CI warnings fail the build, and a JSON report is kept for later steps
+    if options.ci is None:
+        options.ci = running_in_ci()
+    if options.ci:
+        if options.strict is None:
+            options.strict = True
+        if not options.report_files:
+            options.report_files.append(("json", CI_REPORT_PATH))
+    if options.dry_run:
+        options.report_files = []
+
+    # Reports written to files, and run locks, are collected alongside the usual output
+    if options.output != "text" or options.report_files or options.record or options.replay:
+        options.report = Report(quiet=options.output != "text")
+
+    return options, positional
 
 
 # Cache for command availability to avoid repeated shutil.which() calls
 _command_availability_cache: Dict[str, bool] = {}
 
 
+@dataclass
+class ToolSearchPath:
+    """Directories from the config's "path", searched for tools ahead of PATH"""
+
+    directories: List[str]
+    # With the config's "export_path", child tools see the directories on their PATH too
+    export: bool = False
+    # With --login-shell or "login_shell", tools missing from the search path are looked
+    # up through the user's login shell, for tools set up by nvm, pyenv and the like
+    login_shell: bool = False
+
+
+tool_search_path = ToolSearchPath([])
+
+# Tools found through the login shell, by name
+_login_shell_commands: Dict[str, str] = {}
+
+
+def configure_search_path(config: Dict[str, Any], root: Path, login_shell: bool = False) -> None:
+    """Apply a project's "path" config; relative directories are from the project root"""
+    directories = []
+    for entry in config.get("path", []):
+        directory = Path(os.path.expanduser(entry))
+        directories.append(str(directory if directory.is_absolute() else root / directory))
+
+    if directories != tool_search_path.directories:
+        _command_availability_cache.clear()
+    tool_search_path.directories = directories
+    tool_search_path.export = bool(config.get("export_path", False))
+    tool_search_path.login_shell = login_shell or bool(config.get("login_shell", False))
+
+
+def search_path() -> str:
+    """Get the search path for tools: the configured directories, then PATH"""
+    return os.pathsep.join(tool_search_path.directories + [os.environ.get("PATH", os.defpath)])
+
+
+def probe_login_shell(cmd: str) -> Optional[str]:
+    """Ask the user's login shell where a command is, as an interactive terminal would see it"""
+    shell = os.environ.get("SHELL")
+    if not shell:
+        return None
+
+    try:
+        result = subprocess.run(
+            [shell, "-lc", f"command -v {shlex.quote(cmd)}"],
+            stdin=subprocess.DEVNULL,
+            capture_output=True,
+            text=True,
+            timeout=10,
+        )
+    except (OSError, subprocess.SubprocessError) as e:
+        logger.debug(f"Login shell lookup of {cmd} failed: {e}")
+        return None
+
+    # Shell startup files may print banners, so only the last line is the answer
+    lines = result.stdout.strip().splitlines()
+    path = lines[-1] if lines else ""
+    if result.returncode != 0 or not os.path.isabs(path) or not os.access(path, os.X_OK):
+        return None
+    return path
+
+
+def resolve_command(cmd: str) -> str:
+    """Find a tool's full path on the search path, so child processes needn't search"""
+    if cmd in _login_shell_commands:
+        return _login_shell_commands[cmd]
+    return shutil.which(cmd, path=search_path()) or cmd
+
+
+# Whether this run uses the CI defaults, from --ci or --no-ci or else detected
+ci_mode = False
+
+
+def enable_ci_mode(enabled: bool) -> None:
+    """Run tools without colour or prompts, as suits a CI log"""
+    global ci_mode
+    ci_mode = enabled
+
+
+# Whether tools are asked not to colour their output, for --output plain-verbose
+colourless_tools = False
+
+
+def enable_colourless_tools(enabled: bool) -> None:
+    """Ask tools for output without colour or other escape sequences"""
+    global colourless_tools
+    colourless_tools = enabled
+
+
+# Whether each tool run shows how taidy runs it, for --show-env
+show_env = False
+
+
+def enable_show_env(enabled: bool) -> None:
+    """Show each tool's binary, working directory and environment before running it"""
+    global show_env
+    show_env = enabled
+
+
+def environment_changes(environment: Optional[Dict[str, str]]) -> List[str]:
+    """Describe how a tool's environment differs from taidy's own, with PATH shown as
+    the directories put in front of it"""
+    if environment is None:
+        return []
+    changes = []
+    for name, value in sorted(environment.items()):
+        original = os.environ.get(name)
+        if value == original:
+            continue
+        if name == "PATH" and original and value.endswith(os.pathsep + original):
+            value = value[: -len(original)] + "$PATH"
+        changes.append(f"{name}={value}")
+    changes += [f"{name} unset" for name in sorted(set(os.environ) - set(environment))]
+    return changes
+
+
+def tool_environment() -> Optional[Dict[str, str]]:
+    """Get the environment for child tools: unchanged unless "export_path" is set,
+    running under CI or colour is turned off.
+
+    Tools found through the login shell get their own directory on PATH, as they often
+    need neighbouring programs (node for nvm's eslint, for example).
+    """
+    directories = list(tool_search_path.directories) if tool_search_path.export else []
+    for path in _login_shell_commands.values():
+        if os.path.dirname(path) not in directories:
+            directories.append(os.path.dirname(path))
+    if not directories and not ci_mode and not colourless_tools:
+        return None
+
+    environment = dict(os.environ, **CI_TOOL_ENVIRONMENT) if ci_mode else dict(os.environ)
+    if colourless_tools:
+        environment.update(NO_COLOUR_ENVIRONMENT)
+    if directories:
+        environment["PATH"] = os.pathsep.join(directories + [os.environ.get("PATH", "")])
+    return environment
+
+
+# Tool paths and versions remembered across runs, loaded when first needed
+_tool_cache: Optional[ToolCache] = None
+tool_cache_lock = threading.Lock()
+
+
+def tool_cache() -> ToolCache:
+    """Get the cross-run cache of tool paths and versions"""
+    global _tool_cache
+    if _tool_cache is None:
+        _tool_cache = ToolCache(tool_cache_path())
+    return _tool_cache
+
+
+def login_shell_path(cmd: str) -> Optional[str]:
+    """Find a command through the login shell, remembering the answer across runs"""
+    key = f"login-shell {os.environ.get('SHELL', '')} {cmd}"
+    with tool_cache_lock:
+        entry = tool_cache().lookup(key)
+    if entry is not None:
+        return str(entry["path"])
+
+    path = probe_login_shell(cmd)
+    if path is not None:
+        with tool_cache_lock:
+            tool_cache().remember(key, path)
+            tool_cache().save()
+    return path
+
+
+def tool_version(cmd: str) -> Optional[str]:
+    """Get the first line a tool prints for --version, remembered across runs until the
+    binary changes. Runners like uvx and npx have no version of the tool they'd run.
+    """
+    if cmd in ["uvx", "npx", "bunx"]:
+        return None
+    path = resolve_command(cmd)
+    if not os.path.isabs(path):
+        return None
+
+    key = f"version {path}"
+    with tool_cache_lock:
+        entry = tool_cache().lookup(key)
+    if entry is not None and isinstance(entry.get("version"), str):
+        return str(entry["version"])
+
+    try:
+        result = subprocess.run(
+            [path, "--version"],
+            stdin=subprocess.DEVNULL,
+            capture_output=True,
+            text=True,
+            errors="replace",
+            timeout=10,
+        )
+    except (OSError, subprocess.SubprocessError) as e:
+        logger.debug(f"Asking {cmd} for its version failed: {e}")
+        return None
+    lines = [line.strip() for line in (result.stdout or result.stderr).splitlines()]
+    version = next((line for line in lines if line), None)
+    if result.returncode != 0 or version is None:
+        return None
+
+    with tool_cache_lock:
+        tool_cache().remember(key, path, version=version)
+        tool_cache().save()
+    return version
+
+
 def is_command_available(cmd: str) -> bool:
-    """Check if a command is available in PATH, with caching"""
+    """Check if a command is available on the search path, with caching"""
     if cmd not in _command_availability_cache:
-        _command_availability_cache[cmd] = shutil.which(cmd) is not None
+        available = shutil.which(cmd, path=search_path()) is not None
+        if not available and tool_search_path.login_shell:
+            path = login_shell_path(cmd)
+            if path is not None:
+                logger.debug(f"Found {cmd} through the login shell at {path}")
+                _login_shell_commands[cmd] = path
+                available = True
+        _command_availability_cache[cmd] = available
     return _command_availability_cache[cmd]
 
 
+@dataclass
+class GitPaths:
+    """Where git finds a directory's working tree"""
+
+    toplevel: Path
+
+
+# Cache of git paths per directory, as asking git costs a subprocess
+_git_paths_cache: Dict[Path, Optional[GitPaths]] = {}
+
+
+def get_git_paths(directory: Path) -> Optional[GitPaths]:
+    """Ask git for the root of a directory's working tree.
+
+    Unlike looking for a .git directory, this is right for linked worktrees and
+    submodules (where .git is a file) and for repositories set up with GIT_DIR.
+    """
+    directory = directory.resolve()
+    if directory.is_file():
+        directory = directory.parent
+
+    if directory not in _git_paths_cache:
+        paths = None
+        try:
+            result = subprocess.run(
+                ["git", "rev-parse", "--show-toplevel"],
+                cwd=directory,
+                capture_output=True,
+                text=True,
+                timeout=10,
+            )
+            toplevel = result.stdout.strip()
+            if result.returncode == 0 and toplevel:
+                paths = GitPaths(Path(toplevel).resolve())
+        except Exception as e:
+            logger.debug(f"Failed to find git paths for {directory}: {e}")
+        _git_paths_cache[directory] = paths
+
+    return _git_paths_cache[directory]
+
+
 def is_git_repository(directory: Path) -> bool:
-    """Check if a directory is inside a git repository"""
-    current = directory.resolve()
-    while current != current.parent:
-        if (current / ".git").exists():
-            return True
-        current = current.parent
-    return False
+    """Check if a directory is inside a git working tree"""
+    return get_git_paths(directory) is not None
 
 
 def find_git_root(directory: Path) -> Optional[Path]:
-    """Find the root of the git repository containing the directory"""
-    current = directory.resolve()
-    while current != current.parent:
-        if (current / ".git").exists():
-            return current
-        current = current.parent
-    return None
+    """Find the root of the git working tree containing the directory"""
+    paths = get_git_paths(directory)
+    return paths.toplevel if paths else None
 
 
 def get_git_ignored_files(git_root: Path) -> Set[Path]:
@@ -146,15 +1189,17 @@ def get_git_ignored_files(git_root: Path) -> Set[Path]:
 
     try:
         result = subprocess.run(
-            ["git", "status", "--ignored", "--porcelain=v1"],
+            ["git", "status", "--ignored", "--porcelain=v1", "-z"],
             cwd=git_root,
             capture_output=True,
             text=True,
+            errors="surrogateescape",
             timeout=10,
         )
 
         if result.returncode == 0:
-            for line in result.stdout.splitlines():
+            # Entries are NUL-separated, as names can hold newlines
+            for line in result.stdout.split("\0"):
                 if line.startswith("!!"):
                     # Remove the '!! ' prefix and get the file path
                     file_path = line[3:]
@@ -165,23 +1210,844 @@ def get_git_ignored_files(git_root: Path) -> Set[Path]:
     return ignored_files
 
 
-def load_config(start_path: str = ".") -> Dict[str, Any]:
-    """Load configuration from .taidy.json file, searching up directory tree"""
+def get_git_tracked_files(directory: Path) -> Optional[List[Path]]:
+    """Get files tracked by git under a directory, or None if git can't list them"""
+    try:
+        result = subprocess.run(
+            ["git", "ls-files", "-z"],
+            cwd=directory,
+            capture_output=True,
+            text=True,
+            # File names needn't be valid UTF-8, and must survive the round trip back to bytes
+            errors="surrogateescape",
+            timeout=30,
+        )
+    except Exception as e:
+        logger.debug(f"Failed to list git tracked files: {e}")
+        return None
+
+    if result.returncode != 0:
+        return None
+
+    return [directory / name for name in result.stdout.split("\0") if name]
+
+
+def files_under(files: List[str], paths: List[str]) -> List[str]:
+    """Keep the files that are, or are inside, one of the given paths"""
+    roots = [os.path.abspath(path) for path in paths]
+    return [
+        file
+        for file in files
+        if any(
+            os.path.abspath(file) == root or os.path.abspath(file).startswith(root + os.sep)
+            for root in roots
+        )
+    ]
+
+
+def long_path(path: str) -> str:
+    """Get a path Windows can open even past MAX_PATH, by giving long ones the \\\\?\\ prefix.
+
+    Elsewhere, and for paths short enough, the path is returned as it is.
+    """
+    if os.name != "nt" or len(os.path.abspath(path)) < WINDOWS_MAX_PATH:
+        return path
+    path = os.path.abspath(path)
+    if path.startswith("\\\\?\\"):
+        return path
+    if path.startswith("\\\\"):
+        # Network shares take the form \\?\UNC\server\share
+        return "\\\\?\\UNC\\" + path[2:]
+    return "\\\\?\\" + path
+
+
+def file_digest(file: str) -> Optional[str]:
+    """Hash a file's contents, or None if it can't be read"""
+    try:
+        return hashlib.sha1(Path(long_path(file)).read_bytes()).hexdigest()
+    except OSError:
+        return None
+
+
+def snapshot_files(files: List[str]) -> Dict[str, Tuple[bytes, os.stat_result]]:
+    """Save files' contents and timestamps, so they can be restored later"""
+    snapshot = {}
+    for file in dict.fromkeys(files):
+        try:
+            snapshot[file] = (Path(long_path(file)).read_bytes(), os.stat(long_path(file)))
+        except OSError:
+            continue
+    return snapshot
+
+
+def make_working_copies(files: List[str]) -> Dict[str, Tuple[str, os.stat_result]]:
+    """Copy files for formatters to work on instead, returning each copy's path and the
+    file's stat when it was copied. A copy keeps its file's name, in a hidden directory
+    beside it, so tools find the same config and config keyed on file names, such as
+    per-file-ignores for __init__.py, still applies. Files that can't be copied are left
+    out."""
+    copies = {}
+    for file in dict.fromkeys(files):
+        directory = os.path.dirname(file)
+        try:
+            stat = os.stat(long_path(file))
+            temp_directory = tempfile.mkdtemp(prefix=".taidy-", dir=directory or ".")
+            copy_path = os.path.join(directory, os.path.basename(temp_directory), Path(file).name)
+            shutil.copyfile(long_path(file), long_path(copy_path))
+        except OSError as e:
+            logger.warning(f"Not formatting {printable_path(file)}: {e}")
+            continue
+        copies[file] = (copy_path, stat)
+    return copies
+
+
+def remove_working_copies(copies: Dict[str, Tuple[str, os.stat_result]]) -> None:
+    """Delete the copies formatters worked on, and the directories holding them"""
+    for copy_path, _ in copies.values():
+        shutil.rmtree(long_path(os.path.dirname(copy_path) or "."), ignore_errors=True)
+
+
+def diff_line_count(before: bytes, after: bytes) -> int:
+    """Count the lines added or removed between two versions of a file"""
+    old = before.decode(errors="replace").splitlines()
+    new = after.decode(errors="replace").splitlines()
+    return sum(
+        1
+        for line in difflib.unified_diff(old, new, lineterm="", n=0)
+        if line[:1] in "+-" and not line.startswith(("+++", "---"))
+    )
+
+
+@dataclass
+class FormatPlan:
+    """What formatting would change, collected before any of it is kept"""
+
+    originals: Dict[str, Tuple[bytes, os.stat_result]]
+    # Formatted contents of each file that would change
+    changes: Dict[str, bytes]
+
+    def changed_lines(self) -> int:
+        """Count the lines the planned formatting adds or removes, across all files"""
+        return sum(
+            diff_line_count(self.originals[file][0], after) for file, after in self.changes.items()
+        )
+
+    def diff(self, file: str) -> List[str]:
+        """Get the planned change to a file as unified diff lines"""
+        return list(
+            difflib.unified_diff(
+                self.originals[file][0].decode(errors="replace").splitlines(),
+                self.changes[file].decode(errors="replace").splitlines(),
+                f"a/{file}",
+                f"b/{file}",
+                lineterm="",
+            )
+        )
+
+
+def restore_file(file: str, contents: bytes, stat: os.stat_result) -> None:
+    """Put a file back as it was, timestamps included"""
+    Path(long_path(file)).write_bytes(contents)
+    os.utime(long_path(file), ns=(stat.st_atime_ns, stat.st_mtime_ns))
+
+
+def plan_formatting(
+    snapshot: Dict[str, Tuple[bytes, os.stat_result]],
+    copies: Dict[str, Tuple[str, os.stat_result]],
+) -> FormatPlan:
+    """Collect what the formatters changed in their copies of the files, and in the
+    snapshotted files formatted in place, which are put back"""
+    originals = {}
+    changes = {}
+    for file, (before, stat) in snapshot.items():
+        try:
+            after = Path(long_path(file)).read_bytes()
+        except OSError:
+            continue
+        if after != before:
+            originals[file] = (before, stat)
+            changes[file] = after
+            restore_file(file, before, stat)
+    for file, (copy_path, stat) in copies.items():
+        try:
+            before = Path(long_path(file)).read_bytes()
+            after = Path(long_path(copy_path)).read_bytes()
+        except OSError:
+            continue
+        if after != before:
+            originals[file] = (before, stat)
+            changes[file] = after
+    return FormatPlan(originals, changes)
+
+
+def apply_plan(plan: FormatPlan, files: Optional[List[str]] = None) -> List[str]:
+    """Write the planned formatting to the given files (by default all), returning them.
+
+    A file changed since it was formatted, such as by an editor during the run, is left
+    as it is rather than have the change overwritten.
+    """
+    applied = []
+    for file in plan.changes:
+        if files is not None and file not in files:
+            continue
+        stat = plan.originals[file][1]
+        try:
+            current = os.stat(long_path(file))
+        except OSError:
+            continue
+        if (current.st_mtime_ns, current.st_size) != (stat.st_mtime_ns, stat.st_size):
+            logger.warning(f"{printable_path(file)} changed during the run, so wasn't formatted")
+            continue
+        Path(long_path(file)).write_bytes(plan.changes[file])
+        applied.append(file)
+    return applied
+
+
+def budget_violations(plan: FormatPlan, options: "RunOptions") -> List[str]:
+    """Describe how a formatting plan exceeds the diff budget, if it does"""
+    violations = []
+    changed_files = len(plan.changes)
+    if options.max_changed_files is not None and changed_files > options.max_changed_files:
+        violations.append(
+            message("budget_files", count=changed_files, limit=options.max_changed_files)
+        )
+    if options.max_diff_lines is not None:
+        changed_lines = plan.changed_lines()
+        if changed_lines > options.max_diff_lines:
+            violations.append(
+                message("budget_lines", count=changed_lines, limit=options.max_diff_lines)
+            )
+    return violations
+
+
+@dataclass
+class WorkingCopies:
+    """A run's formatting until it's kept: the copies formatters work on, and the files
+    formatted in place, snapshotted so they can be put back"""
+
+    # The runs that format, which are given the copies
+    signatures: Set[Tuple[str, Tuple[str, ...]]]
+    snapshot: Dict[str, Tuple[bytes, os.stat_result]]
+    copies: Dict[str, Tuple[str, os.stat_result]]
+    # Set once a formatter has run, until its formatting is kept
+    started: bool = False
+
+    def paths_for(self, cmd_signature: Tuple[str, Tuple[str, ...]]) -> Optional[Dict[str, str]]:
+        """Get the copies a run works on, by file; linters see the files under their own
+        names, so config keyed on file names still applies"""
+        if cmd_signature not in self.signatures:
+            return None
+        return {file: copy_path for file, (copy_path, _) in self.copies.items()}
+
+    def needs_keeping(
+        self, step: List[Tuple[Tuple[str, Tuple[str, ...]], List[str], List[str]]]
+    ) -> bool:
+        """Check whether the formatting so far must be kept before a step, as linting that
+        comes after formatting checks the formatted files"""
+        return self.started and any(sig not in self.signatures for sig, _, _ in step)
+
+    def note_step(
+        self, step: List[Tuple[Tuple[str, Tuple[str, ...]], List[str], List[str]]]
+    ) -> None:
+        """Record that a step is running, and whether a formatter is among its runs"""
+        self.started = self.started or any(sig in self.signatures for sig, _, _ in step)
+
+    def finish(self, options: "RunOptions", keep: bool) -> Optional[List[str]]:
+        """Collect what the formatters changed and remove their copies, then keep the
+        changes if asked to, returning the files reformatted, or None when the changes
+        exceed the diff budget and none were kept"""
+        plan = plan_formatting(self.snapshot, self.copies)
+        self.discard()
+        self.snapshot, self.copies, self.started = {}, {}, False
+        if not keep:
+            return []
+        over_budget = budget_violations(plan, options)
+        if over_budget:
+            clear_checkpoint()
+            logger.error(message("budget_exceeded", changes=", ".join(over_budget)))
+            return None
+        return apply_plan(plan)
+
+    def discard(self) -> None:
+        """Remove the copies, leaving the files as they are"""
+        remove_working_copies(self.copies)
+
+
+def prepare_formatting(
+    runs: List[Tuple[Tuple[str, Tuple[str, ...]], List[str], List[str]]],
+    batch_kinds: Dict[Tuple[str, Tuple[str, ...]], str],
+    mode: Mode,
+) -> WorkingCopies:
+    """Copy the files the run formats, and snapshot those formatted in place.
+
+    Formatting runs in two phases: the tools format copies of the files, then the changes
+    are collected into a plan, and only then applied to the files if they are wanted.
+    Commands that can't be given files, like just --fmt, format in place and are undone.
+    Fixing and sorting imports rewrite files with linters, which count as formatters here.
+    """
+    format_runs = [
+        (sig, covered)
+        for sig, _, covered in runs
+        if batch_kinds.get(sig) == "format" or mode in [Mode.IMPORTS, Mode.FIX]
+    ]
+    snapshot = snapshot_files(
+        [f for sig, covered in format_runs if not takes_file_arguments(sig) for f in covered]
+    )
+    copies = make_working_copies(
+        [f for _, covered in format_runs for f in covered if f not in snapshot]
+    )
+    return WorkingCopies({sig for sig, _ in format_runs}, snapshot, copies)
+
+
+def filter_git_ignored(files: List[str]) -> List[str]:
+    """Remove files matched by .gitignore (or nested ignore files) from a list"""
+    if not files or not is_git_repository(Path.cwd()):
+        return files
+
+    try:
+        result = subprocess.run(
+            ["git", "check-ignore", "-z", "--stdin"],
+            input="\0".join(files),
+            capture_output=True,
+            text=True,
+            errors="surrogateescape",
+            timeout=30,
+        )
+    except Exception as e:
+        logger.debug(f"Failed to check git ignored files: {e}")
+        return files
+
+    ignored = set(result.stdout.split("\0"))
+    return [f for f in files if f not in ignored]
+
+
+def get_git_submodules(directory: Path) -> List[Path]:
+    """Get the initialized git submodules under a directory"""
+    try:
+        result = subprocess.run(
+            ["git", "ls-files", "--stage", "-z"],
+            cwd=directory,
+            capture_output=True,
+            text=True,
+            errors="surrogateescape",
+            timeout=30,
+        )
+    except Exception as e:
+        logger.debug(f"Failed to list git submodules: {e}")
+        return []
+
+    submodules = []
+    for entry in result.stdout.split("\0"):
+        info, _, name = entry.partition("\t")
+        # Submodules are recorded as gitlinks, with mode 160000
+        if not info.startswith("160000 "):
+            continue
+        path = directory / name
+        paths = get_git_paths(path) if path.is_dir() else None
+        if paths and paths.toplevel == path.resolve():
+            submodules.append(path)
+    return submodules
+
+
+# Config file names, in order of precedence when a directory has more than one
+CONFIG_FILES = [".taidy.json", ".taidy.toml", "taidy.yaml", "taidy.yml"]
+
+# User-level config files in $XDG_CONFIG_HOME/taidy, in order of precedence
+USER_CONFIG_FILES = ["config.toml", "config.json", "config.yaml", "config.yml"]
+
+# Orders of linting and formatting in "both" mode, for the "order" config
+RUN_ORDERS = ["lint-first", "format-first"]
+
+# List keys combined rather than replaced when one config is layered over another
+ADDITIVE_CONFIG_KEYS = ["disable", "ignore", "sensitive"]
+
+
+def import_parser(module_names: List[str], requirement: str) -> Any:
+    """Import the first available parser module, for config formats outside the stdlib"""
+    for name in module_names:
+        try:
+            return importlib.import_module(name)
+        except ImportError:
+            continue
+    raise ValueError(f"reading it needs {requirement}")
+
+
+def read_config_file(config_file: Path) -> Dict[str, Any]:
+    """Parse a config file according to its format"""
+    text = config_file.read_text()
+
+    if config_file.suffix == ".toml":
+        toml = import_parser(["tomllib", "tomli"], "Python 3.11+ or the tomli package")
+        config = toml.loads(text)
+    elif config_file.suffix in [".yaml", ".yml"]:
+        yaml = import_parser(["yaml"], "the PyYAML package")
+        config = yaml.safe_load(text)
+    else:
+        config = json.loads(text)
+
+    if not isinstance(config, dict):
+        return {}
+    # "disabled_tools" is another name for "disable"
+    if isinstance(config.get("disabled_tools"), list):
+        disabled = list(config.get("disable", []))
+        renamed = config.pop("disabled_tools")
+        config["disable"] = disabled + [tool for tool in renamed if tool not in disabled]
+    return config
+
+
+# Parsed config files, with the modification time each was read at, so a resident daemon
+# only parses a file again once it has changed
+_config_file_cache: Dict[Path, Tuple[float, Dict[str, Any]]] = {}
+
+
+def read_cached_config_file(config_file: Path) -> Dict[str, Any]:
+    """Parse a config file, reusing the last parse while the file is unchanged"""
+    mtime = config_file.stat().st_mtime
+    cached = _config_file_cache.get(config_file)
+    if cached is None or cached[0] != mtime:
+        cached = (mtime, read_config_file(config_file))
+        _config_file_cache[config_file] = cached
+    # Callers may adjust the config they get, which mustn't leak into later runs
+    return copy.deepcopy(cached[1])
+
+
+def find_config_file(start_path: str = ".") -> Optional[Path]:
+    """Find the nearest config file, searching up the directory tree"""
     current_path = Path(start_path).resolve()
 
-    # Search up directory tree for .taidy.json
     for path in [current_path] + list(current_path.parents):
-        config_file = path / ".taidy.json"
-        if config_file.exists():
+        for name in CONFIG_FILES:
+            if (path / name).is_file():
+                return path / name
+
+    return None
+
+
+def user_config_dir() -> Path:
+    """Get the directory of the user-level config, following the XDG base directory spec"""
+    config_home = os.environ.get("XDG_CONFIG_HOME") or os.path.expanduser("~/.config")
+    return Path(config_home) / "taidy"
+
+
+# The user-level config, read once per run
+_user_config: Optional[Dict[str, Any]] = None
+
+
+def load_user_config() -> Dict[str, Any]:
+    """Load the user's personal defaults from $XDG_CONFIG_HOME/taidy/config.toml"""
+    global _user_config
+    if _user_config is None:
+        _user_config = {}
+        for name in USER_CONFIG_FILES:
+            config_file = user_config_dir() / name
+            if not config_file.is_file():
+                continue
             try:
-                with open(config_file, "r") as f:
-                    config = json.load(f) or {}
-                    return config
+                _user_config = read_config_file(config_file)
             except Exception as e:
                 logger.warning(f"Failed to parse {config_file}: {e}")
-                return {}
+            break
+    return _user_config
+
+
+def merge_config(base: Dict[str, Any], override: Dict[str, Any]) -> Dict[str, Any]:
+    """Layer one config over another; tables such as "args" are merged key by key, and
+    lists that only ever add restrictions, such as "disable", are combined"""
+    merged = dict(base)
+    for key, value in override.items():
+        if isinstance(value, dict) and isinstance(merged.get(key), dict):
+            merged[key] = dict(merged[key], **value)
+        elif key in ADDITIVE_CONFIG_KEYS and isinstance(merged.get(key), list):
+            merged[key] = merged[key] + [item for item in value if item not in merged[key]]
+        else:
+            merged[key] = value
+    return merged
+
+
+# The preset chosen with --preset, which takes precedence over any config's "preset" key
+selected_preset: Optional[str] = None
+
+
+def select_preset(name: Optional[str]) -> None:
+    """Use a preset for every config loaded from now on, whatever the configs say"""
+    global selected_preset
+    selected_preset = name
+
+
+# Tools skipped with --skip-tool, added to every config's "disable" list
+skipped_tools: List[str] = []
+
+
+def skip_tools(names: List[str]) -> None:
+    """Disable tools in every config loaded from now on, whatever the configs say"""
+    global skipped_tools
+    skipped_tools = list(names)
+
+
+def layer_config(user_config: Dict[str, Any], project_config: Dict[str, Any]) -> Dict[str, Any]:
+    """Stack the user-level config, the selected preset, the project's config and the
+    tools skipped with --skip-tool"""
+    name = selected_preset or project_config.get("preset") or user_config.get("preset")
+    base = user_config
+    if name:
+        try:
+            base = merge_config(user_config, preset_config(name))
+        except ValueError as e:
+            logger.warning(str(e))
+    return merge_config(merge_config(base, project_config), {"disable": skipped_tools})
+
+
+# Where project config is searched for from while the files being processed are an
+# unpacked archive, whose own config files could name commands to run and so are ignored
+_config_search_path: Optional[str] = None
+
+
+def load_config(start_path: str = ".") -> Dict[str, Any]:
+    """Load the nearest project config file, searching up the directory tree, over any
+    preset and the user-level config"""
+    user_config = load_user_config()
+    config_file = find_config_file(_config_search_path or start_path)
+    if config_file is None:
+        return layer_config(user_config, {})
+
+    try:
+        return layer_config(user_config, read_cached_config_file(config_file))
+    except Exception as e:
+        logger.warning(f"Failed to parse {config_file}: {e}")
+        return layer_config(user_config, {})
+
+
+def config_start_path(files: List[str]) -> str:
+    """Get the directory to search for config from: the deepest one containing every target"""
+    existing = [os.path.abspath(f) for f in files if os.path.exists(f)]
+    if not existing:
+        return "."
+    return os.path.commonpath(existing)
+
+
+def find_project_root(start_path: str = ".") -> Path:
+    """Find the project root: the nearest directory with a config file, else the git root"""
+    current_path = Path(start_path).resolve()
+    if current_path.is_file():
+        current_path = current_path.parent
+
+    config_file = find_config_file(str(current_path))
+    if config_file is not None:
+        return config_file.parent
+
+    return find_git_root(current_path) or current_path
+
+
+# Lock guarding reads and writes of the on-disk cache
+cache_lock = threading.Lock()
+
+
+def get_cache_path(start_path: str = ".") -> Path:
+    """Get the path of the per-project cache file"""
+    return find_project_root(start_path) / ".taidy" / "cache.json"
+
+
+def load_cache(start_path: str = ".") -> Dict[str, Any]:
+    """Load the per-project cache, returning an empty cache if missing or unreadable"""
+    cache_path = get_cache_path(start_path)
+    if not cache_path.exists():
+        return {}
+
+    try:
+        with open(cache_path, "r") as f:
+            cache = json.load(f)
+            return cache if isinstance(cache, dict) else {}
+    except Exception as e:
+        logger.debug(f"Failed to read cache {cache_path}: {e}")
+        return {}
+
+
+def save_cache(cache: Dict[str, Any], start_path: str = ".") -> None:
+    """Write the per-project cache, ignoring failures (the cache is only an optimisation)"""
+    cache_path = get_cache_path(start_path)
+    try:
+        if not cache_path.parent.exists():
+            cache_path.parent.mkdir(parents=True)
+            # Keep taidy's state out of version control, like .ruff_cache does
+            (cache_path.parent / ".gitignore").write_text("*\n")
+        with open(cache_path, "w") as f:
+            json.dump(cache, f, indent=2, sort_keys=True)
+    except Exception as e:
+        logger.debug(f"Failed to write cache {cache_path}: {e}")
+
+
+def timing_key(tool: str, kind: str) -> str:
+    """Get the cache key for a tool's timings, kept apart for each kind of run, as ruff
+    takes longer to lint than to format the same files"""
+    return f"{tool} {kind}"
+
+
+def record_tool_timings(
+    durations: List[Tuple[Tuple[str, Tuple[str, ...]], List[str], float]],
+    batch_kinds: Dict[Tuple[str, Tuple[str, ...]], str],
+) -> None:
+    """Fold how long each tool took per file into running averages in the cache"""
+    with cache_lock:
+        cache = load_cache()
+        timings = cache.setdefault("tool_timings", {})
+        for cmd_signature, covered, elapsed in durations:
+            key = timing_key(signature_tool_name(cmd_signature), batch_kinds[cmd_signature])
+            per_file = elapsed / max(len(covered), 1)
+            entry = timings.get(key, {"runs": 0, "seconds_per_file": 0.0})
+            runs = entry["runs"] + 1
+            entry["seconds_per_file"] = entry["seconds_per_file"] + (
+                (per_file - entry["seconds_per_file"]) / runs
+            )
+            entry["runs"] = runs
+            timings[key] = entry
+        save_cache(cache)
+
+
+def signature_key(cmd_signature: Tuple[str, Tuple[str, ...]]) -> str:
+    """Get the cache key for a batched command signature"""
+    cmd, base_args = cmd_signature
+    return " ".join([cmd] + list(base_args))
+
+
+def file_mtime(file: str) -> Optional[float]:
+    """Get a file's modification time, or None if it can't be read"""
+    try:
+        return os.path.getmtime(file)
+    except OSError:
+        return None
+
+
+def record_checkpoint(cmd_signature: Tuple[str, Tuple[str, ...]], files: List[str]) -> None:
+    """Record files a command found clean, so an interrupted run can resume past them"""
+    with cache_lock:
+        cache = load_cache()
+        completed = cache.setdefault("checkpoint", {}).setdefault(signature_key(cmd_signature), {})
+        for file in files:
+            completed[str(Path(file).resolve())] = file_mtime(file)
+        save_cache(cache)
+
+
+def clear_checkpoint() -> None:
+    """Remove the checkpoint once a run has finished"""
+    with cache_lock:
+        cache = load_cache()
+        if cache.pop("checkpoint", None) is not None:
+            save_cache(cache)
+
+
+def is_checkpointed(
+    checkpoint: Dict[str, Dict[str, Any]], cmd_signature: Tuple[str, Tuple[str, ...]], file: str
+) -> bool:
+    """Check whether a command already found a file clean and the file hasn't changed since"""
+    completed = checkpoint.get(signature_key(cmd_signature), {})
+    recorded = completed.get(str(Path(file).resolve()))
+    return recorded is not None and recorded == file_mtime(file)
+
+
+def custom_command(tool: CustomTool) -> LinterCommand:
+    """Make a command from a tool in the config's "tools" table"""
+    cmd, args = tool.command[0], tool.command[1:]
+    return LinterCommand(
+        available=lambda: is_command_available(cmd),
+        command=lambda files: (cmd, args + files),
+    )
+
+
+def command_tool_name(linter_cmd: LinterCommand) -> str:
+    """Get the name of the tool a command runs, looking through runners like uvx and npx"""
+    cmd, args = linter_cmd.command([])
+    return signature_tool_name((cmd, tuple(args)))
+
+
+# taidy's own check for merge conflict markers, run on every file it lints
+CONFLICT_CHECK = (sys.executable, ("-m", "taidy.conflicts"))
+# taidy's own check for inconsistent indentation, run on files no formatter can fix
+INDENTATION_CHECK = (sys.executable, ("-m", "taidy.indentation"))
+
+
+def is_builtin(linter_cmd: LinterCommand) -> bool:
+    """Check whether a command is one of taidy's own checks, run with its interpreter"""
+    return linter_cmd.command([])[0] == sys.executable
+
+
+def signature_tool_name(cmd_signature: Tuple[str, Tuple[str, ...]]) -> str:
+    """Get the name of the tool a batch runs, looking through runners like uvx and npx,
+    and naming taidy's own checkers, run with its interpreter, after their modules"""
+    cmd, args = cmd_signature
+    if cmd in ["uvx", "npx", "bunx"] and args:
+        return args[0]
+    if cmd == sys.executable and args[:1] == ("-m",) and len(args) > 1:
+        return args[1]
+    return cmd
+
+
+# Commands that run the tool named by their first argument
+RUNNERS = ["uvx", "npx", "bunx"]
+
+
+def config_keys(linter_cmd: LinterCommand) -> List[str]:
+    """Get the keys config tables use for a command: its tool name, then "tool subcommand" """
+    cmd, args = linter_cmd.command([])
+    tool = command_tool_name(linter_cmd)
+    tool_args = args[1:] if cmd in RUNNERS and args else args
+
+    keys = [tool]
+    if tool_args and not tool_args[0].startswith("-"):
+        keys.append(f"{tool} {tool_args[0]}")
+    return keys
 
-    return {}
+
+def configured_args(linter_cmd: LinterCommand, config: Dict[str, Any], kind: str) -> List[str]:
+    """Get the arguments the config adds to a command, from "args" and then "lint_args" or
+    "format_args", by tool name or "tool subcommand" key"""
+    return [
+        str(arg)
+        for table in ["args", f"{kind}_args"]
+        for key in config_keys(linter_cmd)
+        if isinstance(config.get(table, {}).get(key), list)
+        for arg in config[table][key]
+    ]
+
+
+def command_args(
+    linter_cmd: LinterCommand,
+    args: List[str],
+    files: List[str],
+    config: Dict[str, Any],
+    kind: str,
+) -> List[str]:
+    """Apply the config's arguments to a command's built-in ones, which may include files.
+
+    A {"replace": [...]} entry in "args", "lint_args" or "format_args" takes the place of
+    the built-in arguments, except for the runner's tool name and, under a "tool
+    subcommand" key, the subcommand; the files are kept at the end. The last such entry
+    wins, and the lists of the other entries are still added.
+    """
+    cmd = linter_cmd.command([])[0]
+    for table in ["args", f"{kind}_args"]:
+        for key in config_keys(linter_cmd):
+            entry = config.get(table, {}).get(key)
+            if entry is None or isinstance(entry, list):
+                continue
+            if not isinstance(entry, dict) or not isinstance(entry.get("replace"), list):
+                logger.warning(
+                    f'Ignoring "{table}" for {key}: expected a list or {{"replace": [...]}}'
+                )
+                continue
+            kept = (1 if cmd in RUNNERS else 0) + (1 if " " in key else 0)
+            replacement = [str(arg) for arg in entry["replace"]]
+            args = args[:kept] + replacement + [arg for arg in args[kept:] if arg in files]
+    return args + configured_args(linter_cmd, config, kind)
+
+
+def structured_output_args(linter_cmd: LinterCommand) -> List[str]:
+    """Get the arguments that make a command print JSON, if taidy can parse it"""
+    for key in reversed(config_keys(linter_cmd)):
+        if key in STRUCTURED_OUTPUT_ARGS:
+            return STRUCTURED_OUTPUT_ARGS[key]
+    return []
+
+
+def success_criteria(
+    linter_cmd: LinterCommand, configured: Dict[str, Any], strict: Optional[bool] = None
+) -> SuccessCriteria:
+    """Get how to judge a command's runs, from the config's "success" table or the built-in
+    criteria, preferring a "tool subcommand" entry to a tool-wide one. --strict and
+    --no-strict decide whether findings alone fail a run."""
+    criteria = SuccessCriteria()
+    for key in reversed(config_keys(linter_cmd)):
+        if key in configured:
+            try:
+                criteria = parse_criteria(key, configured[key])
+                break
+            except ValueError as e:
+                logger.warning(f"Ignoring {e}")
+        if key in BUILTIN_CRITERIA:
+            criteria = BUILTIN_CRITERIA[key]
+            break
+    if strict is not None:
+        criteria = replace(criteria, fail_on_findings=strict)
+    return criteria
+
+
+def conditions_met(
+    linter_cmd: LinterCommand, configured: Dict[str, Any], context: ConditionContext
+) -> bool:
+    """Check a command's built-in conditions and those in the config's "when" table, keyed
+    like "args". Malformed conditions are ignored with a warning."""
+    conditions = list(linter_cmd.conditions)
+    for key in config_keys(linter_cmd):
+        conditions += configured.get(key, [])
+
+    for condition in conditions:
+        try:
+            if not evaluate_condition(condition, context):
+                return False
+        except ValueError as e:
+            logger.warning(f"Ignoring {e}")
+    return True
+
+
+def force_tools(commands: List[LinterCommand], tools: List[str]) -> List[LinterCommand]:
+    """Narrow a chain to the tools given with --tool, if it has any of them, so nothing
+    else in it is tried; a chain without them is left as it is"""
+    forced = [linter_cmd for linter_cmd in commands if command_tool_name(linter_cmd) in tools]
+    return forced or commands
+
+
+def unknown_tools(tools: List[str]) -> List[str]:
+    """Get the names given with --tool or --skip-tool that aren't in any chain, nor the
+    conflict marker check"""
+    known = {
+        command_tool_name(linter_cmd)
+        for tool_map in [LINTER_MAP, FORMATTER_MAP, IMPORTS_MAP, FIX_MAP, SPELL_MAP]
+        for commands in tool_map.values()
+        for linter_cmd in commands
+    }
+    known.add(signature_tool_name(CONFLICT_CHECK))
+    known.add(signature_tool_name(INDENTATION_CHECK))
+    return [tool for tool in tools if tool not in known]
+
+
+def apply_preferences(commands: List[LinterCommand], prefer: List[str]) -> List[LinterCommand]:
+    """Move commands for the config's preferred tools to the front, in preference order"""
+    if not prefer:
+        return commands
+
+    def rank(linter_cmd: LinterCommand) -> int:
+        name = command_tool_name(linter_cmd)
+        return prefer.index(name) if name in prefer else len(prefer)
+
+    return sorted(commands, key=rank)
+
+
+def order_by_speed(
+    commands: List[LinterCommand], timings: Dict[str, Dict[str, Any]], kind: str
+) -> List[LinterCommand]:
+    """Reorder equally capable commands so the tool measured fastest at this kind of run
+    comes first.
+
+    Only commands that share a capability swap places; everything else keeps its position
+    in the chain. Tools without recorded timings go ahead of the measured ones, keeping
+    their order, so each available tool is tried and timed once before the fastest wins.
+    """
+    ordered = list(commands)
+
+    def key(linter_cmd: LinterCommand) -> str:
+        return timing_key(command_tool_name(linter_cmd), kind)
+
+    capabilities = {c.capability for c in commands if c.capability is not None}
+    for capability in capabilities:
+        positions = [i for i, c in enumerate(ordered) if c.capability == capability]
+        untried = [ordered[i] for i in positions if key(ordered[i]) not in timings]
+        measured = sorted(
+            (ordered[i] for i in positions if key(ordered[i]) in timings),
+            key=lambda c: float(timings[key(c)]["seconds_per_file"]),
+        )
+        for position, linter_cmd in zip(positions, untried + measured):
+            ordered[position] = linter_cmd
+
+    return ordered
 
 
 def should_ignore_file(file_path: Path, ignore_patterns: List[str]) -> bool:
@@ -205,6 +2071,18 @@ def should_ignore_file(file_path: Path, ignore_patterns: List[str]) -> bool:
     return False
 
 
+def is_sensitive_file(file_path: Path, extra_patterns: List[str]) -> bool:
+    """Check if a file looks like it holds secrets, by name"""
+    return any(
+        fnmatch.fnmatch(file_path.name, pattern) for pattern in SENSITIVE_PATTERNS + extra_patterns
+    )
+
+
+def manifest_path(files: List[str], name: str) -> str:
+    """Get the manifest a per-file command runs on, defaulting to the current directory's"""
+    return files[0] if files else name
+
+
 def _get_trufflehog_command(files: List[str]) -> Tuple[str, List[str]]:
     """Get the appropriate trufflehog command based on git repository status."""
     # Check if we're in a git repository
@@ -222,8 +2100,255 @@ def _get_trufflehog_command(files: List[str]) -> Tuple[str, List[str]]:
         return ("trufflehog", ["filesystem", "--no-update", "--fail", "--log-level=-1"] + files)
 
 
-def discover_files_in_directory(directory_path: str) -> List[str]:
-    """Discover all supported files in a directory recursively"""
+def filter_taidyignored(files: List[str], root: Path) -> List[str]:
+    """Remove files matched by the project's .taidyignore"""
+    rules = load_ignore_file(root)
+    if not rules:
+        return files
+
+    kept = []
+    for file in files:
+        try:
+            relative_path = Path(file).resolve().relative_to(root).as_posix()
+        except ValueError:
+            # Files outside the project aren't covered by its .taidyignore
+            kept.append(file)
+            continue
+        if not is_ignored(relative_path, rules):
+            kept.append(file)
+    return kept
+
+
+# Well-known files whose names, rather than their extensions, say what they hold
+FILENAME_KEYS: Dict[str, str] = {
+    "Dockerfile": "dockerfile",
+    "Containerfile": "dockerfile",
+    "Makefile": "makefile",
+    "makefile": "makefile",
+    "GNUmakefile": "makefile",
+    "Jenkinsfile": "jenkinsfile",
+    "BUILD": "bazel",
+    "BUILD.bazel": "bazel",
+    "WORKSPACE": "bazel",
+    "WORKSPACE.bazel": "bazel",
+    "MODULE.bazel": "bazel",
+    # Ruby DSLs
+    "Rakefile": ".rb",
+    "Gemfile": ".rb",
+    "Guardfile": ".rb",
+    "Podfile": ".rb",
+    "Vagrantfile": ".rb",
+    "Brewfile": ".rb",
+}
+
+
+# Interpreters named on a script's #! line, with any version suffix such as python3.11
+# removed, and the extension key of the language they run
+SHEBANG_KEYS: Dict[str, str] = {
+    "sh": ".sh",
+    "bash": ".sh",
+    "dash": ".sh",
+    "ksh": ".sh",
+    "zsh": ".zsh",
+    "python": ".py",
+    "node": ".js",
+    "nodejs": ".js",
+    "ts-node": ".ts",
+    "ruby": ".rb",
+    "php": ".php",
+}
+
+
+def shebang_key(file_path: Path) -> Optional[str]:
+    """Get the extension key of the interpreter a script's #! line names, such as .sh for
+    `#!/usr/bin/env bash`, or None if it has no #! line or an unknown interpreter"""
+    try:
+        with open(long_path(str(file_path)), "rb") as f:
+            first_line = f.readline(256)
+    except OSError:
+        return None
+    if not first_line.startswith(b"#!"):
+        return None
+
+    words = first_line[2:].decode(errors="replace").split()
+    if words and os.path.basename(words[0]) == "env":
+        # Skip env's own options, such as -S, and any variables it sets
+        words = [word for word in words[1:] if not word.startswith("-") and "=" not in word]
+    if not words:
+        return None
+    interpreter = os.path.basename(words[0]).rstrip("0123456789.")
+    return SHEBANG_KEYS.get(interpreter)
+
+
+# Extensions that say nothing about a file's language, so its #! line or a modeline has
+# to: none at all, and those of templates, examples and fragments
+UNTELLING_EXTENSIONS = ["", ".in", ".inc", ".conf", ".cfg", ".tmpl", ".tpl", ".dist", ".example"]
+
+# Vim's `vim: set ft=python :` (also after vi: or ex:), within a file's first or last lines
+VIM_MODELINE_PATTERN = re.compile(
+    r"(?:^|\s)(?:vi|vim|ex):\s*(?:set?\s+)?(?:\S*[\s:])*?(?:ft|filetype)=(?P<mode>[\w+-]+)"
+)
+# Emacs's `-*- mode: sh -*-` or just `-*- sh -*-`, on a file's first line (or its second,
+# after a #! line)
+EMACS_MODE_PATTERN = re.compile(
+    r"-\*-\s*(?:.*?\bmode:\s*(?P<mode>[\w+-]+).*?|(?P<short>[\w+-]+))\s*-\*-", re.IGNORECASE
+)
+
+# Modes editors name that aren't taidy's language names or aliases
+MODELINE_ALIASES: Dict[str, str] = {
+    "shell-script": "shell",
+    "python3": "python",
+    "js2": "javascript",
+    "makefile-gmake": "makefile",
+    "hcl": "terraform",
+    "conf-toml": "toml",
+}
+
+# How many lines at either end of a file Vim reads modelines from
+MODELINE_LINES = 5
+
+
+def modeline_key(file_path: Path) -> Optional[str]:
+    """Get the extension key of the language a Vim modeline or Emacs mode line names, or
+    None if the file has neither or names a language taidy has no chain for"""
+    try:
+        with open(long_path(str(file_path)), "rb") as f:
+            head = f.read(4096)
+            f.seek(max(f.seek(0, os.SEEK_END) - 4096, len(head)))
+            tail = f.read()
+    except OSError:
+        return None
+    if b"\0" in head:
+        return None
+
+    head_lines = head.decode(errors="replace").splitlines()[:MODELINE_LINES]
+    tail_lines = (head + tail).decode(errors="replace").splitlines()[-MODELINE_LINES:]
+    modes = []
+    for line in head_lines[:2]:
+        match = EMACS_MODE_PATTERN.search(line)
+        if match:
+            modes.append(match.group("mode") or match.group("short"))
+    for line in head_lines + tail_lines:
+        match = VIM_MODELINE_PATTERN.search(line)
+        if match:
+            modes.append(match.group("mode"))
+
+    for mode in modes:
+        name = mode.lower()
+        language = LANGUAGE_ALIASES.get(name, MODELINE_ALIASES.get(name, name))
+        if language in LANGUAGES:
+            return LANGUAGES[language][0]
+    return None
+
+
+def get_extension_key(file_path: Path) -> str:
+    """Map a file to the key used in LINTER_MAP and FORMATTER_MAP"""
+    ext = file_path.suffix.lower()
+
+    # Special case: Justfile files
+    if file_path.name.lower() in ["justfile", "justfile.just"]:
+        return "justfile"
+
+    if file_path.name in FILENAME_KEYS:
+        return FILENAME_KEYS[file_path.name]
+
+    # Variants such as Dockerfile.dev, whose suffix says nothing about the language,
+    # and node18.Dockerfile
+    if file_path.name.startswith("Dockerfile.") or ext == ".dockerfile":
+        return "dockerfile"
+
+    # Special case: GitHub Actions workflow files
+    if ext in [".yml", ".yaml"] and ".github/workflows" in str(file_path):
+        return ".github-workflow"
+
+    # TypeScript declarations are linted but, often being generated, never formatted
+    if file_path.name.lower().endswith(".d.ts"):
+        return ".d.ts"
+
+    # Scripts and templates whose extensions don't tell, such as bin/deploy, are known by
+    # their #! line, or failing that a Vim or Emacs modeline
+    if ext in UNTELLING_EXTENSIONS and not file_path.name.startswith("."):
+        return shebang_key(file_path) or modeline_key(file_path) or ext
+
+    return ext
+
+
+def mapped_extension(ext: str, overrides: Dict[str, str]) -> Optional[str]:
+    """Apply the config's "extensions" table to an extension key. A file can be mapped to
+    another extension (".mjs": ".js"), a language (".pyi": "python") or "skip", for which
+    None is returned as no tool should see the file."""
+    target = overrides.get(ext, ext)
+    if target == "skip":
+        return None
+    language = LANGUAGE_ALIASES.get(target.lower(), target.lower())
+    if not target.startswith(".") and language in LANGUAGES:
+        return LANGUAGES[language][0]
+    return target
+
+
+def walk_files(
+    directory: Path, skip_directory: Callable[[str], bool], follow_symlinks: bool = False
+) -> Iterator[Path]:
+    """Yield the files under a directory, however deep, without recursing in Python.
+
+    Directories whose names skip_directory accepts aren't entered. Symlinked directories
+    are only entered when following symlinks, and then never twice, so a link back to
+    an ancestor can't loop forever.
+    """
+    visited: Set[Tuple[int, int]] = set()
+    pending = [directory]
+    while pending:
+        current = pending.pop()
+        try:
+            if follow_symlinks:
+                stat = os.stat(long_path(str(current)))
+                if (stat.st_dev, stat.st_ino) in visited:
+                    logger.warning(f"Skipping {printable_path(str(current))}: symlink loop")
+                    continue
+                visited.add((stat.st_dev, stat.st_ino))
+            with os.scandir(long_path(str(current))) as scan:
+                entries = sorted(scan, key=lambda entry: entry.name)
+        except OSError as e:
+            logger.debug(f"Failed to list {printable_path(str(current))}: {e}")
+            continue
+
+        subdirectories = []
+        for entry in entries:
+            path = current / entry.name
+            try:
+                if entry.is_dir(follow_symlinks=follow_symlinks):
+                    if not skip_directory(entry.name):
+                        subdirectories.append(path)
+                elif entry.is_file():
+                    yield path
+            except OSError:
+                continue
+        # Reversed, so directories are listed in name order as they come off the stack
+        pending.extend(reversed(subdirectories))
+
+
+def is_text_file(file_path: Path) -> bool:
+    """Check whether a file looks like text, judging by its first 8k as git does"""
+    try:
+        with open(long_path(str(file_path)), "rb") as f:
+            return b"\0" not in f.read(8192)
+    except OSError:
+        return False
+
+
+def discover_files_in_directory(
+    directory_path: str,
+    use_gitignore: bool = True,
+    follow_symlinks: bool = False,
+    all_text: bool = False,
+) -> List[str]:
+    """Discover all supported files in a directory recursively.
+
+    Inside a git repository only tracked files that git doesn't ignore are found,
+    unless use_gitignore is False. Symlinked directories are followed only with
+    follow_symlinks, tracked ones included. With all_text, every text file is found,
+    whatever its type, for checks such as spelling that apply to any text.
+    """
     supported_extensions: Set[str] = set()
     supported_extensions.update(LINTER_MAP.keys())
     supported_extensions.update(FORMATTER_MAP.keys())
@@ -231,6 +2356,9 @@ def discover_files_in_directory(directory_path: str) -> List[str]:
     # Load config and get ignore patterns
     config = load_config(directory_path)
     config_ignores = config.get("ignore", [])
+    supported_extensions.update(
+        ext for ext, target in config.get("extensions", {}).items() if target != "skip"
+    )
 
     # Common directories to ignore (defaults)
     default_ignore_patterns = [
@@ -248,6 +2376,7 @@ def discover_files_in_directory(directory_path: str) -> List[str]:
         ".mypy_cache",
         ".ruff_cache",
         ".coverage",
+        ".taidy",
     ]
 
     # Combine default and config ignore patterns
@@ -258,14 +2387,35 @@ def discover_files_in_directory(directory_path: str) -> List[str]:
 
     # Check if directory is in a git repository and get ignored files
     git_ignored_files = set()
-    if is_git_repository(directory):
+    tracked_files: Optional[List[Path]] = None
+    if use_gitignore and is_git_repository(directory):
         git_root = find_git_root(directory)
         if git_root:
             git_ignored_files = get_git_ignored_files(git_root)
 
-    for file_path in directory.rglob("*"):
-        # Skip if it's not a file
-        if not file_path.is_file():
+        # Inside a git repository only tracked files are candidates, matching prettier and ruff.
+        # Files outside a sparse checkout are listed but absent, and skipped below
+        tracked_files = get_git_tracked_files(directory)
+
+    # Ignored directories such as node_modules aren't walked at all
+    def skip_directory(name: str) -> bool:
+        return any(fnmatch.fnmatch(name, pattern) for pattern in all_ignore_patterns)
+
+    candidates: Iterable[Path] = walk_files(directory, skip_directory, follow_symlinks)
+    if tracked_files is not None:
+        candidates = tracked_files
+        if follow_symlinks:
+            # git records a symlinked directory as one entry, so its files are walked here
+            linked = [
+                walk_files(path, skip_directory, follow_symlinks)
+                for path in tracked_files
+                if path.is_symlink() and path.is_dir()
+            ]
+            candidates = itertools.chain(tracked_files, *linked)
+
+    for file_path in candidates:
+        # Skip if it's not a file (this also skips tracked files deleted from the worktree)
+        if not os.path.isfile(long_path(str(file_path))):
             continue
 
         # Skip if file should be ignored by taidy patterns
@@ -280,8 +2430,12 @@ def discover_files_in_directory(directory_path: str) -> List[str]:
         ext = file_path.suffix.lower()
         is_supported = ext in supported_extensions
 
-        # Special case: Justfile files
-        if not is_supported and file_path.name.lower() in ["justfile", "justfile.just"]:
+        # Special case: files known by their names, such as justfiles and Dockerfiles
+        if not is_supported and get_extension_key(file_path) in supported_extensions:
+            is_supported = True
+
+        # Special case: dependency manifests such as go.mod
+        if not is_supported and file_path.name in MANIFEST_FILES:
             is_supported = True
 
         # Special case: GitHub Actions workflow files
@@ -322,12 +2476,15 @@ def discover_files_in_directory(directory_path: str) -> List[str]:
             if ext in security_extensions or file_path.name.startswith(".env"):
                 is_supported = True
 
+        if not is_supported and all_text and is_text_file(file_path):
+            is_supported = True
+
         if not is_supported:
             continue
 
         discovered_files.append(str(file_path))
 
-    return sorted(discovered_files)
+    return sorted(filter_taidyignored(discovered_files, find_project_root(directory_path)))
 
 
 # LinterConfig maps file extensions to sequences of linter commands to try in order
@@ -336,12 +2493,22 @@ LINTER_MAP: Dict[str, List[LinterCommand]] = {
         LinterCommand(
             available=lambda: is_command_available("ruff"),
             command=lambda files: ("ruff", ["check", "--quiet"] + files),
+            stdin_command=lambda name: (
+                "ruff",
+                ["check", "--quiet", "--stdin-filename", name, "-"],
+            ),
             supports_directories=True,
+            capability="python-lint",
         ),
         LinterCommand(
             available=lambda: is_command_available("uvx"),
             command=lambda files: ("uvx", ["ruff", "check", "--quiet"] + files),
+            stdin_command=lambda name: (
+                "uvx",
+                ["ruff", "check", "--quiet", "--stdin-filename", name, "-"],
+            ),
             supports_directories=True,
+            capability="python-lint",
         ),
         LinterCommand(
             available=lambda: is_command_available("black"),
@@ -350,20 +2517,28 @@ LINTER_MAP: Dict[str, List[LinterCommand]] = {
         LinterCommand(
             available=lambda: is_command_available("flake8"),
             command=lambda files: ("flake8", ["--quiet"] + files),
+            capability="python-lint",
         ),
         LinterCommand(
             available=lambda: is_command_available("pylint"),
             command=lambda files: ("pylint", ["--quiet"] + files),
+            capability="python-lint",
         ),
         LinterCommand(
             available=lambda: is_command_available("python"),
             command=lambda files: ("python", ["-m", "py_compile"] + files),
         ),
+        # The interpreter running taidy is always there, even when nothing else is
+        LinterCommand(
+            available=lambda: True,
+            command=lambda files: (sys.executable, ["-m", "taidy.syntax"] + files),
+        ),
     ],
     ".js": [
         LinterCommand(
             available=lambda: is_command_available("eslint"),
             command=lambda files: ("eslint", ["--quiet"] + files),
+            stdin_command=lambda name: ("eslint", ["--quiet", "--stdin", "--stdin-filename", name]),
         ),
         LinterCommand(
             available=lambda: is_command_available("prettier"),
@@ -381,6 +2556,7 @@ LINTER_MAP: Dict[str, List[LinterCommand]] = {
         LinterCommand(
             available=lambda: is_command_available("eslint"),
             command=lambda files: ("eslint", ["--quiet"] + files),
+            stdin_command=lambda name: ("eslint", ["--quiet", "--stdin", "--stdin-filename", name]),
         ),
         LinterCommand(
             available=lambda: is_command_available("prettier"),
@@ -394,10 +2570,13 @@ LINTER_MAP: Dict[str, List[LinterCommand]] = {
         LinterCommand(
             available=lambda: is_command_available("eslint"),
             command=lambda files: ("eslint", ["--quiet"] + files),
+            stdin_command=lambda name: ("eslint", ["--quiet", "--stdin", "--stdin-filename", name]),
         ),
         LinterCommand(
             available=lambda: is_command_available("tsc"),
             command=lambda files: ("tsc", ["--noEmit"] + files),
+            # Without a tsconfig.json, tsc checks with defaults that rarely match the project
+            conditions=["exists tsconfig.json"],
         ),
         LinterCommand(
             available=lambda: is_command_available("prettier"),
@@ -407,14 +2586,28 @@ LINTER_MAP: Dict[str, List[LinterCommand]] = {
             ),
         ),
     ],
+    ".d.ts": [
+        LinterCommand(
+            available=lambda: is_command_available("eslint"),
+            command=lambda files: ("eslint", ["--quiet"] + files),
+        ),
+        LinterCommand(
+            available=lambda: is_command_available("tsc"),
+            command=lambda files: ("tsc", ["--noEmit"] + files),
+            conditions=["exists tsconfig.json"],
+        ),
+    ],
     ".tsx": [
         LinterCommand(
             available=lambda: is_command_available("eslint"),
             command=lambda files: ("eslint", ["--quiet"] + files),
+            stdin_command=lambda name: ("eslint", ["--quiet", "--stdin", "--stdin-filename", name]),
         ),
         LinterCommand(
             available=lambda: is_command_available("tsc"),
             command=lambda files: ("tsc", ["--noEmit"] + files),
+            # Without a tsconfig.json, tsc checks with defaults that rarely match the project
+            conditions=["exists tsconfig.json"],
         ),
         LinterCommand(
             available=lambda: is_command_available("prettier"),
@@ -485,6 +2678,7 @@ LINTER_MAP: Dict[str, List[LinterCommand]] = {
         LinterCommand(
             available=lambda: is_command_available("rubocop"),
             command=lambda files: ("rubocop", ["--quiet"] + files),
+            stdin_command=lambda name: ("rubocop", ["--quiet", "--stdin", name]),
         ),
     ],
     ".php": [
@@ -602,6 +2796,79 @@ LINTER_MAP: Dict[str, List[LinterCommand]] = {
             supports_directories=True,
         ),
     ],
+    "go.mod": [
+        LinterCommand(
+            available=lambda: is_command_available("go"),
+            command=lambda files: (
+                "go",
+                ["-C", str(Path(manifest_path(files, "go.mod")).parent), "mod", "tidy", "-diff"],
+            ),
+            per_file=True,
+        ),
+    ],
+    "package.json": [
+        LinterCommand(
+            available=lambda: is_command_available("publint"),
+            command=lambda files: (
+                "publint",
+                [str(Path(manifest_path(files, "package.json")).parent)],
+            ),
+            per_file=True,
+        ),
+    ],
+    "pyproject.toml": [
+        LinterCommand(
+            available=lambda: is_command_available("validate-pyproject"),
+            command=lambda files: ("validate-pyproject", files),
+        ),
+    ],
+    "Cargo.toml": [
+        LinterCommand(
+            available=lambda: is_command_available("cargo"),
+            command=lambda files: (
+                "cargo",
+                ["verify-project", f"--manifest-path={manifest_path(files, 'Cargo.toml')}"],
+            ),
+            per_file=True,
+        ),
+    ],
+    ".editorconfig": [
+        LinterCommand(
+            available=lambda: is_command_available("editorconfig-checker"),
+            command=lambda files: ("editorconfig-checker", ["--format", "gcc"] + files),
+            supports_directories=True,
+        ),
+        LinterCommand(
+            available=lambda: is_command_available("ec"),
+            command=lambda files: ("ec", ["--format", "gcc"] + files),
+            supports_directories=True,
+        ),
+    ],
+    "dockerfile": [
+        LinterCommand(
+            available=lambda: is_command_available("hadolint"),
+            command=lambda files: ("hadolint", ["--no-color"] + files),
+        ),
+    ],
+    "makefile": [
+        LinterCommand(
+            available=lambda: is_command_available("checkmake"),
+            command=lambda files: ("checkmake", files),
+            per_file=True,
+        ),
+    ],
+    "jenkinsfile": [
+        LinterCommand(
+            available=lambda: is_command_available("npm-groovy-lint"),
+            command=lambda files: ("npm-groovy-lint", ["--noserver", "--failon", "error"] + files),
+        ),
+    ],
+    "bazel": [
+        LinterCommand(
+            available=lambda: is_command_available("buildifier"),
+            command=lambda files: ("buildifier", ["--mode=check", "--lint=warn"] + files),
+        ),
+    ],
 }
 
 # FormatterConfig maps file extensions to sequences of formatter commands to try in order
@@ -610,17 +2877,26 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
         LinterCommand(
             available=lambda: is_command_available("ruff"),
             command=lambda files: ("ruff", ["format", "--quiet"] + files),
+            stdin_command=lambda name: ("ruff", ["format", "--quiet", "--stdin-filename", name]),
             supports_directories=True,
+            capability="python-format",
         ),
         LinterCommand(
             available=lambda: is_command_available("uvx"),
             command=lambda files: ("uvx", ["ruff", "format", "--quiet"] + files),
+            stdin_command=lambda name: (
+                "uvx",
+                ["ruff", "format", "--quiet", "--stdin-filename", name],
+            ),
             supports_directories=True,
+            capability="python-format",
         ),
         LinterCommand(
             available=lambda: is_command_available("black"),
             command=lambda files: ("black", ["--quiet"] + files),
+            stdin_command=lambda name: ("black", ["--quiet", "--stdin-filename", name, "-"]),
             supports_directories=True,
+            capability="python-format",
         ),
     ],
     ".js": [
@@ -630,6 +2906,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "prettier",
                 ["--write", "--log-level", "error"] + files,
             ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -640,6 +2920,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "prettier",
                 ["--write", "--log-level", "error"] + files,
             ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -650,6 +2934,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "prettier",
                 ["--write", "--log-level", "error"] + files,
             ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -660,6 +2948,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "prettier",
                 ["--write", "--log-level", "error"] + files,
             ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -670,6 +2962,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "prettier",
                 ["--write", "--log-level", "error"] + files,
             ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -680,6 +2976,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "prettier",
                 ["--write", "--log-level", "error"] + files,
             ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -690,6 +2990,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "prettier",
                 ["--write", "--log-level", "error"] + files,
             ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -700,6 +3004,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "prettier",
                 ["--write", "--log-level", "error"] + files,
             ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -710,6 +3018,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "prettier",
                 ["--write", "--log-level", "error"] + files,
             ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -720,6 +3032,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "bunx",
                 ["prettier", "--write", "--plugin=@prettier/plugin-pug"] + files,
             ),
+            stdin_command=lambda name: (
+                "bunx",
+                ["prettier", "--plugin=@prettier/plugin-pug", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
         LinterCommand(
@@ -728,6 +3044,10 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
                 "npx",
                 ["prettier", "--write", "--plugin=@prettier/plugin-pug"] + files,
             ),
+            stdin_command=lambda name: (
+                "npx",
+                ["prettier", "--plugin=@prettier/plugin-pug", "--stdin-filepath", name],
+            ),
             supports_directories=True,
         ),
     ],
@@ -735,6 +3055,7 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
         LinterCommand(
             available=lambda: is_command_available("gofmt"),
             command=lambda files: ("gofmt", ["-w"] + files),
+            stdin_command=lambda name: ("gofmt", []),
             supports_directories=True,
         ),
     ],
@@ -742,6 +3063,7 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
         LinterCommand(
             available=lambda: is_command_available("rustfmt"),
             command=lambda files: ("rustfmt", ["--quiet"] + files),
+            stdin_command=lambda name: ("rustfmt", ["--quiet", "--emit", "stdout"]),
             supports_directories=True,
         ),
     ],
@@ -762,89 +3084,280 @@ FORMATTER_MAP: Dict[str, List[LinterCommand]] = {
         LinterCommand(
             available=lambda: is_command_available("shfmt"),
             command=lambda files: ("shfmt", ["-w"] + files),
+            stdin_command=lambda name: ("shfmt", ["-filename", name]),
         ),
         LinterCommand(
             available=lambda: is_command_available("beautysh"),
             command=lambda files: ("beautysh", files),
+            stdin_command=lambda name: ("beautysh", ["-"]),
         ),
     ],
     ".bash": [
         LinterCommand(
             available=lambda: is_command_available("shfmt"),
             command=lambda files: ("shfmt", ["-w"] + files),
+            stdin_command=lambda name: ("shfmt", ["-filename", name]),
+        ),
+        LinterCommand(
+            available=lambda: is_command_available("beautysh"),
+            command=lambda files: ("beautysh", files),
+            stdin_command=lambda name: ("beautysh", ["-"]),
         ),
+    ],
+    ".zsh": [
+        LinterCommand(
+            available=lambda: is_command_available("shfmt"),
+            command=lambda files: ("shfmt", ["-w"] + files),
+            stdin_command=lambda name: ("shfmt", ["-filename", name]),
+        ),
+        LinterCommand(
+            available=lambda: is_command_available("beautysh"),
+            command=lambda files: ("beautysh", files),
+            stdin_command=lambda name: ("beautysh", ["-"]),
+        ),
+    ],
+    ".yaml": [
+        LinterCommand(
+            available=lambda: is_command_available("prettier"),
+            command=lambda files: (
+                "prettier",
+                ["--write", "--log-level", "error"] + files,
+            ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
+            supports_directories=True,
+        ),
+    ],
+    ".yml": [
+        LinterCommand(
+            available=lambda: is_command_available("prettier"),
+            command=lambda files: (
+                "prettier",
+                ["--write", "--log-level", "error"] + files,
+            ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
+            supports_directories=True,
+        ),
+    ],
+    ".toml": [
+        LinterCommand(
+            available=lambda: is_command_available("taplo"),
+            command=lambda files: ("taplo", ["format"] + files),
+            stdin_command=lambda name: ("taplo", ["format", "-"]),
+        ),
+    ],
+    ".tf": [
+        LinterCommand(
+            available=lambda: is_command_available("terraform"),
+            command=lambda files: ("terraform", ["fmt"] + files),
+            stdin_command=lambda name: ("terraform", ["fmt", "-"]),
+        ),
+    ],
+    ".tfvars": [
+        LinterCommand(
+            available=lambda: is_command_available("terraform"),
+            command=lambda files: ("terraform", ["fmt"] + files),
+            stdin_command=lambda name: ("terraform", ["fmt", "-"]),
+        ),
+    ],
+    ".github-workflow": [
+        LinterCommand(
+            available=lambda: is_command_available("prettier"),
+            command=lambda files: (
+                "prettier",
+                ["--write", "--log-level", "error"] + files,
+            ),
+            stdin_command=lambda name: (
+                "prettier",
+                ["--log-level", "error", "--stdin-filepath", name],
+            ),
+            supports_directories=True,
+        ),
+    ],
+    "justfile": [
+        LinterCommand(
+            available=lambda: is_command_available("just"),
+            command=lambda files: ("just", ["--fmt", "--unstable"]),
+        ),
+    ],
+    "bazel": [
+        LinterCommand(
+            available=lambda: is_command_available("buildifier"),
+            command=lambda files: ("buildifier", files),
+        ),
+    ],
+}
+
+# Import organizers, for `taidy imports`
+IMPORTS_MAP: Dict[str, List[LinterCommand]] = {
+    ".py": [
+        LinterCommand(
+            available=lambda: is_command_available("ruff"),
+            command=lambda files: ("ruff", ["check", "--select", "I", "--fix", "--quiet"] + files),
+            supports_directories=True,
+        ),
+        LinterCommand(
+            available=lambda: is_command_available("uvx"),
+            command=lambda files: (
+                "uvx",
+                ["ruff", "check", "--select", "I", "--fix", "--quiet"] + files,
+            ),
+            supports_directories=True,
+        ),
+        LinterCommand(
+            available=lambda: is_command_available("isort"),
+            command=lambda files: ("isort", ["--quiet"] + files),
+            supports_directories=True,
+        ),
+    ],
+    ".go": [
+        LinterCommand(
+            available=lambda: is_command_available("goimports"),
+            command=lambda files: ("goimports", ["-w"] + files),
+            supports_directories=True,
+        ),
+    ],
+    # ESLint can't be limited to one rule's fixes, so import/order (from
+    # eslint-plugin-import) is switched on alongside the project's own rules
+    ".js": [
+        LinterCommand(
+            available=lambda: is_command_available("eslint"),
+            command=lambda files: ("eslint", ["--fix", "--rule", "import/order: error"] + files),
+        ),
+    ],
+    ".jsx": [
+        LinterCommand(
+            available=lambda: is_command_available("eslint"),
+            command=lambda files: ("eslint", ["--fix", "--rule", "import/order: error"] + files),
+        ),
+    ],
+    ".ts": [
+        LinterCommand(
+            available=lambda: is_command_available("eslint"),
+            command=lambda files: ("eslint", ["--fix", "--rule", "import/order: error"] + files),
+        ),
+    ],
+    ".tsx": [
+        LinterCommand(
+            available=lambda: is_command_available("eslint"),
+            command=lambda files: ("eslint", ["--fix", "--rule", "import/order: error"] + files),
+        ),
+    ],
+}
+
+
+# Linters' automatic fixes, for `taidy fix`; only fixes the tools consider safe are applied
+FIX_MAP: Dict[str, List[LinterCommand]] = {
+    ".py": [
+        LinterCommand(
+            available=lambda: is_command_available("ruff"),
+            command=lambda files: ("ruff", ["check", "--fix", "--quiet"] + files),
+            supports_directories=True,
+        ),
+        LinterCommand(
+            available=lambda: is_command_available("uvx"),
+            command=lambda files: ("uvx", ["ruff", "check", "--fix", "--quiet"] + files),
+            supports_directories=True,
+        ),
+    ],
+    ".js": [
         LinterCommand(
-            available=lambda: is_command_available("beautysh"),
-            command=lambda files: ("beautysh", files),
+            available=lambda: is_command_available("eslint"),
+            command=lambda files: ("eslint", ["--fix"] + files),
         ),
     ],
-    ".zsh": [
+    ".jsx": [
         LinterCommand(
-            available=lambda: is_command_available("shfmt"),
-            command=lambda files: ("shfmt", ["-w"] + files),
+            available=lambda: is_command_available("eslint"),
+            command=lambda files: ("eslint", ["--fix"] + files),
         ),
+    ],
+    ".ts": [
         LinterCommand(
-            available=lambda: is_command_available("beautysh"),
-            command=lambda files: ("beautysh", files),
+            available=lambda: is_command_available("eslint"),
+            command=lambda files: ("eslint", ["--fix"] + files),
         ),
     ],
-    ".yaml": [
+    ".tsx": [
         LinterCommand(
-            available=lambda: is_command_available("prettier"),
-            command=lambda files: (
-                "prettier",
-                ["--write", "--log-level", "error"] + files,
-            ),
-            supports_directories=True,
+            available=lambda: is_command_available("eslint"),
+            command=lambda files: ("eslint", ["--fix"] + files),
         ),
     ],
-    ".yml": [
+    ".css": [
         LinterCommand(
-            available=lambda: is_command_available("prettier"),
-            command=lambda files: (
-                "prettier",
-                ["--write", "--log-level", "error"] + files,
-            ),
-            supports_directories=True,
+            available=lambda: is_command_available("stylelint"),
+            command=lambda files: ("stylelint", ["--fix"] + files),
         ),
     ],
-    ".toml": [
+    ".scss": [
         LinterCommand(
-            available=lambda: is_command_available("taplo"),
-            command=lambda files: ("taplo", ["format"] + files),
+            available=lambda: is_command_available("stylelint"),
+            command=lambda files: ("stylelint", ["--fix"] + files),
         ),
     ],
-    ".tf": [
+    # -a rather than -A, which also applies the cops rubocop marks as unsafe
+    ".rb": [
         LinterCommand(
-            available=lambda: is_command_available("terraform"),
-            command=lambda files: ("terraform", ["fmt"] + files),
+            available=lambda: is_command_available("rubocop"),
+            command=lambda files: ("rubocop", ["-a", "--quiet"] + files),
         ),
     ],
-    ".tfvars": [
+    ".md": [
         LinterCommand(
-            available=lambda: is_command_available("terraform"),
-            command=lambda files: ("terraform", ["fmt"] + files),
+            available=lambda: is_command_available("markdownlint"),
+            command=lambda files: ("markdownlint", ["--fix"] + files),
         ),
     ],
-    ".github-workflow": [
+}
+
+
+# Spell checkers, for `taidy spell`; every file goes to the same chain whatever its language
+SPELL_MAP: Dict[str, List[LinterCommand]] = {
+    ".spell": [
         LinterCommand(
-            available=lambda: is_command_available("prettier"),
-            command=lambda files: (
-                "prettier",
-                ["--write", "--log-level", "error"] + files,
-            ),
+            available=lambda: is_command_available("typos"),
+            command=lambda files: ("typos", ["--format", "brief"] + files),
             supports_directories=True,
         ),
-    ],
-    "justfile": [
         LinterCommand(
-            available=lambda: is_command_available("just"),
-            command=lambda files: ("just", ["--fmt", "--unstable"]),
+            available=lambda: is_command_available("codespell"),
+            command=lambda files: ("codespell", files),
+            supports_directories=True,
+        ),
+        LinterCommand(
+            available=lambda: is_command_available("cspell"),
+            command=lambda files: (
+                "cspell",
+                ["--no-progress", "--no-summary", "--no-color"] + files,
+            ),
         ),
     ],
 }
 
 
+def tool_maps(mode: Mode) -> List[Dict[str, List[LinterCommand]]]:
+    """Get the maps of tool chains that run in a mode, in the order they run"""
+    if mode == Mode.IMPORTS:
+        return [IMPORTS_MAP]
+    if mode == Mode.SPELL:
+        return [SPELL_MAP]
+    if mode == Mode.FIX:
+        return [FIX_MAP]
+
+    maps = []
+    if mode in [Mode.LINT, Mode.BOTH]:
+        maps.append(LINTER_MAP)
+    if mode in [Mode.FORMAT, Mode.BOTH]:
+        maps.append(FORMATTER_MAP)
+    return maps
+
+
 def show_usage() -> None:
     """Show usage information"""
     print(USAGE_TEXT, file=sys.stderr)
@@ -857,6 +3370,9 @@ def show_help() -> None:
     print(f"\n{DIRECTORY_PROCESSING_TEXT}")
     print(f"\n{SUPPORTED_LANGUAGES_TEXT}")
     print(f"\n{CONFIGURATION_TEXT}")
+    print("\nPresets (--preset NAME, or \"preset\": \"NAME\" in config):")
+    for name, preset in PRESETS.items():
+        print(f"  {name:<12}{preset['description']}")
 
 
 def show_version() -> None:
@@ -871,12 +3387,134 @@ def show_version() -> None:
 # Thread-safe output lock
 output_lock = threading.Lock()
 
+# Exit status of a run stopped by SIGTERM, as a CI runner's timeout sends, or by Ctrl-C
+INTERRUPTED_EXIT_CODE = 130
+
+
+class Interrupted(Exception):
+    """taidy was told to stop, and should report what it has so far"""
+
+
+def raise_interrupted(signum: int, frame: Any) -> None:
+    """Turn SIGTERM into Interrupted; a second one stops taidy at once"""
+    signal.signal(signal.SIGTERM, signal.SIG_DFL)
+    raise Interrupted()
+
+
+def takes_file_arguments(cmd_signature: Tuple[str, Tuple[str, ...]]) -> bool:
+    """Check whether a batched command is given the files to process as arguments"""
+    cmd, base_args = cmd_signature
+    # just --fmt operates on the justfile in the current directory, and trufflehog
+    # git mode scans the repository
+    return not (cmd == "just" and "--fmt" in base_args) and not (
+        cmd == "trufflehog" and "git" in base_args
+    )
+
+
+# Tools whose option parsers accept `--` to end options, so that no file name after it
+# can be read as an option
+END_OF_OPTIONS_TOOLS = {
+    "ruff",
+    "black",
+    "isort",
+    "mypy",
+    "eslint",
+    "prettier",
+    "rubocop",
+    "shellcheck",
+    "shfmt",
+    "gofmt",
+    "yamllint",
+}
+
+
+def file_arguments(cmd_signature: Tuple[str, Tuple[str, ...]], files: List[str]) -> List[str]:
+    """Get the arguments that pass files to a command, so that none can be taken as an option.
+
+    Names starting with a dash get a `./` prefix, since not every tool accepts `--`.
+    """
+    paths = [f"./{file}" if file.startswith("-") else file for file in files]
+    if signature_tool_name(cmd_signature) in END_OF_OPTIONS_TOOLS:
+        return ["--"] + paths
+    return paths
+
+
+def publish_diagnostics(parsed: List[Diagnostic]) -> None:
+    """Send a run's findings to the registered sinks, so a failing sink can't end the run"""
+    for sink in list(diagnostics_sinks):
+        try:
+            with output_lock:
+                sink(parsed)
+        except Exception as e:
+            logger.warning(f"Diagnostics sink failed: {e}")
+
+
+# Lines that were moved rather than written, by file, for --ignore-moved-code
+moved_code: Dict[str, Set[int]] = {}
+# Number of findings this run downgraded for being on moved lines
+moved_findings = 0
+
+
+def reset_moved_code() -> None:
+    """Forget the last run's moved lines and downgraded findings, as a daemon's runs
+    share this process"""
+    global moved_code, moved_findings
+    moved_code = {}
+    moved_findings = 0
+
+
+def load_moved_code(since: Optional[str]) -> None:
+    """Find the lines moved since the last commit, or since a ref, whose findings are
+    downgraded; without git every finding is kept as it is"""
+    global moved_code
+    vcs = detect_vcs(Path.cwd(), find_git_root(Path.cwd()))
+    moved = vcs.moved_lines(since) if vcs is not None else None
+    if moved is None:
+        logger.warning("--ignore-moved-code needs a git repository with a commit to compare with")
+    moved_code = moved or {}
+
+
+def downgrade_moved_findings(parsed: List[Diagnostic]) -> int:
+    """Make findings on moved lines informational, returning how many there were"""
+    global moved_findings
+    count = 0
+    for diagnostic in parsed:
+        # Tools report paths relative to the cwd, or absolute as eslint does
+        if diagnostic.line in moved_code.get(os.path.relpath(diagnostic.file), set()):
+            diagnostic.severity = "info"
+            count += 1
+    with output_lock:
+        moved_findings += count
+    return count
+
 
 def execute_batched_command(
-    cmd_signature: Tuple[str, Tuple[str, ...]], file_list: List[str]
-) -> int:
-    """Execute a batched command with deduplicated file list"""
+    cmd_signature: Tuple[str, Tuple[str, ...]],
+    file_list: List[str],
+    diagnostics: Optional[List[Diagnostic]] = None,
+    quiet_success: bool = False,
+    findings: Optional[List[Diagnostic]] = None,
+    report: Optional[Report] = None,
+    criteria: Optional[SuccessCriteria] = None,
+    copies: Optional[Dict[str, str]] = None,
+) -> Tuple[int, Outcome]:
+    """Execute a batched command with deduplicated file list.
+
+    When a diagnostics list is given, findings parsed from the tool's output are
+    collected into it instead of printing the raw output. With quiet_success,
+    nothing at all is printed for a command that exits cleanly. A findings list
+    also receives the parsed findings, for the run history, without changing output.
+    With a report, the run and its output are recorded there, and printed only if the
+    report isn't quiet.
+
+    The run's outcome is judged by the criteria, and its exit code is 0 if it passed,
+    whatever the tool exited with. Files with copies, by the file, are processed through
+    their copies, with the copies' paths swapped back for the files' in the output.
+    """
     cmd, base_args = cmd_signature
+    tool = signature_tool_name(cmd_signature)
+    criteria = criteria or SuccessCriteria()
+    copies = copies or {}
 
     # Remove duplicates from file list while preserving order
     unique_files = []
@@ -886,39 +3524,243 @@ def execute_batched_command(
             seen.add(file)
             unique_files.append(file)
 
-    # Special handling for commands that don't take file arguments
-    if cmd == "just" and "--fmt" in base_args:
-        # just --fmt operates on the justfile in the current directory
-        args = list(base_args)
-    elif cmd == "trufflehog" and "git" in base_args:
-        # trufflehog git mode scans the repository, doesn't take file arguments
-        args = list(base_args)
+    # Build final command with files, unless the command doesn't take file arguments
+    if takes_file_arguments(cmd_signature):
+        args = list(base_args) + file_arguments(cmd_signature, unique_files)
+        targets = [copies.get(file, file) for file in unique_files]
+        run_args = [copies.get(arg, arg) for arg in base_args]
+        run_args += file_arguments(cmd_signature, targets)
     else:
-        # Build final command with files
-        args = list(base_args) + unique_files
+        args = list(base_args)
+        run_args = args
+    used_copies = {
+        file: copies[file] for file in unique_files + list(base_args) if file in copies
+    }
 
-    with output_lock:
-        logger.info(f"Running: {cmd} {' '.join(args)}")
+    # Under a CI service that folds logs, the banner waits to open the run's section, so
+    # it stays with the output instead of interleaving with other tools' banners
+    ci = detect_ci() if ci_mode else None
+    banner = message("running", command=" ".join([cmd] + [printable_path(arg) for arg in args]))
+    if not quiet_success and ci is None:
+        with output_lock:
+            logger.info(banner)
 
     try:
-        result = subprocess.run([cmd] + args, capture_output=True, text=True)
+        started = time.time()
+        start = time.monotonic()
+        # Under CI tools see end of input; elsewhere a prompt waits, and is caught
+        executable = resolve_command(cmd)
+        environment = tool_environment()
+        if show_env:
+            version = tool_version(cmd)
+            found = executable if os.path.isabs(executable) else "not found on the search path"
+            changes = environment_changes(environment)
+            with output_lock:
+                logger.info(f"{cmd}: {found}" + (f" ({version})" if version else ""))
+                logger.info(f"  working directory: {os.getcwd()}")
+                logger.info(f"  environment: {', '.join(changes) or 'unchanged'}")
+                if ci_mode:
+                    logger.info("  stdin: closed")
+        completed = run_unattended(
+            [executable] + run_args, env=environment, stdin_closed=ci_mode
+        )
+        stdout, stderr = completed.stdout, completed.stderr
+        # Absolute paths go first, as a copy's relative path is the end of its absolute one
+        for file, copy_path in used_copies.items():
+            swaps = [(os.path.abspath(copy_path), os.path.abspath(file)), (copy_path, file)]
+            for old, new in swaps:
+                stdout = stdout.replace(os.fsencode(old), os.fsencode(new))
+                stderr = stderr.replace(os.fsencode(old), os.fsencode(new))
+        # Tools echo file names, which needn't be valid UTF-8
+        result = subprocess.CompletedProcess(
+            [executable] + args,
+            completed.returncode,
+            stdout.decode(errors="backslashreplace"),
+            stderr.decode(errors="backslashreplace"),
+        )
+        duration = time.monotonic() - start
+        parsed = parse_diagnostics(tool, result.stdout, result.stderr)
+        moved = downgrade_moved_findings(parsed) if moved_code else 0
+        outcome = classify(
+            criteria, result.returncode, result.stdout, result.stderr, len(parsed) - moved
+        )
+        # Code that was only moved had its findings before the move, so they alone don't fail
+        if outcome == Outcome.FINDINGS and parsed and moved == len(parsed):
+            outcome = Outcome.PASSED
+        exit_code = outcome_exit_code(outcome, result.returncode)
+        if parsed:
+            publish_diagnostics(parsed)
+
+        if findings is not None and parsed:
+            with output_lock:
+                findings.extend(parsed)
+
+        if report is not None:
+            tool_run = ToolRun(
+                tool=tool,
+                command=[printable_path(arg) for arg in [cmd] + args],
+                exit_code=result.returncode,
+                duration=round(duration, 3),
+                stdout=result.stdout,
+                stderr=result.stderr,
+                version=tool_version(cmd),
+                path=executable if os.path.isabs(executable) else None,
+            )
+            with output_lock:
+                report.runs.append(tool_run)
+            if report.quiet:
+                return exit_code, outcome
+
+        if quiet_success:
+            if outcome == Outcome.PASSED:
+                return 0, outcome
+            # Only now that the tool reported issues is its banner worth showing
+            if ci is None:
+                with output_lock:
+                    logger.info(banner)
+
+        if diagnostics is not None:
+            # JSON output has nothing more to show, even when it held no findings
+            if parsed or load_json(result.stdout) is not None:
+                with output_lock:
+                    diagnostics.extend(parsed)
+                    if ci is not None:
+                        logger.info(banner)
+                return exit_code, outcome
 
         # Print output atomically to avoid mixing
         with output_lock:
+            if ci is not None:
+                opening, closing = section_markers(ci, f"{cmd} {' '.join(base_args)}", started)
+                print(opening, flush=True)
+                logger.info(banner)
             if result.stdout:
                 print(result.stdout, end="", flush=True)
             if result.stderr:
                 print(result.stderr, end="", file=sys.stderr, flush=True)
+            if ci is not None:
+                print(closing, flush=True)
 
-        return result.returncode
+        return exit_code, outcome
+    except InteractivePrompt as e:
+        explanation = message("interactive_setup", command=cmd, prompt=e.prompt)
+        with output_lock:
+            logger.error(explanation)
+            if report is not None:
+                report.runs.append(ToolRun(tool, [cmd] + args, 1, 0.0, "", explanation))
+        return 1, Outcome.ERROR
     except FileNotFoundError:
         with output_lock:
-            logger.error(f"Error executing {cmd}: command not found")
-        return 127  # Standard exit code for command not found
+            logger.error(message("command_not_found", command=cmd))
+            if report is not None:
+                report.runs.append(ToolRun(tool, [cmd] + args, 127, 0.0, "", "command not found"))
+        return 127, Outcome.ERROR  # Standard exit code for command not found
     except Exception as e:
         with output_lock:
             logger.error(f"Error executing {cmd}: {e}")
-        return 1  # General error
+        return 1, Outcome.ERROR  # General error
+
+
+def run_order(config: Dict[str, Any], group: str) -> str:
+    """Get whether a file group is linted or formatted first in "both" mode, from the
+    config's "extension_order" or else its "order" """
+    order = config.get("extension_order", {}).get(group, config.get("order", "lint-first"))
+    if order not in RUN_ORDERS:
+        logger.warning(f"Unknown order {order}, expected one of {', '.join(RUN_ORDERS)}")
+        return "lint-first"
+    return str(order)
+
+
+def print_dry_run(
+    runs: List[Tuple[Tuple[str, Tuple[str, ...]], List[str], List[str]]],
+    batch_kinds: Dict[Tuple[str, Tuple[str, ...]], str],
+    file_orders: Dict[str, str],
+) -> None:
+    """Print each command line a run would execute, phase by phase, as
+    execute_batched_command would build it"""
+    for phase_runs in order_runs(runs, batch_kinds, file_orders):
+        for cmd_signature, inputs, _ in phase_runs:
+            cmd, base_args = cmd_signature
+            args = list(base_args)
+            if takes_file_arguments(cmd_signature):
+                args += file_arguments(cmd_signature, list(dict.fromkeys(inputs)))
+            print(" ".join(shlex.quote(arg) for arg in [cmd] + args))
+
+
+def order_runs(
+    runs: List[Tuple[Tuple[str, Tuple[str, ...]], List[str], List[str]]],
+    batch_kinds: Dict[Tuple[str, Tuple[str, ...]], str],
+    file_orders: Dict[str, str],
+) -> List[List[Tuple[Tuple[str, Tuple[str, ...]], List[str], List[str]]]]:
+    """Split runs into phases that run one after another, following each file's order.
+
+    A run given files is split between phases when its files' orders differ. One that
+    can't be split, because it's given directories or takes no files, runs in the
+    earliest phase any of its files needs. Formatters sharing a file, as custom tools
+    can, take turns in the order they were listed rather than race to rewrite it, and
+    linting that comes after formatting waits for every formatter, so the formatting
+    can be kept before it starts.
+    """
+    phases: List[List[Tuple[Tuple[str, Tuple[str, ...]], List[str], List[str]]]] = [[], []]
+
+    def phase(cmd_signature: Tuple[str, Tuple[str, ...]], file: str) -> int:
+        formats_first = file_orders.get(file, "lint-first") == "format-first"
+        return int((batch_kinds.get(cmd_signature) == "format") != formats_first)
+
+    for cmd_signature, inputs, covered in runs:
+        if inputs != covered or not inputs or not takes_file_arguments(cmd_signature):
+            phases[min((phase(cmd_signature, f) for f in covered), default=0)].append(
+                (cmd_signature, inputs, covered)
+            )
+            continue
+        for index in [0, 1]:
+            part = [f for f in covered if phase(cmd_signature, f) == index]
+            if part:
+                phases[index].append((cmd_signature, part, part))
+
+    ordered = []
+    for index, runs_in_phase in enumerate(phases):
+        # Each formatter goes in the turn after the last one formatting any of its files
+        turns: List[List[Tuple[Tuple[str, Tuple[str, ...]], List[str], List[str]]]] = []
+        formatted: List[Set[str]] = []
+        linted_after = []
+        for run in runs_in_phase:
+            turn = 0
+            if index == 1 and batch_kinds.get(run[0]) != "format":
+                linted_after.append(run)
+                continue
+            if batch_kinds.get(run[0]) == "format":
+                covered = set(run[2])
+                sharing = [i + 1 for i, files in enumerate(formatted) if files & covered]
+                turn = max(sharing, default=0)
+                if turn == len(formatted):
+                    formatted.append(set())
+                formatted[turn] |= covered
+            while len(turns) <= turn:
+                turns.append([])
+            turns[turn].append(run)
+        ordered.extend(turns + [linted_after])
+    return [runs_in_phase for runs_in_phase in ordered if runs_in_phase]
+
+
+def execute_timed(
+    cmd_signature: Tuple[str, Tuple[str, ...]], *args: Any
+) -> Tuple[int, Outcome, float]:
+    """Execute a batched command, also returning how many seconds it took"""
+    start = time.monotonic()
+    exit_code, outcome = execute_batched_command(cmd_signature, *args)
+    return exit_code, outcome, time.monotonic() - start
+
+
+def run_within_limits(
+    limits: List[threading.Semaphore], function: Callable[..., Any], *args: Any
+) -> Any:
+    """Call a function while holding a place under each of its run's --jobs limits, taken
+    in the same order by every run so that none can wait on another forever"""
+    with contextlib.ExitStack() as stack:
+        for limit in limits:
+            stack.enter_context(limit)
+        return function(*args)
 
 
 def execute_linters(commands: List[LinterCommand], file_list: List[str]) -> int:
@@ -928,10 +3770,15 @@ def execute_linters(commands: List[LinterCommand], file_list: List[str]) -> int:
             cmd, args = linter_cmd.command(file_list)
 
             with output_lock:
-                logger.info(f"Running: {cmd} {' '.join(args)}")
+                logger.info(message("running", command=" ".join([cmd] + args)))
 
             try:
-                result = subprocess.run([cmd] + args, capture_output=True, text=True)
+                result = subprocess.run(
+                    [resolve_command(cmd)] + args,
+                    capture_output=True,
+                    text=True,
+                    env=tool_environment(),
+                )
 
                 # Print output atomically to avoid mixing
                 with output_lock:
@@ -943,7 +3790,7 @@ def execute_linters(commands: List[LinterCommand], file_list: List[str]) -> int:
                 return result.returncode
             except FileNotFoundError:
                 with output_lock:
-                    logger.error(f"Error executing {cmd}: command not found")
+                    logger.error(message("command_not_found", command=cmd))
                 return 127  # Standard exit code for command not found
             except Exception as e:
                 with output_lock:
@@ -977,7 +3824,7 @@ def process_file_group(
             result = execute_linters(LINTER_MAP[ext], inputs)
             if result == 2:
                 with output_lock:
-                    logger.warning(f"No available linter found for {ext} files")
+                    logger.warning(message("no_linter_for_extension", extension=ext))
             elif result != 0:
                 exit_code = result
 
@@ -995,82 +3842,695 @@ def process_file_group(
             result = execute_linters(FORMATTER_MAP[ext], inputs)
             if result == 2:
                 with output_lock:
-                    logger.warning(f"No available formatter found for {ext} files")
+                    logger.warning(message("no_formatter_for_extension", extension=ext))
             elif result != 0:
                 exit_code = result
 
     return exit_code
 
 
-def process_files(files: List[str], mode: Mode) -> int:
-    """Process files according to the specified mode"""
-    # Track which inputs were directories for potential direct passing to formatters
-    input_directories = [f for f in files if os.path.isdir(f) and os.path.exists(f)]
+def config_languages(config: Dict[str, Any]) -> Optional[Set[str]]:
+    """Get the extension keys of the config's "languages" list, as --lang would select"""
+    languages = config.get("languages")
+    if not languages:
+        return None
+    try:
+        return resolve_languages(",".join(languages))
+    except ValueError as e:
+        logger.warning(str(e))
+        return None
 
-    # Check if we have custom ignore patterns (beyond the defaults)
-    config = load_config(".")
-    config_ignores = config.get("ignore", [])
-    has_custom_ignores = len(config_ignores) > 0
 
-    # Expand directories to files
-    expanded_files = []
-    for file_or_dir in files:
-        if not os.path.exists(file_or_dir):
-            logger.warning(f"Path {file_or_dir} does not exist, skipping")
-            continue
+def config_file_size(config: Dict[str, Any]) -> Optional[int]:
+    """Get the config's "max_file_size", in bytes, warning about one that isn't a size"""
+    value = config.get("max_file_size")
+    if value is None:
+        return None
+    try:
+        return parse_size('"max_file_size"', str(value))
+    except ValueError:
+        logger.warning(f'Ignoring "max_file_size" {value}, expected a size such as 500k or 2MB')
+        return None
 
-        if os.path.isdir(file_or_dir):
-            discovered = discover_files_in_directory(file_or_dir)
-            if discovered:
-                logger.info(f"Discovered {len(discovered)} supported files in {file_or_dir}")
-                expanded_files.extend(discovered)
+
+def config_jobs(config: Dict[str, Any]) -> Tuple[Optional[int], Dict[str, int]]:
+    """Get the config's "jobs": a total, or a string such as "8,.ts=2" as --jobs takes"""
+    value = config.get("jobs")
+    if value is None:
+        return None, {}
+    try:
+        return parse_jobs('"jobs"', str(value))
+    except ValueError as e:
+        logger.warning(f"Ignoring {e}")
+        return None, {}
+
+
+def apply_config_defaults(options: RunOptions, config: Dict[str, Any]) -> RunOptions:
+    """Fill in options the command line left unset from the config's personal defaults"""
+    jobs, extension_jobs = config_jobs(config)
+    return replace(
+        options,
+        prefer_fast=options.prefer_fast or bool(config.get("prefer_fast", False)),
+        show_context=options.show_context or bool(config.get("show_context", False)),
+        quiet_success=options.quiet_success or bool(config.get("quiet_success", False)),
+        all_tools=options.all_tools or bool(config.get("all_tools", False)),
+        builtin_only=options.builtin_only or bool(config.get("builtin_only", False)),
+        jobs=options.jobs or jobs,
+        extension_jobs={**extension_jobs, **options.extension_jobs},
+        language_extensions=options.language_extensions or config_languages(config),
+        max_file_size=(
+            options.max_file_size
+            if options.max_file_size is not None
+            else config_file_size(config)
+        ),
+    )
+
+
+def process_files(files: List[str], mode: Mode, options: Optional[RunOptions] = None) -> int:
+    """Process files according to the specified mode, then any submodules when recursing"""
+    options = apply_config_defaults(options or RunOptions(), load_config(config_start_path(files)))
+    reset_moved_code()
+    if options.ignore_moved_code:
+        load_moved_code(options.since)
+    if options.staged:
+        return process_staged_files(files, mode, options)
+
+    # Changes on HEAD since it diverged from the ref, as a pull request would show them
+    if options.since is not None:
+        vcs = detect_vcs(Path.cwd(), find_git_root(Path.cwd()))
+        changed = vcs.changed_since(options.since) if vcs is not None else None
+        if changed is None:
+            logger.error(f"Could not list files changed since {options.since}")
+            return 1
+        files = files_under(changed, files)
+        if not files:
+            logger.info(f"No files changed since {options.since}")
+            if options.report is not None:
+                options.report.reason = f"no files changed since {options.since}"
+            return 0
+
+    exit_code = process_project_files(files, mode, options)
+
+    recurse = options.recurse_submodules or load_config(config_start_path(files)).get(
+        "recurse_submodules", False
+    )
+    if not recurse:
+        return exit_code
+
+    # Each submodule is processed as a project of its own, with its own config if it has one
+    for directory in [f for f in files if os.path.isdir(f)]:
+        for submodule in get_git_submodules(Path(directory)):
+            logger.info(f"Processing submodule {submodule}")
+            result = process_files([str(submodule)], mode, options)
+            if result != 0:
+                exit_code = result
+
+    return exit_code
+
+
+def diagnostic_owners(
+    diagnostic: Diagnostic, root: Path, entries: List[Tuple[IgnoreRule, List[str]]]
+) -> List[str]:
+    """Find the owners CODEOWNERS assigns a finding's file to"""
+    try:
+        relative_path = Path(diagnostic.file).resolve().relative_to(root).as_posix()
+    except ValueError:
+        return []
+    return owners_of(relative_path, entries)
+
+
+def print_diagnostics_by_owner(
+    diagnostics: List[Diagnostic], root: Path, show_context: bool
+) -> None:
+    """Print findings grouped under the owners CODEOWNERS assigns their files to"""
+    entries = load_codeowners(root)
+    if not entries:
+        logger.warning("No CODEOWNERS file found, so every finding is unowned")
+
+    groups: Dict[str, List[Diagnostic]] = {}
+    for diagnostic in diagnostics:
+        # A file with several owners is listed under each of them
+        for owner in diagnostic_owners(diagnostic, root, entries) or [UNOWNED]:
+            groups.setdefault(owner, []).append(diagnostic)
+
+    source_cache: Dict[str, List[str]] = {}
+    for owner in sorted(groups, key=lambda owner: (owner == UNOWNED, owner)):
+        print(f"{owner} ({len(groups[owner])} findings)")
+        for diagnostic in groups[owner]:
+            if show_context:
+                rendered = format_context(diagnostic, source_cache) + "\n"
             else:
-                logger.warning(f"No supported files found in directory {file_or_dir}")
-        else:
-            expanded_files.append(file_or_dir)
+                rendered = format_diagnostic(diagnostic)
+            print("\n".join(f"  {line}" if line else "" for line in rendered.split("\n")))
+        print()
+
+
+def language_label(group: str) -> str:
+    """Name the language of a file group, such as python for .py"""
+    for language, extensions in LANGUAGES.items():
+        if group in extensions:
+            return language
+    return group
+
+
+def print_run_summary(
+    file_groups: Dict[str, List[str]],
+    group_tools: Dict[str, List[str]],
+    findings: List[Diagnostic],
+    reformatted: List[str],
+    elapsed: float,
+    summary: Optional[Dict[str, Any]] = None,
+) -> None:
+    """Print a table of what each file group got: its tools and their findings, then the
+    tidiness score when the run was summarised for one"""
+    rows: Dict[str, Tuple[Set[str], List[str]]] = {}
+    for group, files in file_groups.items():
+        paths, tools = rows.setdefault(language_label(group), (set(), []))
+        paths.update(os.path.normpath(file) for file in files)
+        tools.extend(tool for tool in group_tools.get(group, []) if tool not in tools)
+
+    # Columns widen to fit headers in languages with longer words
+    headers = [message(key) for key in ["summary_files", "summary_errors", "summary_warnings"]]
+    widths = [max(width, len(header)) for width, header in zip([6, 7, 9], headers)]
+    columns = " ".join(f"{header:>{width}}" for header, width in zip(headers, widths))
+    print(f"\n{message('summary_language'):<16} {columns}  {message('summary_tools')}")
+    for language, (paths, tools) in sorted(rows.items()):
+        severities = Counter(d.severity for d in findings if os.path.normpath(d.file) in paths)
+        counts = [len(paths), severities["error"], severities["warning"]]
+        columns = " ".join(f"{count:>{width}}" for count, width in zip(counts, widths))
+        print(f"{language:<16} {columns}  {', '.join(tools) or message('summary_no_tools')}")
+
+    if summary is not None:
+        print(message("tidiness_score", score=summary["score"], total=summary["total"]))
+    if reformatted:
+        print(message("reformatted_in", count=len(reformatted), seconds=elapsed))
+    else:
+        print(message("finished_in", seconds=elapsed))
+
+
+def print_timings(
+    durations: List[Tuple[Tuple[str, Tuple[str, ...]], List[str], float]],
+    file_groups: Dict[str, List[str]],
+    group_tools: Dict[str, List[str]],
+    stream: IO[str],
+) -> None:
+    """Print the wall-clock time of each command, and of each language, slowest first.
+
+    A run covering several languages, like prettier's, has its time shared between them
+    by file count.
+    """
+    groups_of: Dict[str, List[str]] = {}
+    for group, files in file_groups.items():
+        for file in files:
+            groups_of.setdefault(file, []).append(group)
+
+    by_command: Dict[str, List[float]] = {}
+    by_language: Dict[str, float] = {}
+    for cmd_signature, covered, elapsed in durations:
+        cmd, base_args = cmd_signature
+        by_command.setdefault(" ".join((cmd,) + base_args), []).append(elapsed)
+
+        tool = signature_tool_name(cmd_signature)
+        for file in covered:
+            groups = [g for g in groups_of.get(file, []) if tool in group_tools.get(g, [])]
+            for group in groups:
+                language = language_label(group)
+                share = elapsed / len(covered) / len(groups)
+                by_language[language] = by_language.get(language, 0.0) + share
+
+    print("\nTimings by command:", file=stream)
+    for command, times in sorted(by_command.items(), key=lambda item: -sum(item[1])):
+        runs = f"{len(times)} run{'s' if len(times) != 1 else ''}"
+        print(f"  {sum(times):>7.2f}s  {runs:<8} {command}", file=stream)
+
+    print("Timings by language:", file=stream)
+    for language, seconds in sorted(by_language.items(), key=lambda item: -item[1]):
+        print(f"  {seconds:>7.2f}s  {language}", file=stream)
+
+
+def available_chain(
+    tool_map: Dict[str, List[LinterCommand]],
+    file: str,
+    config: Dict[str, Any],
+    tools: Optional[List[str]] = None,
+) -> List[LinterCommand]:
+    """Get the available commands for a file from a tool map, in the config's order, or
+    just the tools given with --tool if the chain has them"""
+    extension_overrides: Dict[str, str] = config.get("extensions", {})
+    ext = mapped_extension(get_extension_key(Path(file)), extension_overrides)
+    if ext is None:
+        return []
+    context = ConditionContext(1, find_project_root(config_start_path([file])), current_platform())
+    tools = tools or []
+    commands = apply_preferences(tool_map.get(ext, []), config.get("prefer", []))
+    return [
+        linter_cmd
+        for linter_cmd in force_tools(commands, tools)
+        if command_tool_name(linter_cmd) not in set(config.get("disable", [])) - set(tools)
+        and linter_cmd.available()
+        and conditions_met(linter_cmd, config.get("when", {}), context)
+    ]
 
-    # Group files by their file extension
+
+def run_on_content(
+    linter_cmd: LinterCommand, file: str, content: bytes, extra_args: List[str]
+) -> Tuple[int, bytes, bytes, bytes]:
+    """Run a command on content that belongs to a file, without touching the file.
+
+    Commands with a stdin mode are given the content on stdin. Others run on a
+    temporary copy beside the file, so they find the same config, with the copy's path
+    replaced by the file's in their output.
+    Returns the exit code, stdout, stderr and the content as the command left it: its
+    output in stdin mode, or else the copy's contents.
+    """
+    if linter_cmd.stdin_command is not None:
+        # The file name is an option's value in stdin mode, which a leading dash would confuse
+        cmd, args = linter_cmd.stdin_command(f"./{file}" if file.startswith("-") else file)
+        result = subprocess.run(
+            [resolve_command(cmd)] + args + extra_args,
+            input=content,
+            capture_output=True,
+            env=tool_environment(),
+        )
+        return result.returncode, result.stdout, result.stderr, result.stdout
+
+    # Content from stdin may belong to a file in a directory that doesn't exist yet
+    directory = os.path.dirname(file)
+    if not os.path.isdir(directory or "."):
+        directory = ""
+    fd, temp_path = tempfile.mkstemp(
+        prefix=".taidy-", suffix=f"-{Path(file).name}", dir=directory or "."
+    )
+    temp_file = os.path.join(directory, os.path.basename(temp_path))
+    try:
+        with os.fdopen(fd, "wb") as f:
+            f.write(content)
+        cmd, args = linter_cmd.command([temp_file])
+        # stdin may be an editor's protocol stream, which a prompting tool mustn't read
+        result = subprocess.run(
+            [resolve_command(cmd)] + args + extra_args,
+            stdin=subprocess.DEVNULL,
+            capture_output=True,
+            env=tool_environment(),
+        )
+        with open(temp_path, "rb") as f:
+            after = f.read()
+    finally:
+        os.unlink(temp_path)
+
+    stdout = result.stdout.replace(os.fsencode(temp_file), os.fsencode(file))
+    stderr = result.stderr.replace(os.fsencode(temp_file), os.fsencode(file))
+    return result.returncode, stdout, stderr, after
+
+
+@dataclass
+class ContentRun:
+    """What a tool made of content that belongs to a file"""
+
+    exit_code: int
+    stdout: str
+    stderr: str
+    # The content as the tool left it, which is what formatters produce
+    content: bytes
+    # Findings parsed from a linter's output, and the outcome they give its run
+    diagnostics: List[Diagnostic] = field(default_factory=list)
+    outcome: Outcome = Outcome.PASSED
+
+
+def run_chain_on_content(
+    tool_map: Dict[str, List[LinterCommand]], file: str, content: bytes, options: RunOptions
+) -> Optional[ContentRun]:
+    """Run the first available command in a file's chain on content for it.
+
+    Returns None if no command in the chain is available.
+    """
+    config = load_config(config_start_path([file]))
+    configure_search_path(config, find_project_root(config_start_path([file])), options.login_shell)
+
+    # Commands like just --fmt can't be pointed at a copy
+    chain = [
+        linter_cmd
+        for linter_cmd in available_chain(tool_map, file, config, options.tools)
+        if linter_cmd.stdin_command is not None
+        or takes_file_arguments((linter_cmd.command([])[0], tuple(linter_cmd.command([])[1])))
+    ]
+    if not chain:
+        return None
+
+    linter_cmd = chain[0]
+    kind = "lint" if tool_map is LINTER_MAP else "format"
+    extra_args = configured_args(linter_cmd, config, kind) + options.tool_args
+    returncode, stdout, stderr, after = run_on_content(linter_cmd, file, content, extra_args)
+    run = ContentRun(
+        exit_code=returncode,
+        stdout=stdout.decode(errors="backslashreplace"),
+        stderr=stderr.decode(errors="backslashreplace"),
+        content=after,
+    )
+    if tool_map is not LINTER_MAP:
+        return run
+
+    cmd, args = linter_cmd.command([])
+    tool = signature_tool_name((cmd, tuple(args)))
+    run.diagnostics = parse_diagnostics(tool, run.stdout, run.stderr)
+    criteria = success_criteria(linter_cmd, config.get("success", {}), options.strict)
+    run.outcome = classify(criteria, returncode, run.stdout, run.stderr, len(run.diagnostics))
+    if run.diagnostics:
+        publish_diagnostics(run.diagnostics)
+    return run
+
+
+def format_to_stdout(file: str, options: RunOptions, content: Optional[bytes] = None) -> int:
+    """Print a file as its formatter would leave it, without changing the file.
+
+    Content, such as an unsaved buffer from stdin, is formatted in place of the file's own.
+    """
+    if content is None:
+        content = Path(file).read_bytes()
+    run = run_chain_on_content(FORMATTER_MAP, file, content, options)
+    if run is None:
+        logger.error(message("no_formatter_for_file", file=printable_path(file)))
+        return 1
+
+    print(run.stderr, end="", file=sys.stderr, flush=True)
+    if run.exit_code == 0:
+        sys.stdout.buffer.write(run.content)
+        sys.stdout.flush()
+    return run.exit_code
+
+
+def process_stdin(mode: Mode, options: RunOptions) -> int:
+    """Lint or format content from stdin as if it were the file named by --stdin-filename.
+
+    The name picks the tool chain and config, and tools see the content through their
+    stdin mode or a temporary copy, so the file itself is never read or changed.
+    """
+    file = options.stdin_filename or ""
+    content = sys.stdin.buffer.read()
+    if mode == Mode.FORMAT:
+        return format_to_stdout(file, options, content)
+
+    run = run_chain_on_content(LINTER_MAP, file, content, options)
+    if run is None:
+        logger.error(message("no_linter_for_file", file=printable_path(file)))
+        return 1
+
+    if options.show_context and run.diagnostics:
+        # The findings point into the content, not whatever the file holds on disk
+        lines = content.decode(errors="replace").splitlines()
+        source_cache = {diagnostic.file: lines for diagnostic in run.diagnostics}
+        for diagnostic in sorted(run.diagnostics, key=lambda d: (d.line, d.column or 0)):
+            print(format_context(diagnostic, source_cache) + "\n")
+    else:
+        print(run.stdout, end="", flush=True)
+    print(run.stderr, end="", file=sys.stderr, flush=True)
+
+    if options.exit_zero and run.outcome != Outcome.ERROR:
+        return 0
+    return outcome_exit_code(run.outcome, run.exit_code)
+
+
+def lsp_command(args: List[str]) -> int:
+    """Handle `taidy lsp`, serving an editor over stdin and stdout"""
+    try:
+        options, positional = parse_flags(args)
+    except ValueError as e:
+        print(f"Error: {e}", file=sys.stderr)
+        return 1
+    if positional:
+        print("Usage: taidy lsp [flags]", file=sys.stderr)
+        return 1
+    select_preset(options.preset)
+    skip_tools(options.skip_tools)
+
+    # stdout carries the protocol, so nothing else may be printed there
+    for handler in logger.handlers:
+        if isinstance(handler, logging.StreamHandler):
+            handler.setStream(sys.stderr)
+
+    def lint(path: str, text: str) -> List[Diagnostic]:
+        run = run_chain_on_content(LINTER_MAP, path, text.encode(), options)
+        return run.diagnostics if run is not None else []
+
+    def format_text(path: str, text: str) -> Optional[str]:
+        run = run_chain_on_content(FORMATTER_MAP, path, text.encode(), options)
+        if run is None or run.exit_code != 0:
+            return None
+        return run.content.decode(errors="replace")
+
+    return serve_lsp(sys.stdin.buffer, sys.stdout.buffer, lint, format_text, VERSION)
+
+
+def explain_command(args: List[str]) -> int:
+    """Handle `taidy explain [flags] <files...>`: lint, then have the configured language
+    model explain the findings and suggest patches
+    """
+    try:
+        options, files = parse_flags(args)
+        options.record_history = False
+        settings = explain_settings(load_config("."), load_user_config())
+    except ValueError as e:
+        print(f"Error: {e}", file=sys.stderr)
+        return 1
+    select_preset(options.preset)
+    skip_tools(options.skip_tools)
+    if not files:
+        files = ["."]
+
+    findings: List[Diagnostic] = []
+    add_diagnostics_sink(findings.extend)
+    try:
+        with captured_output(io.StringIO()):
+            process_files(files, Mode.LINT, options)
+    finally:
+        remove_diagnostics_sink(findings.extend)
+
+    if not findings:
+        logger.info("No findings to explain")
+        return 0
+
+    for finding in findings:
+        print(format_diagnostic(finding))
+    count = min(len(findings), MAX_DIAGNOSTICS)
+    print()
+    logger.info(f"Sending {count} findings and nearby source lines to {settings.endpoint}")
+    try:
+        explanation = request_explanation(settings, build_prompt(findings))
+    except ValueError as e:
+        logger.error(f"Error: {e}")
+        return 1
+    print(f"\n{explanation.strip()}")
+    return 0
+
+
+def process_staged_files(paths: List[str], mode: Mode, options: RunOptions) -> int:
+    """Process the files the next commit would include under the given paths, as a hook.
+
+    With git, files the formatters change are staged again so the fixes land in the
+    commit, unless they also have unstaged changes, which would be swept in with them.
+    Mercurial and Jujutsu commit the working copy, so their fixes land there anyway.
+    """
+    vcs = detect_vcs(Path.cwd(), find_git_root(Path.cwd()))
+    staged = vcs.staged_files() if vcs is not None else None
+    if vcs is None or staged is None:
+        logger.error("--staged only works inside a git, Mercurial or Jujutsu repository")
+        return 1
+
+    files = files_under(staged, paths)
+    if not files:
+        logger.info(message("no_staged_files"))
+        if options.report is not None:
+            options.report.reason = "no staged files"
+        return 0
+
+    unstaged = set(vcs.unstaged_files())
+    before = {file: file_digest(file) for file in files}
+
+    exit_code = process_project_files(files, mode, options)
+    if mode in [Mode.LINT, Mode.SPELL] or not vcs.has_staging_area:
+        return exit_code
+
+    changed = [file for file in files if file_digest(file) != before[file]]
+    restage = [file for file in changed if file not in unstaged]
+    for file in changed:
+        if file in unstaged:
+            logger.warning(
+                f"{printable_path(file)} has unstaged changes, "
+                "so its formatting fixes were not staged"
+            )
+
+    if restage:
+        if not vcs.stage(restage):
+            logger.error("Failed to stage formatting fixes")
+            return 1
+        logger.info(message("staged_fixes", count=len(restage)))
+
+    return exit_code
+
+
+def records_history(files: List[str], project_root: Path, options: RunOptions) -> bool:
+    """Check whether a run goes in the project's history: runs over part of the project
+    would make the trend jump around, so only those over all of it with no selection are"""
+    return (
+        options.record_history
+        and bool(files)
+        and all(os.path.isdir(f) and Path(f).resolve() == project_root.resolve() for f in files)
+        and not options.staged
+        and options.since is None
+        and options.modified_since is None
+        and options.language_extensions is None
+        and options.from_archive is None
+        and not options.resume
+        and options.shard is None
+    )
+
+
+def checks_editorconfig(mode: Mode) -> bool:
+    """Check whether editorconfig-checker lints this run's files. It applies to every text
+    file, whether or not its language has a chain, once the project has an .editorconfig"""
+    return (
+        mode in [Mode.LINT, Mode.BOTH]
+        and (find_project_root(".") / ".editorconfig").exists()
+        and any(linter_cmd.available() for linter_cmd in LINTER_MAP[".editorconfig"])
+    )
+
+
+@dataclass
+class Screening:
+    """A run's files grouped by the chains that take them, and whether any were held back"""
+
+    file_groups: Dict[str, List[str]]
+    # Files no chain claims, which are still linted for conflict markers
+    unclaimed_files: List[str]
+    # Secrets files held back from tools that would read them
+    has_sensitive_files: bool
+    # Minified and oversized files skipped
+    has_skipped_files: bool
+
+
+def screen_files(
+    expanded_files: List[str],
+    files: List[str],
+    input_directories: List[str],
+    mode: Mode,
+    options: RunOptions,
+    config: Dict[str, Any],
+) -> Screening:
+    """Group the files by the chains that take them, by extension, holding back secrets,
+    minified and oversized files and those of languages --lang leaves out"""
     file_groups: Dict[str, List[str]] = {}
 
+    selected = options.language_extensions
+    sensitive_patterns = config.get("sensitive", [])
+    extension_overrides: Dict[str, str] = config.get("extensions", {})
+    has_sensitive_files = False
+    # Minified and oversized files are skipped, so tools can't be given their directories
+    has_skipped_files = False
+    # Minified files are only skipped when found in a directory, not when named
+    skip_minified = config.get("skip_minified", True)
+    named_files = {os.path.normpath(f) for f in files if os.path.isfile(f)}
+
+    # Add to security scanning group if trufflehog is available, we're linting,
+    # and we're scanning a single directory (not individual files)
+    scan_security = (
+        mode in [Mode.LINT, Mode.BOTH]
+        and (selected is None or ".security" in selected)
+        and is_command_available("trufflehog")
+        and len(input_directories) == 1
+        and len(files) == 1
+    )
+    scan_sensitive = scan_security or (
+        options.scan_sensitive
+        and mode in [Mode.LINT, Mode.BOTH]
+        and is_command_available("trufflehog")
+    )
+
+    check_editorconfig = checks_editorconfig(mode)
+
+    # Files no chain claims, which are still linted for conflict markers
+    unclaimed_files: List[str] = []
+
     for file in expanded_files:
         file_path = Path(file)
         ext = file_path.suffix.lower()
+        mapped_ext = mapped_extension(get_extension_key(file_path), extension_overrides)
+        # Files the config's "extensions" table skips aren't seen by any tool
+        if mapped_ext is None:
+            continue
 
-        # Handle special cases for file mapping
-        mapped_ext = ext
+        if (
+            skip_minified
+            and os.path.normpath(file) not in named_files
+            and any(fnmatch.fnmatch(file_path.name.lower(), p) for p in MINIFIED_PATTERNS)
+        ):
+            logger.warning(message("minified_skipped", file=printable_path(file)))
+            has_skipped_files = True
+            continue
 
-        # Special case: Justfile files
-        if file_path.name.lower() in ["justfile", "justfile.just"]:
-            mapped_ext = "justfile"
+        if options.max_file_size is not None:
+            try:
+                size = os.path.getsize(long_path(file))
+            except OSError:
+                size = 0
+            if size > options.max_file_size:
+                logger.info(
+                    message(
+                        "oversized_skipped",
+                        file=printable_path(file),
+                        size=describe_size(size),
+                        limit=describe_size(options.max_file_size),
+                    )
+                )
+                has_skipped_files = True
+                continue
+
+        # With --lang, files of other languages are skipped silently
+        if selected is not None and mapped_ext not in selected and file_path.name not in selected:
+            continue
 
-        # Special case: GitHub Actions workflow files
-        if ext in [".yml", ".yaml"] and ".github/workflows" in str(file_path):
-            mapped_ext = ".github-workflow"
+        if mode == Mode.SPELL:
+            mapped_ext = ".spell"
 
         # Check if we have configuration for this extension based on mode
-        has_config = False
-        if mode == Mode.LINT:
-            has_config = mapped_ext in LINTER_MAP
-        elif mode == Mode.FORMAT:
-            has_config = mapped_ext in FORMATTER_MAP
-        elif mode == Mode.BOTH:
-            has_config = mapped_ext in LINTER_MAP or mapped_ext in FORMATTER_MAP
+        has_config = any(mapped_ext in tool_map for tool_map in tool_maps(mode))
+        # `--lang manifests` selects a manifest's own chain but not its file type's
+        if selected is not None and mapped_ext not in selected:
+            has_config = False
+
+        # Secrets files are kept away from third-party tools unless explicitly allowed
+        if not options.allow_sensitive and is_sensitive_file(file_path, sensitive_patterns):
+            # Directories can't be handed to tools that would read this file
+            has_sensitive_files = has_sensitive_files or has_config
+            if scan_sensitive:
+                file_groups.setdefault(".security", []).append(file)
+            elif options.scan_sensitive:
+                logger.warning(message("sensitive_not_scanned", file=printable_path(file)))
+            else:
+                logger.warning(message("sensitive_skipped", file=printable_path(file)))
+            continue
+
+        if check_editorconfig:
+            file_groups.setdefault(".editorconfig", []).append(file)
+
+        # Dependency manifests get their own lint chain, on top of their file type's
+        is_manifest = mode in [Mode.LINT, Mode.BOTH] and file_path.name in MANIFEST_FILES
+        if is_manifest:
+            file_groups.setdefault(file_path.name, []).append(file)
 
         if has_config:
             if mapped_ext not in file_groups:
                 file_groups[mapped_ext] = []
             file_groups[mapped_ext].append(file)
-        else:
-            logger.warning(f"No linter configured for file {file} (extension: {ext})")
+        # Files taidy only lints, such as Dockerfiles, are passed over quietly when formatting
+        elif not check_editorconfig and not is_manifest and mapped_ext not in LINTER_MAP:
+            logger.warning(
+                message("no_linter_configured", file=printable_path(file), extension=ext)
+            )
+        if not has_config and not check_editorconfig and not is_manifest:
+            if mode in [Mode.LINT, Mode.BOTH]:
+                unclaimed_files.append(file)
 
-        # Add to security scanning group if trufflehog is available, we're linting,
-        # and we're scanning a single directory (not individual files)
-        if (
-            mode in [Mode.LINT, Mode.BOTH]
-            and is_command_available("trufflehog")
-            and len(input_directories) == 1
-            and len(files) == 1
-        ):
+        if scan_security:
             security_extensions = {
                 ".py",
                 ".js",
@@ -1103,92 +4563,607 @@ def process_files(files: List[str], mode: Mode) -> int:
                     file_groups[".security"] = []
                 file_groups[".security"].append(file)
 
+
+    return Screening(file_groups, unclaimed_files, has_sensitive_files, has_skipped_files)
+
+
+def process_project_files(files: List[str], mode: Mode, options: RunOptions) -> int:
+    """Process files from a single project (submodules excluded) according to the mode"""
+    start = time.monotonic()
+
+    # Track which inputs were directories for potential direct passing to formatters
+    input_directories = [f for f in files if os.path.isdir(f) and os.path.exists(f)]
+
+    # Check if we have custom ignore patterns (beyond the defaults)
+    config = load_config(config_start_path(files))
+    configure_search_path(
+        config, find_project_root(config_start_path(files)), options.login_shell
+    )
+    config_ignores = config.get("ignore", [])
+    project_root = find_project_root(config_start_path(files))
+    has_custom_ignores = len(config_ignores) > 0 or bool(load_ignore_file(project_root))
+
+    # Expand directories to files
+    expanded_files = []
+    for file_or_dir in files:
+        # Quoted glob patterns, such as 'src/**/*.py', are expanded here
+        if not os.path.exists(file_or_dir) and any(c in file_or_dir for c in "*?["):
+            matches = sorted(glob.glob(file_or_dir, recursive=True))
+            matches = [f for f in matches if os.path.isfile(f)]
+            if options.use_gitignore:
+                matches = filter_git_ignored(matches)
+            if not matches:
+                logger.warning(message("no_files_match", pattern=printable_path(file_or_dir)))
+            expanded_files.extend(matches)
+            continue
+
+        if not os.path.exists(file_or_dir):
+            logger.warning(message("path_missing", path=printable_path(file_or_dir)))
+            continue
+
+        if os.path.isdir(file_or_dir):
+            discovered = discover_files_in_directory(
+                file_or_dir,
+                options.use_gitignore,
+                options.follow_symlinks,
+                mode == Mode.SPELL or checks_editorconfig(mode),
+            )
+            if discovered:
+                if not options.quiet_success:
+                    logger.info(
+                        message(
+                            "discovered",
+                            count=len(discovered),
+                            directory=printable_path(file_or_dir),
+                        )
+                    )
+                expanded_files.extend(discovered)
+            else:
+                logger.warning(
+                    message("no_supported_in_directory", directory=printable_path(file_or_dir))
+                )
+        else:
+            expanded_files.append(file_or_dir)
+
+    # .taidyignore applies to explicitly named files too, not just directory contents
+    expanded_files = filter_taidyignored(expanded_files, project_root)
+
+    # With --shard, each CI job takes its own stable slice of the files
+    if options.shard is not None:
+        expanded_files = [f for f in expanded_files if in_shard(f, project_root, options.shard)]
+
+    # --modified-since compares modification times, without needing git
+    if options.modified_since is not None:
+        modified_since = options.modified_since
+        expanded_files = [f for f in expanded_files if (file_mtime(f) or 0) > modified_since]
+        if not expanded_files:
+            logger.info(message("no_files_modified"))
+            if options.report is not None:
+                options.report.reason = "no files modified since the given time"
+            return 0
+
+    if options.report is not None:
+        options.report.add_files(expanded_files)
+        if options.record or options.replay:
+            options.report.input_digests = {f: file_digest(f) for f in expanded_files}
+
+    # Group files by their file extension
+    screening = screen_files(expanded_files, files, input_directories, mode, options, config)
+    file_groups = screening.file_groups
+    unclaimed_files = screening.unclaimed_files
+
     # Check if any files will be processed
-    if not file_groups:
-        logger.info("No supported files provided, no files were linted")
+    if not file_groups and not unclaimed_files:
+        if options.report is not None:
+            options.report.reason = "no supported files"
+        if options.error_on_empty:
+            logger.error(message("no_supported_files"))
+            return EXIT_NOTHING_TO_DO
+        logger.info(message("no_supported_files"))
         return 0
 
     # Batch commands by their command signature to avoid duplicate runs
     command_batches: Dict[Tuple[str, Tuple[str, ...]], List[str]] = {}
+    # The files each batch covers, even when it is passed directories instead
+    batch_files: Dict[Tuple[str, Tuple[str, ...]], List[str]] = {}
+    directory_batches: Set[Tuple[str, Tuple[str, ...]]] = set()
+
+    formats = mode in [Mode.FORMAT, Mode.BOTH, Mode.IMPORTS, Mode.FIX]
+
+    # Resuming, sharding and --modified-since need explicit file lists, so other files can be
+    # left out, and so does keeping secrets files and minified bundles away from tools
+    # that would read whole directories. Files found through symlinks would be missed by
+    # tools that don't follow them. Formatting works on copies of the files, which tools
+    # have to be given by name. Tools would also walk into git submodules, which are
+    # projects of their own, processed only with --recurse-submodules
+    pass_directories = (
+        bool(input_directories)
+        and not options.follow_symlinks
+        and not has_custom_ignores
+        and not options.resume
+        and not screening.has_sensitive_files
+        and not screening.has_skipped_files
+        and options.shard is None
+        and options.modified_since is None
+        and not formats
+        and not any(get_git_submodules(Path(directory)) for directory in input_directories)
+    )
 
-    # Collect all commands that would be run
-    for ext, file_list in file_groups.items():
-        # Process linting commands
-        if mode in [Mode.LINT, Mode.BOTH] and ext in LINTER_MAP:
-            for linter_cmd in LINTER_MAP[ext]:
-                if linter_cmd.available():
-                    # Use directory if supported and no custom ignores
+    # With --prefer-fast, reorder equally capable tools by their measured speed
+    timings: Dict[str, Dict[str, Any]] = {}
+    if options.prefer_fast:
+        timings = load_cache().get("tool_timings", {})
+
+    prefer = config.get("prefer", [])
+    disabled = config.get("disable", [])
+    try:
+        custom_tools = parse_custom_tools(config)
+    except ValueError as e:
+        logger.warning(message("custom_tools_ignored", error=e))
+        custom_tools = []
+    # A custom tool takes the place of the built-in tool of the same name
+    disabled = list(disabled) + [tool.name for tool in custom_tools]
+    configured_criteria = config.get("success", {})
+    thresholds = config.get("thresholds", {})
+    conditions = config.get("when", {})
+    # When findings are rendered by taidy rather than printed as the tools wrote them,
+    # tools that can report them as JSON are asked to
+    structured = options.show_context or options.group_by is not None or options.output != "text"
+    # How each command's runs are judged, keyed like command_batches
+    batch_criteria: Dict[Tuple[str, Tuple[str, ...]], SuccessCriteria] = {}
+    # The tools chosen for each file group, for the summary at the end
+    group_tools: Dict[str, List[str]] = {}
+    # Whether each command lints or formats, and each file's order of the two
+    batch_kinds: Dict[Tuple[str, Tuple[str, ...]], str] = {}
+    file_orders: Dict[str, str] = {}
+    if mode == Mode.BOTH:
+        for group, group_files in file_groups.items():
+            for file in group_files:
+                file_orders.setdefault(file, run_order(config, group))
+        format_first = sorted(
+            {language_label(g) for g in file_groups if run_order(config, g) == "format-first"}
+        )
+        if format_first and not options.quiet_success:
+            logger.info(message("format_first", languages=", ".join(format_first)))
+
+    # Collect all commands that would be run: linters first, then formatters
+    unusable_tools: Set[str] = set()
+    # The tools given the arguments after a second `--`, which fit only one of them
+    passed_to: Set[str] = set()
+    for tool_map in tool_maps(mode):
+        kind = "format" if tool_map is FORMATTER_MAP else "lint"
+        for ext, file_list in file_groups.items():
+            if ext not in tool_map:
+                continue
+
+            context = ConditionContext(len(file_list), project_root, current_platform())
+            # Tools given with --tool are used even if the config disables them
+            commands = force_tools(apply_preferences(tool_map[ext], prefer), options.tools)
+            chain = [
+                linter_cmd
+                for linter_cmd in commands
+                if command_tool_name(linter_cmd) not in set(disabled) - set(options.tools)
+                and conditions_met(linter_cmd, conditions, context)
+                and (is_builtin(linter_cmd) or not options.builtin_only)
+            ]
+            # With --all-tools every available linter runs, each tool once, not just the first
+            run_all = options.all_tools and kind == "lint"
+            used: List[str] = []
+            for linter_cmd in order_by_speed(chain, timings, kind):
+                if not linter_cmd.available() or command_tool_name(linter_cmd) in used:
+                    continue
+                used.append(command_tool_name(linter_cmd))
+
+                if linter_cmd.per_file:
+                    # The path is part of each command, so every file is a batch of its own
+                    for file in file_list:
+                        cmd, args = linter_cmd.command([file])
+                        args = command_args(linter_cmd, args, [file], config, kind)
+                        args += options.tool_args
+                        passed_to.add(f"{command_tool_name(linter_cmd)} ({kind})")
+                        if structured:
+                            args += structured_output_args(linter_cmd)
+                        cmd_signature = (cmd, tuple(args))
+                        command_batches.setdefault(cmd_signature, [])
+                        batch_files.setdefault(cmd_signature, []).append(file)
+                        batch_criteria[cmd_signature] = success_criteria(
+                            linter_cmd, configured_criteria, options.strict
+                        )
+                        batch_kinds[cmd_signature] = kind
+                    group_tools.setdefault(ext, []).append(command_tool_name(linter_cmd))
+                else:
+                    # Use directory if supported and no custom ignores. Tools find files in
+                    # directories by extension, so files known by name or #! line need naming
                     inputs = file_list
                     if (
-                        input_directories
-                        and not has_custom_ignores
+                        pass_directories
                         and linter_cmd.supports_directories
+                        and all(Path(file).suffix.lower() == ext for file in file_list)
                     ):
                         inputs = input_directories
 
                     cmd, args = linter_cmd.command(inputs)
                     # Create a signature excluding the file arguments
-                    base_args = [arg for arg in args if arg not in inputs]
+                    base_args = command_args(
+                        linter_cmd, [arg for arg in args if arg not in inputs], [], config, kind
+                    )
+                    base_args += options.tool_args
+                    passed_to.add(f"{command_tool_name(linter_cmd)} ({kind})")
+                    if structured:
+                        base_args += structured_output_args(linter_cmd)
                     cmd_signature = (cmd, tuple(base_args))
 
                     if cmd_signature not in command_batches:
                         command_batches[cmd_signature] = []
+                        batch_files[cmd_signature] = []
+                        batch_criteria[cmd_signature] = success_criteria(
+                            linter_cmd, configured_criteria, options.strict
+                        )
+                        batch_kinds[cmd_signature] = kind
                     command_batches[cmd_signature].extend(inputs)
+                    batch_files[cmd_signature].extend(file_list)
+                    if inputs is input_directories:
+                        directory_batches.add(cmd_signature)
+                    group_tools.setdefault(ext, []).append(command_tool_name(linter_cmd))
+
+                if not run_all:
                     break  # Only use the first available command
+            if not used:
+                # A tool given with --tool isn't swapped for another when it can't be used
+                unusable_tools.update(
+                    command_tool_name(c) for c in commands if command_tool_name(c) in options.tools
+                )
+
+    for tool in sorted(unusable_tools):
+        logger.error(message("tool_unusable", tool=tool))
+    if unusable_tools:
+        return 1
 
-        # Process formatting commands
-        if mode in [Mode.FORMAT, Mode.BOTH] and ext in FORMATTER_MAP:
-            for formatter_cmd in FORMATTER_MAP[ext]:
-                if formatter_cmd.available():
-                    # Use directory if supported and no custom ignores
-                    inputs = file_list
-                    if (
-                        input_directories
-                        and not has_custom_ignores
-                        and formatter_cmd.supports_directories
-                    ):
-                        inputs = input_directories
+    # Custom tools run on every file matching their patterns, alongside the built-in chains
+    kinds = ["format" if tool_map is FORMATTER_MAP else "lint" for tool_map in tool_maps(mode)]
+    for tool in custom_tools:
+        matched = {
+            ext: [file for file in file_list if tool.matches(file)]
+            for ext, file_list in file_groups.items()
+        }
+        matched = {ext: file_list for ext, file_list in matched.items() if file_list}
+        if tool.kind not in kinds or not matched or options.builtin_only:
+            continue
+        linter_cmd = custom_command(tool)
+        if not linter_cmd.available():
+            logger.warning(
+                message("custom_tool_missing", tool=tool.name, command=tool.command[0])
+            )
+            continue
 
-                    cmd, args = formatter_cmd.command(inputs)
-                    # Create a signature excluding the file arguments
-                    base_args = [arg for arg in args if arg not in inputs]
-                    cmd_signature = (cmd, tuple(base_args))
+        cmd, args = linter_cmd.command([])
+        args = command_args(linter_cmd, args, [], config, tool.kind) + options.tool_args
+        passed_to.add(f"{tool.name} ({tool.kind})")
+        cmd_signature = (cmd, tuple(args))
+        batch_criteria[cmd_signature] = success_criteria(
+            linter_cmd, configured_criteria, options.strict
+        )
+        batch_kinds[cmd_signature] = tool.kind
+        for ext, file_list in matched.items():
+            command_batches.setdefault(cmd_signature, []).extend(file_list)
+            batch_files.setdefault(cmd_signature, []).extend(file_list)
+            group_tools.setdefault(ext, []).append(tool.name)
+
+    if options.tool_args and len(passed_to) > 1:
+        logger.error(message("tool_args_ambiguous", tools=", ".join(sorted(passed_to))))
+        return 1
 
-                    if cmd_signature not in command_batches:
-                        command_batches[cmd_signature] = []
-                    command_batches[cmd_signature].extend(inputs)
-                    break  # Only use the first available command
+    # Every file is linted for leftover merge conflict markers, whatever its language
+    if "lint" in kinds and signature_tool_name(CONFLICT_CHECK) not in disabled:
+        grouped = [f for file_list in file_groups.values() for f in file_list]
+        all_files = list(dict.fromkeys(grouped + unclaimed_files))
+        if all_files:
+            command_batches[CONFLICT_CHECK] = all_files
+            batch_files[CONFLICT_CHECK] = all_files
+            batch_criteria[CONFLICT_CHECK] = SuccessCriteria()
+            batch_kinds[CONFLICT_CHECK] = "lint"
+
+    # Files that no available formatter would reindent have their indentation checked
+    # instead, as warnings that only fail the run with --strict
+    if "lint" in kinds and signature_tool_name(INDENTATION_CHECK) not in disabled:
+        unformatted = [
+            file
+            for ext, file_list in file_groups.items()
+            # TypeScript declarations go unformatted on purpose, as they're often generated
+            if ext != ".d.ts"
+            and not any(
+                formatter.available()
+                and command_tool_name(formatter) not in disabled
+                and not options.builtin_only
+                for formatter in FORMATTER_MAP.get(ext, [])
+            )
+            for file in file_list
+        ]
+        if unformatted:
+            command_batches[INDENTATION_CHECK] = unformatted
+            batch_files[INDENTATION_CHECK] = unformatted
+            batch_criteria[INDENTATION_CHECK] = SuccessCriteria(
+                fail_on_findings=bool(options.strict)
+            )
+            batch_kinds[INDENTATION_CHECK] = "lint"
+
+    # Split large file lists into chunks, each checkpointed as it finishes, so an
+    # interrupted run can pick up where it left off with --resume
+    checkpoint: Dict[str, Dict[str, Any]] = {}
+    if options.resume:
+        checkpoint = load_cache().get("checkpoint", {})
+        if not checkpoint:
+            logger.info("No interrupted run to resume, processing all files")
+    elif not options.dry_run:
+        clear_checkpoint()
+
+    runs: List[Tuple[Tuple[str, Tuple[str, ...]], List[str], List[str]]] = []
+    skipped_files = 0
+    for cmd_signature, inputs in command_batches.items():
+        covered = list(dict.fromkeys(batch_files[cmd_signature]))
+        if cmd_signature in directory_batches:
+            runs.append((cmd_signature, inputs, covered))
+            continue
+
+        pending = [f for f in covered if not is_checkpointed(checkpoint, cmd_signature, f)]
+        skipped_files += len(covered) - len(pending)
+        if not pending:
+            continue
+
+        # Per-file commands already carry their file in the signature
+        if not inputs:
+            runs.append((cmd_signature, [], pending))
+            continue
+
+        if not takes_file_arguments(cmd_signature):
+            runs.append((cmd_signature, pending, pending))
+            continue
+
+        for i in range(0, len(pending), CHECKPOINT_CHUNK_SIZE):
+            chunk = pending[i : i + CHECKPOINT_CHUNK_SIZE]
+            runs.append((cmd_signature, chunk, chunk))
+
+    if skipped_files:
+        logger.info(message("resuming", count=skipped_files))
+
+    if not runs and not command_batches:
+        hint = "no_tools" if tool_search_path.login_shell else "no_tools_login_shell"
+        logger.warning(message(hint))
+        if options.report is not None:
+            options.report.reason = "no tools available"
+        return 0
+
+    if not runs:
+        if not options.dry_run:
+            clear_checkpoint()
+        logger.info("Nothing left to do, the previous run had already finished every file")
+        if options.report is not None:
+            options.report.reason = "the interrupted run had already finished every file"
+        return 0
+
+    if options.dry_run:
+        print_dry_run(runs, batch_kinds, file_orders)
+        return 0
 
     # Execute batched commands
     exit_code = 0
 
-    # Use ThreadPoolExecutor for parallel processing
-    with ThreadPoolExecutor(max_workers=min(len(command_batches), os.cpu_count() or 1)) as executor:
-        # Submit all batched commands for processing
-        future_to_cmd = {
-            executor.submit(
-                execute_batched_command,
-                cmd_signature,
-                file_list,
-            ): cmd_signature
-            for cmd_signature, file_list in command_batches.items()
-        }
+    # With --show-context or --group-by, findings are collected and rendered uniformly at the end
+    collect = (options.show_context or options.group_by is not None) and options.output == "text"
+    diagnostics: Optional[List[Diagnostic]] = [] if collect else None
+    findings: List[Diagnostic] = []
+
+    # Formatters work on copies of the files, kept only once the formatting is wanted
+    formatting = prepare_formatting(runs, batch_kinds, mode)
+    reformatted: List[str] = []
+
+    # Tool runs for every file group go through one pool; each run's output is captured and
+    # printed whole, so output from concurrent tools never interleaves
+    workers = min(len(runs), options.jobs or os.cpu_count() or 1)
+    # Runs over a file type with its own --jobs limit also wait for a place under it
+    job_limits = {
+        ext: threading.Semaphore(jobs)
+        for ext, jobs in sorted(options.extension_jobs.items())
+        if ext in file_groups
+    }
+    limited_files = {ext: set(file_groups[ext]) for ext in job_limits}
+    # Collect results as they complete, keeping the highest exit code so the result
+    # doesn't depend on which tool happened to finish last
+    failed_runs = 0
+    tool_failed = False
+    # Exit codes of runs with findings from tools with thresholds, judged once all are in
+    thresholded: Dict[str, List[int]] = {}
+    # (signature, covered files, seconds) of each run, for --timings
+    durations: List[Tuple[Tuple[str, Tuple[str, ...]], List[str], float]] = []
+    # Set when taidy is told to stop partway, leaving whatever results are in to report
+    interrupted = False
+    future_to_run: Dict[Any, Tuple[Tuple[str, Tuple[str, ...]], List[str]]] = {}
+    with ThreadPoolExecutor(max_workers=workers) as executor:
+        try:
+            # Each phase finishes before the next starts, so formatting can come before linting
+            for phase_runs in order_runs(runs, batch_kinds, file_orders):
+                # Linting after formatting needs the formatting kept first
+                if formatting.needs_keeping(phase_runs):
+                    kept = formatting.finish(options, formats)
+                    if kept is None:
+                        return 1
+                    reformatted.extend(kept)
+                formatting.note_step(phase_runs)
+                future_to_run = {
+                    executor.submit(
+                        run_within_limits,
+                        [
+                            limit
+                            for ext, limit in job_limits.items()
+                            if not limited_files[ext].isdisjoint(covered)
+                        ],
+                        execute_timed,
+                        cmd_signature,
+                        inputs,
+                        diagnostics,
+                        options.quiet_success,
+                        findings,
+                        options.report,
+                        batch_criteria.get(cmd_signature),
+                        formatting.paths_for(cmd_signature),
+                    ): (cmd_signature, covered)
+                    for cmd_signature, inputs, covered in phase_runs
+                }
+
+                for future in as_completed(future_to_run):
+                    cmd_signature, covered = future_to_run[future]
+                    try:
+                        result, outcome, elapsed = future.result()
+                        durations.append((cmd_signature, covered, elapsed))
+                        if options.report is not None:
+                            options.report.record_result(cmd_signature[0], covered, result)
+                        tool = signature_tool_name(cmd_signature)
+                        if outcome == Outcome.FINDINGS and tool in thresholds:
+                            thresholded.setdefault(tool, []).append(result)
+                        elif result == 0:
+                            record_checkpoint(cmd_signature, covered)
+                        else:
+                            exit_code = max(exit_code, result)
+                            failed_runs += 1
+                            tool_failed = tool_failed or outcome == Outcome.ERROR
+                    except Exception as e:
+                        with output_lock:
+                            logger.error(
+                                message("command_failed", command=cmd_signature[0], error=e)
+                            )
+                        if options.report is not None:
+                            options.report.record_result(cmd_signature[0], covered, 1)
+                        exit_code = max(exit_code, 1)
+                        failed_runs += 1
+                        tool_failed = True
+        except Interrupted:
+            interrupted = True
+            exit_code = INTERRUPTED_EXIT_CODE
+            for future in future_to_run:
+                future.cancel()
+            stop_running_tools()
+            logger.warning(message("interrupted"))
+        except BaseException:
+            # Nothing has been written to the files yet, and the copies mustn't be left behind
+            stop_running_tools()
+            formatting.discard()
+            raise
+
+    # --prefer-fast orders tools by these times on later runs
+    if options.prefer_fast and durations:
+        record_tool_timings(durations, batch_kinds)
+
+    # A tool with thresholds fails on the number of findings rather than on any finding
+    for tool, results in sorted(thresholded.items()):
+        tool_findings = [d for d in findings if d.tool == tool]
+        try:
+            violations = threshold_violations(tool, tool_findings, thresholds[tool])
+        except ValueError as e:
+            # Without usable thresholds, any finding fails the tool as usual
+            logger.warning(message("thresholds_ignored", error=e))
+            violations = [f"{len(tool_findings)} findings"]
+        if violations:
+            logger.error(f"{tool}: {'; '.join(violations)}")
+            exit_code = max([exit_code] + results)
+            failed_runs += len(results)
+        elif not options.quiet_success:
+            logger.info(message("within_thresholds", tool=tool, count=len(tool_findings)))
+
+    # Formatting cut off partway is thrown away rather than half applied
+    kept = formatting.finish(options, formats and not interrupted)
+    if kept is None:
+        return 1
+    reformatted.extend(kept)
+    if reformatted and not options.quiet_success:
+        logger.info(message("reformatted", count=len(reformatted)))
+
+    if diagnostics:
+        ordered = sorted(diagnostics, key=lambda d: (d.file, d.line, d.column or 0))
+        if options.group_by == "owner":
+            print_diagnostics_by_owner(ordered, project_root, options.show_context)
+        else:
+            source_cache: Dict[str, List[str]] = {}
+            for diagnostic in ordered:
+                print(format_context(diagnostic, source_cache) + "\n")
 
-        # Collect results as they complete
-        for future in as_completed(future_to_cmd):
-            cmd_signature = future_to_cmd[future]
-            try:
-                result = future.result()
-                if result != 0:
-                    exit_code = result
-            except Exception as e:
-                with output_lock:
-                    logger.error(f"Error executing {cmd_signature[0]}: {e}")
-                exit_code = 1
+    if options.report is not None:
+        entries = load_codeowners(project_root) if options.group_by == "owner" else None
+        for finding in sorted(findings, key=lambda d: (d.file, d.line, d.column or 0)):
+            owners = None if entries is None else diagnostic_owners(finding, project_root, entries)
+            options.report.add_diagnostic(finding, owners)
+
+    if moved_findings and options.output == "text":
+        logger.info(message("moved_downgraded", count=moved_findings))
+
+    # Linting runs are scored by their findings, for the summary, reports and history
+    summary = None
+    if mode in [Mode.LINT, Mode.BOTH]:
+        summary = run_summary(mode.value, expanded_files, exit_code, findings)
+        if options.report is not None:
+            options.report.summary = summary
+
+    if options.output == "text" and not options.quiet_success:
+        elapsed = time.monotonic() - start
+        print_run_summary(file_groups, group_tools, findings, reformatted, elapsed, summary)
+
+    if options.timings:
+        # With a report on stdout, the breakdown goes to stderr alongside the progress messages
+        stream = sys.stdout if options.output == "text" else sys.stderr
+        print_timings(durations, file_groups, group_tools, stream)
+
+    if options.quiet_success and options.output == "text":
+        total_runs = len(runs)
+        if failed_runs:
+            print(message("runs_failed", failed=failed_runs, total=total_runs))
+        else:
+            print(message("runs_passed", total=total_runs))
+
+    if summary is not None:
+        if options.quiet_success and options.output == "text":
+            print(message("tidiness_score", score=summary["score"], total=summary["total"]))
+
+        if records_history(files, project_root, options) and not interrupted:
+            record_run(project_root, summary)
 
+    # An interrupted run keeps its checkpoint, so --resume can carry on from it
+    if interrupted:
+        return INTERRUPTED_EXIT_CODE
+    clear_checkpoint()
+
+    # With --exit-zero findings don't fail the run, but a tool that couldn't run still does
+    if options.exit_zero and not tool_failed:
+        return 0
     return exit_code
 
 
+def process_archive(source: str, files: List[str], mode: Mode, options: RunOptions) -> int:
+    """Process files from an archive, or its URL, unpacked into a temporary directory that
+    the run works in, so paths are relative to the archive's root. Config comes from where
+    taidy was run, never from config files in the archive."""
+    global _config_search_path
+    with tempfile.TemporaryDirectory(prefix="taidy-archive-") as directory:
+        try:
+            workspace, skipped = unpack(source, directory)
+        except (ValueError, OSError) as e:
+            logger.error(str(e))
+            return 1
+        for name in skipped:
+            logger.warning(
+                f"Skipped {name} in {source}: links and paths outside it aren't unpacked"
+            )
+        if mode not in [Mode.LINT, Mode.SPELL]:
+            logger.info("Formatting changes only the unpacked copy, which is then thrown away")
+
+        previous = os.getcwd()
+        _config_search_path = previous
+        os.chdir(workspace)
+        try:
+            return process_files(files, mode, options)
+        finally:
+            os.chdir(previous)
+            _config_search_path = None
+
+
 def analyze_project_files(directory: str = ".") -> Dict[str, Set[str]]:
     """Analyze project files and return found extensions and their tools"""
     found_extensions = set()
@@ -1198,16 +5173,9 @@ def analyze_project_files(directory: str = ".") -> Dict[str, Set[str]]:
 
     # Extract extensions and special cases
     for file_path_str in all_files:
-        file_path = Path(file_path_str)
-        ext = file_path.suffix.lower()
-
-        # Handle special cases
-        if file_path.name.lower() in ["justfile", "justfile.just"]:
-            found_extensions.add("justfile")
-        elif ext in [".yml", ".yaml"] and ".github/workflows" in str(file_path):
-            found_extensions.add(".github-workflow")
-        elif ext:
-            found_extensions.add(ext)
+        extension_key = get_extension_key(Path(file_path_str))
+        if extension_key:
+            found_extensions.add(extension_key)
 
     # Group by available vs missing tools
     result = {
@@ -1278,6 +5246,10 @@ def get_tool_suggestions(extensions: Set[str]) -> Dict[str, List[str]]:
         ".tfvars": ["terraform", "tflint"],
         ".github-workflow": ["actionlint", "yamllint", "prettier"],
         "justfile": ["just"],
+        "dockerfile": ["hadolint"],
+        "makefile": ["checkmake"],
+        "jenkinsfile": ["npm-groovy-lint"],
+        "bazel": ["buildifier"],
         ".security": ["trufflehog"],
     }
 
@@ -1302,6 +5274,10 @@ def get_tool_suggestions(extensions: Set[str]) -> Dict[str, List[str]]:
             "brew install actionlint (macOS) or go install github.com/rhymond/actionlint@latest"
         ),
         "just": "brew install just (macOS) or cargo install just",
+        "hadolint": "brew install hadolint (macOS) or https://github.com/hadolint/hadolint",
+        "checkmake": "go install github.com/mrtazz/checkmake/cmd/checkmake@latest",
+        "npm-groovy-lint": "npm install -g npm-groovy-lint",
+        "buildifier": "go install github.com/bazelbuild/buildtools/buildifier@latest",
         "trufflehog": (
             "brew install trufflehog (macOS) or "
             "go install github.com/trufflesecurity/trufflehog/v3@latest"
@@ -1325,6 +5301,7 @@ def get_tool_suggestions(extensions: Set[str]) -> Dict[str, List[str]]:
 def suggest_tools() -> int:
     """Analyze project and suggest missing tools"""
     print("🔍 Analyzing project files...")
+    configure_search_path(load_config("."), find_project_root("."))
 
     analysis = analyze_project_files()
     found_extensions = analysis["found_extensions"]
@@ -1373,6 +5350,662 @@ def suggest_tools() -> int:
     return 0
 
 
+def doctor() -> int:
+    """Check the environment taidy runs in: the tools it can find and their versions, the
+    config it reads, and the files in the project that no available tool handles"""
+    config = load_config(".")
+    root = find_project_root(".")
+    configure_search_path(config, root)
+    print(f"taidy {VERSION} on Python {sys.version.split()[0]}, in {root}")
+
+    print("\nTools:")
+    commands = [
+        linter_cmd.command([])[0]
+        for tool_map in [LINTER_MAP, FORMATTER_MAP]
+        for chain in tool_map.values()
+        for linter_cmd in chain
+    ]
+    # taidy's own interpreter, which runs its built-in checkers, is always there
+    names = sorted(set(commands) - {sys.executable})
+    width = max(len(name) for name in names)
+    for name in names:
+        if is_command_available(name):
+            version = tool_version(name)
+            detail = resolve_command(name) + (f" ({version})" if version else "")
+            print(f"  ✅ {name:<{width}}  {detail}")
+        else:
+            print(f"  ❌ {name:<{width}}  not found")
+
+    print("\nConfiguration:")
+    config_file = find_config_file(".")
+    print(f"  taidy: {config_file if config_file else 'none (using the defaults)'}")
+    user_files = [user_config_dir() / name for name in USER_CONFIG_FILES]
+    user_file = next((path for path in user_files if path.is_file()), None)
+    if user_file is not None:
+        print(f"  taidy (personal): {user_file}")
+    for where, tool in tool_config_files(root):
+        print(f"  {tool}: {where}")
+
+    analysis = analyze_project_files()
+    uncovered = analysis["missing_linters"] | analysis["missing_formatters"]
+    if not uncovered:
+        print("\nEvery file type in the project has an available tool")
+        return 0
+
+    print("\nFile types without an available tool:")
+    suggestions = get_tool_suggestions(uncovered)
+    for ext in sorted(uncovered):
+        missing = [
+            kind
+            for kind, key in [("linter", "missing_linters"), ("formatter", "missing_formatters")]
+            if ext in analysis[key]
+        ]
+        print(f"  {ext}: no {' or '.join(missing)}")
+        for suggestion in suggestions.get(ext, []):
+            print(f"    {suggestion}")
+    return 1
+
+
+def command_line(
+    linter_cmd: LinterCommand, files: List[str], config: Dict[str, Any], kind: str
+) -> List[str]:
+    """Get the command line a command would run for files, with the config's arguments
+    applied, as process_files builds it"""
+    cmd, args = linter_cmd.command(files)
+    if linter_cmd.per_file:
+        return [cmd] + command_args(linter_cmd, args, files, config, kind)
+    base_args = [arg for arg in args if arg not in files]
+    base_args = command_args(linter_cmd, base_args, [], config, kind)
+    cmd_signature = (cmd, tuple(base_args))
+    if not takes_file_arguments(cmd_signature):
+        return [cmd] + base_args
+    return [cmd] + base_args + file_arguments(cmd_signature, files)
+
+
+def which_command(args: List[str]) -> int:
+    """Handle `taidy which FILE...`, printing the command lines that would lint and format
+    each file, and why earlier tools in its chains were passed over, without running any"""
+    if not args:
+        print("Usage: taidy which FILE...", file=sys.stderr)
+        return 1
+
+    failed = False
+    for file in [arg for arg in args if arg != "--"]:
+        file_path = Path(file)
+        config = load_config(str(file_path.parent))
+        root = find_project_root(str(file_path.parent))
+        configure_search_path(config, root)
+        prefer = config.get("prefer", [])
+        disabled = config.get("disable", [])
+        conditions = config.get("when", {})
+        try:
+            custom_tools = parse_custom_tools(config)
+        except ValueError as e:
+            logger.warning(f"Ignoring custom tools: {e}")
+            custom_tools = []
+        disabled = list(disabled) + [tool.name for tool in custom_tools]
+        context = ConditionContext(1, root, current_platform())
+
+        ext = mapped_extension(get_extension_key(file_path), config.get("extensions", {}))
+        if ext is None:
+            print(printable_path(file))
+            print('  not passed to any tool, as "extensions" skips it')
+            continue
+        label = language_label(ext)
+        print(f"{printable_path(file)} ({label})" if label else printable_path(file))
+        if is_sensitive_file(file_path, config.get("sensitive", [])):
+            print("  not passed to any tool, as it may contain secrets (see --allow-sensitive)")
+            continue
+
+        chains = [("lint", ext, LINTER_MAP), ("format", ext, FORMATTER_MAP)]
+        if file_path.name in MANIFEST_FILES:
+            chains.append(("lint", file_path.name, LINTER_MAP))
+        if (root / ".editorconfig").exists():
+            chains.append(("lint", ".editorconfig", LINTER_MAP))
+
+        found = False
+        for kind, key, tool_map in chains:
+            for linter_cmd in apply_preferences(tool_map.get(key, []), prefer):
+                tool = command_tool_name(linter_cmd)
+                if tool in disabled:
+                    print(f"  {kind:<7} skip {tool}: disabled")
+                elif not conditions_met(linter_cmd, conditions, context):
+                    print(f"  {kind:<7} skip {tool}: its conditions aren't met")
+                elif not linter_cmd.available():
+                    print(f"  {kind:<7} skip {tool}: not found")
+                else:
+                    line = command_line(linter_cmd, [file], config, kind)
+                    print(f"  {kind:<7} run  {' '.join(shlex.quote(arg) for arg in line)}")
+                    found = True
+                    break
+
+        for tool in custom_tools:
+            if tool.matches(file):
+                linter_cmd = custom_command(tool)
+                if not linter_cmd.available():
+                    print(f"  {tool.kind:<7} skip {tool.name}: not found")
+                    continue
+                line = command_line(linter_cmd, [file], config, tool.kind)
+                print(f"  {tool.kind:<7} run  {' '.join(shlex.quote(arg) for arg in line)}")
+                found = True
+
+        if not found:
+            print("  no tool would run")
+            failed = True
+    return 1 if failed else 0
+
+
+def image_tools(name: str) -> List[str]:
+    """Get the tools an image bundles: for "full" every tool in every chain, otherwise the
+    first tool in each of its languages' chains that can be installed"""
+    covered = IMAGES[name]
+    keys: Optional[Set[str]] = None
+    if covered is not None:
+        keys = set()
+        for language in covered:
+            keys.update(LANGUAGES.get(language, [language]))
+
+    tools: List[str] = []
+    for tool_map in [LINTER_MAP, FORMATTER_MAP, IMPORTS_MAP, FIX_MAP, SPELL_MAP]:
+        for ext, chain in tool_map.items():
+            if keys is not None and ext not in keys:
+                continue
+            names = [command_tool_name(linter_cmd) for linter_cmd in chain]
+            installable = [tool for tool in names if tool in INSTALLS]
+            tools += installable if keys is None else installable[:1]
+    return list(dict.fromkeys(tools))
+
+
+def bundle_image_command(args: List[str]) -> int:
+    """Handle `taidy bundle-image [NAME] [--write DIR]`, printing or writing the Dockerfiles
+    of the images with taidy's tools preinstalled"""
+    usage = f"Usage: taidy bundle-image [{'|'.join(IMAGES)}] [--write DIR]"
+    directory = None
+    names = []
+    remaining = list(args)
+    while remaining:
+        arg = remaining.pop(0)
+        if arg == "--write" and remaining:
+            directory = remaining.pop(0)
+        elif arg.startswith("--write="):
+            directory = arg.split("=", 1)[1]
+        elif arg in IMAGES:
+            names.append(arg)
+        else:
+            print(usage, file=sys.stderr)
+            return 1
+
+    if directory is None:
+        if len(names) != 1:
+            print(usage, file=sys.stderr)
+            return 1
+        print(dockerfile(names[0], image_tools(names[0])), end="")
+        return 0
+
+    Path(directory).mkdir(parents=True, exist_ok=True)
+    for name in names or list(IMAGES):
+        target = Path(directory) / f"Dockerfile.{name}"
+        target.write_text(dockerfile(name, image_tools(name)))
+        print(f"Wrote {target}", file=sys.stderr)
+    return 0
+
+
+def tool_chains() -> List[Dict[str, Any]]:
+    """Describe every extension's lint and format chains: each candidate in order, whether
+    it's available and its version, and the one that would be chosen now"""
+    config = load_config(".")
+    root = find_project_root(".")
+    configure_search_path(config, root)
+    prefer = config.get("prefer", [])
+    disabled = config.get("disable", [])
+    conditions = config.get("when", {})
+    context = ConditionContext(0, root, current_platform())
+
+    entries = []
+    for ext in sorted(set(LINTER_MAP) | set(FORMATTER_MAP)):
+        entry: Dict[str, Any] = {"extension": ext, "language": language_label(ext)}
+        for kind, tool_map in [("lint", LINTER_MAP), ("format", FORMATTER_MAP)]:
+            candidates = []
+            chosen = False
+            for linter_cmd in apply_preferences(tool_map.get(ext, []), prefer):
+                cmd, args = linter_cmd.command([])
+                tool = command_tool_name(linter_cmd)
+                available = linter_cmd.available()
+                skipped = None
+                if tool in disabled:
+                    skipped = "disabled"
+                elif not conditions_met(linter_cmd, conditions, context):
+                    skipped = "conditions not met"
+                candidates.append(
+                    {
+                        "command": " ".join([cmd] + [arg for arg in args if arg != "--"]),
+                        "tool": tool,
+                        "available": available,
+                        "version": tool_version(cmd) if available else None,
+                        "skipped": skipped,
+                        "chosen": available and skipped is None and not chosen,
+                    }
+                )
+                chosen = chosen or candidates[-1]["chosen"]
+            entry[kind] = candidates
+        entries.append(entry)
+    return entries
+
+
+def tools_command(args: List[str]) -> int:
+    """Handle `taidy tools [--json]`, listing every extension's tool chains"""
+    if args not in [[], ["--json"]]:
+        print("Usage: taidy tools [--json]", file=sys.stderr)
+        return 1
+
+    entries = tool_chains()
+    if args == ["--json"]:
+        print(json.dumps({"extensions": entries}, indent=2))
+        return 0
+
+    for entry in entries:
+        print(f"{entry['extension']} ({entry['language']})")
+        for kind in ["lint", "format"]:
+            for index, candidate in enumerate(entry[kind]):
+                label = kind if index == 0 else ""
+                mark = "✅" if candidate["available"] else "❌"
+                if not candidate["available"]:
+                    detail = "not found"
+                else:
+                    detail = candidate["version"] or ""
+                if candidate["skipped"]:
+                    detail = f"{detail}, {candidate['skipped']}".lstrip(", ")
+                chosen = "  ← chosen" if candidate["chosen"] else ""
+                print(f"  {label:<7} {mark} {candidate['command']}  {detail}{chosen}".rstrip())
+    return 0
+
+
+def first_available(commands: List[LinterCommand]) -> Optional[LinterCommand]:
+    """Get the first available command in a chain"""
+    for linter_cmd in commands:
+        if linter_cmd.available():
+            return linter_cmd
+    return None
+
+
+def extension_files_pattern(extensions: List[str]) -> str:
+    """Build a pre-commit `files` regex matching taidy's extension keys"""
+    patterns = []
+    suffixes = []
+    for ext in sorted(extensions):
+        names = [re.escape(name) for name, key in FILENAME_KEYS.items() if key == ext]
+        if names:
+            patterns.append(rf"(^|/)({'|'.join(names)})$")
+        if ext == "justfile":
+            patterns.append(r"(^|/)[Jj]ustfile$")
+        elif ext == "dockerfile":
+            patterns.append(r"(^|/)Dockerfile\.[^/]*$|\.[Dd]ockerfile$")
+        elif ext in FILENAME_KEYS.values() and not ext.startswith("."):
+            continue
+        elif ext == ".github-workflow":
+            patterns.append(r"^\.github/workflows/.*\.ya?ml$")
+        else:
+            suffixes.append(re.escape(ext.lstrip(".")))
+
+    if suffixes:
+        patterns.insert(0, rf"\.({'|'.join(suffixes)})$")
+    return "|".join(patterns)
+
+
+def export_pre_commit() -> str:
+    """Render the active lint and format chains as a .pre-commit-config.yaml"""
+    prefer = load_config(".").get("prefer", [])
+
+    # Group extensions by the command that would run for them, as process_files batches them
+    hooks: Dict[Tuple[str, str, Tuple[str, ...]], List[str]] = {}
+    for kind, command_map in [("lint", LINTER_MAP), ("format", FORMATTER_MAP)]:
+        for ext, commands in command_map.items():
+            if ext == ".security":
+                continue  # trufflehog scans the whole repository rather than staged files
+            linter_cmd = first_available(apply_preferences(commands, prefer))
+            if linter_cmd:
+                cmd, args = linter_cmd.command([])
+                hooks.setdefault((kind, cmd, tuple(args)), []).append(ext)
+
+    lines = [
+        "# Generated by `taidy export pre-commit` from the tools available on this machine",
+        "repos:",
+        "  - repo: local",
+        "    hooks:",
+    ]
+    used_ids: Set[str] = set()
+    for (kind, cmd, args), extensions in hooks.items():
+        hook_id = f"{cmd}-{kind}"
+        unique_id = hook_id
+        counter = 2
+        while unique_id in used_ids:
+            unique_id = f"{hook_id}-{counter}"
+            counter += 1
+        used_ids.add(unique_id)
+
+        lines += [
+            f"      - id: {unique_id}",
+            f"        name: {cmd} ({kind})",
+            f"        entry: {json.dumps(' '.join([cmd] + list(args)))}",
+            "        language: system",
+            f"        files: {json.dumps(extension_files_pattern(extensions))}",
+        ]
+        if cmd == "just":
+            lines.append("        pass_filenames: false")
+
+    return "\n".join(lines) + "\n"
+
+
+def export_command(args: List[str]) -> int:
+    """Handle `taidy export` subcommands"""
+    if not args or args[0] != "pre-commit":
+        print("Usage: taidy export pre-commit [--write]", file=sys.stderr)
+        return 1
+
+    config = export_pre_commit()
+    if "--write" not in args[1:]:
+        print(config, end="")
+        return 0
+
+    target = find_project_root(".") / ".pre-commit-config.yaml"
+    if target.exists() and "--force" not in args[1:]:
+        print(f"{target} already exists; use --force to overwrite it", file=sys.stderr)
+        return 1
+    target.write_text(config)
+    print(f"Wrote {target}", file=sys.stderr)
+    return 0
+
+
+def project_template(config: Dict[str, Any]) -> Project:
+    """Describe the project for `taidy init`'s templates: its languages (the config's, or
+    those of the files found), the smallest taidy image with their tools, and a regex for
+    the files taidy's chains cover"""
+    keys = config_languages(config)
+    if keys is None:
+        overrides = config.get("extensions", {})
+        found = {
+            mapped_extension(get_extension_key(Path(file)), overrides)
+            for file in discover_files_in_directory(".")
+        }
+        keys = {key for key in found if key is not None}
+    keys = {key for key in keys if key in LINTER_MAP or key in FORMATTER_MAP}
+
+    image = "full"
+    for name, covered in IMAGES.items():
+        if covered is None:
+            continue
+        covered_keys = {key for language in covered for key in LANGUAGES.get(language, [language])}
+        if keys <= covered_keys:
+            image = name
+            break
+
+    return Project(
+        languages=sorted({language_label(key) for key in keys}),
+        image=f"taidy/{image}",
+        files_pattern=extension_files_pattern(sorted(keys)),
+    )
+
+
+def init_command(args: List[str]) -> int:
+    """Handle `taidy init --template NAME [--force]`, writing a CI workflow or hook config
+    for the project"""
+    template = None
+    force = False
+    remaining = list(args)
+    while remaining:
+        arg = remaining.pop(0)
+        if arg == "--template" and remaining:
+            template = remaining.pop(0)
+        elif arg.startswith("--template="):
+            template = arg.split("=", 1)[1]
+        elif arg == "--force":
+            force = True
+        else:
+            template = None
+            break
+
+    if template not in TEMPLATES:
+        print(INIT_USAGE, file=sys.stderr)
+        return 1
+
+    project = project_template(load_config("."))
+    target = find_project_root(".") / TARGETS[template]
+    if target.exists() and not force:
+        print(f"{target} already exists; use --force to overwrite it", file=sys.stderr)
+        return 1
+    target.parent.mkdir(parents=True, exist_ok=True)
+    target.write_text(TEMPLATES[template](project))
+    print(f"Wrote {target} for {', '.join(project.languages) or 'no languages'}", file=sys.stderr)
+    return 0
+
+
+def config_command(args: List[str]) -> int:
+    """Handle `taidy config` subcommands"""
+    if not args or args[0] != "import":
+        print("Usage: taidy config import [--write]", file=sys.stderr)
+        return 1
+
+    root = find_project_root(".")
+    for source, tools in detect_project_tools(root).items():
+        if tools:
+            print(f"Found in {source}: {', '.join(sorted(set(tools)))}", file=sys.stderr)
+
+    scaffold = scaffold_config(root)
+    if "--write" not in args[1:]:
+        print(json.dumps(scaffold, indent=2))
+        return 0
+
+    # Merge into any existing config so ignore patterns and other settings survive
+    config_file = root / ".taidy.json"
+    # Read the project file alone, so personal defaults aren't copied into it
+    config = read_config_file(config_file) if config_file.exists() else {}
+    config.update(scaffold)
+    with open(config_file, "w") as f:
+        json.dump(config, f, indent=2)
+        f.write("\n")
+    print(f"Wrote {config_file}", file=sys.stderr)
+    return 0
+
+
+# What `taidy import` converts: the file it reads and how
+IMPORT_SOURCES: Dict[str, Tuple[str, Callable[[str], Tuple[Dict[str, Any], List[str]]]]] = {
+    "pre-commit": (".pre-commit-config.yaml", import_pre_commit),
+    "lint-staged": ("package.json", import_lint_staged),
+}
+
+
+def import_command(args: List[str]) -> int:
+    """Handle `taidy import pre-commit|lint-staged`, converting another tool's setup into a
+    .taidy.toml"""
+    if not args or args[0] not in IMPORT_SOURCES:
+        print(
+            f"Usage: taidy import {'|'.join(IMPORT_SOURCES)} [--write] [--force]",
+            file=sys.stderr,
+        )
+        return 1
+
+    root = find_project_root(".")
+    filename, convert = IMPORT_SOURCES[args[0]]
+    source = root / filename
+    if not source.exists():
+        print(f"No {source.name} in {root}", file=sys.stderr)
+        return 1
+
+    try:
+        config, skipped = convert(source.read_text())
+    except ValueError as e:
+        print(f"Error: {e}", file=sys.stderr)
+        return 1
+    comments = [f"Converted from {source.name} by `taidy import {args[0]}`"]
+    if skipped:
+        comments.append(f"Not converted: {', '.join(skipped)}")
+    toml = to_toml(config, comments)
+    if "--write" not in args[1:]:
+        print(toml, end="")
+        return 0
+
+    target = root / ".taidy.toml"
+    if target.exists() and "--force" not in args[1:]:
+        print(f"{target} already exists; use --force to overwrite it", file=sys.stderr)
+        return 1
+    target.write_text(toml)
+    print(f"Wrote {target}", file=sys.stderr)
+    # Config files are read in order, so an earlier one hides the new file
+    for name in CONFIG_FILES[: CONFIG_FILES.index(target.name)]:
+        if (root / name).exists():
+            print(f"Note: {name} is read instead of {target.name}", file=sys.stderr)
+    return 0
+
+
+def license_command(args: List[str]) -> int:
+    """Handle `taidy license --check/--fix`"""
+    fix = "--fix" in args
+    paths = [arg for arg in args if not arg.startswith("--")]
+    if not fix and "--check" not in args:
+        print("Usage: taidy license --check|--fix [files_or_directories...]", file=sys.stderr)
+        return 1
+
+    template = load_config(".").get("license_header")
+    if not template:
+        print('No "license_header" template configured', file=sys.stderr)
+        return 1
+
+    files: List[str] = []
+    for path in paths or ["."]:
+        if os.path.isdir(path):
+            files.extend(discover_files_in_directory(path))
+        elif os.path.exists(path):
+            files.append(path)
+        else:
+            logger.warning(f"Path {path} does not exist, skipping")
+
+    return check_license_headers(
+        [(Path(file), get_extension_key(Path(file))) for file in files], template, fix
+    )
+
+
+def request_options(params: Dict[str, Any]) -> RunOptions:
+    """Turn a protocol request's options into run options, as if given as flags"""
+    settings = params.get("options", {})
+    if not isinstance(settings, dict):
+        raise ProtocolError(INVALID_PARAMS, "options must be an object")
+
+    args = []
+    for name, value in settings.items():
+        flag = "--" + name.replace("_", "-")
+        if value is True:
+            args.append(flag)
+        elif isinstance(value, list):
+            args.append(f"{flag}={','.join(str(item) for item in value)}")
+        elif value is not False and value is not None:
+            args.append(f"{flag}={value}")
+
+    try:
+        options, positional = parse_flags(args)
+    except ValueError as e:
+        raise ProtocolError(INVALID_PARAMS, str(e))
+    if positional:
+        raise ProtocolError(INVALID_PARAMS, f"Unknown options: {', '.join(positional)}")
+    return options
+
+
+@contextlib.contextmanager
+def captured_output(output: IO[str]) -> Iterator[None]:
+    """Send everything a run prints, logging included, to output instead of the terminal"""
+    streams = [h for h in logger.handlers if isinstance(h, logging.StreamHandler)]
+    previous = [handler.setStream(output) for handler in streams]
+    try:
+        with contextlib.redirect_stdout(output), contextlib.redirect_stderr(output):
+            yield
+    finally:
+        for handler, stream in zip(streams, previous):
+            handler.setStream(stream)
+
+
+def daemon_run(mode: Mode, params: Dict[str, Any]) -> Dict[str, Any]:
+    """Handle a lint or format request, capturing everything the run would have printed"""
+    files = request_files(params)
+    options = request_options(params)
+    options.output = "text"
+    options.record_history = False
+    options.report = Report(quiet=False)
+    select_preset(options.preset)
+    skip_tools(options.skip_tools)
+    enable_ci_mode(bool(options.ci))
+
+    output = io.StringIO()
+    with captured_output(output):
+        exit_code = process_files(files, mode, options)
+
+    result: Dict[str, Any] = {"exit_code": exit_code, "output": output.getvalue()}
+    if mode == Mode.LINT:
+        result["diagnostics"] = options.report.diagnostics
+    return result
+
+
+def daemon_command(args: List[str]) -> int:
+    """Handle `taidy daemon [--socket PATH]`, serving requests until one asks for shutdown"""
+    path = DEFAULT_SOCKET_PATH
+    if args[:1] == ["--socket"] and len(args) == 2:
+        path = args[1]
+    elif args:
+        print("Usage: taidy daemon [--socket PATH]", file=sys.stderr)
+        return 1
+
+    extensions = sorted(set(LINTER_MAP) | set(FORMATTER_MAP))
+    handlers = {
+        "capabilities": lambda params: {
+            "protocol_version": PROTOCOL_VERSION,
+            "taidy_version": VERSION,
+            "methods": METHODS,
+            "extensions": extensions,
+        },
+        "lint": lambda params: daemon_run(Mode.LINT, params),
+        "format": lambda params: daemon_run(Mode.FORMAT, params),
+    }
+
+    logger.info(f"Listening on {path}")
+    try:
+        serve_socket(path, handlers)
+    except OSError as e:
+        logger.error(f"Daemon failed: {e}")
+        return 1
+    except KeyboardInterrupt:
+        pass
+    return 0
+
+
+CLIENT_USAGE = "Usage: taidy client [--socket PATH] METHOD [PARAMS_JSON]"
+
+
+def client_command(args: List[str]) -> int:
+    """Handle `taidy client [--socket PATH] METHOD [PARAMS_JSON]`, making one request of
+    a running daemon and printing its result"""
+    path = DEFAULT_SOCKET_PATH
+    if args[:1] == ["--socket"] and len(args) > 1:
+        path = args[1]
+        args = args[2:]
+    if len(args) not in [1, 2]:
+        print(CLIENT_USAGE, file=sys.stderr)
+        return 1
+
+    params = load_json(args[1]) if len(args) == 2 else {}
+    if not isinstance(params, dict):
+        print("Error: PARAMS_JSON must be a JSON object", file=sys.stderr)
+        return 1
+
+    try:
+        result = connect(path).call(args[0], params)
+    except OSError as e:
+        print(f"Error: no daemon answering on {path}: {e}", file=sys.stderr)
+        return 1
+    except ProtocolError as e:
+        print(f"Error: {e.message} ({e.code})", file=sys.stderr)
+        return 1
+    print(json.dumps(result, indent=2))
+    return 0
+
+
 def docker_run(args: List[str]) -> int:
     """Run taidy in Docker container with all tools pre-installed"""
     docker_image = "taidy:latest"
@@ -1435,22 +6068,69 @@ def docker_run(args: List[str]) -> int:
         return result.returncode
     except KeyboardInterrupt:
         print("\n⚠️  Interrupted by user")
-        return 130
+        return INTERRUPTED_EXIT_CODE
     except Exception as e:
         print(f"❌ Error running Docker container: {e}", file=sys.stderr)
         return 1
 
 
+def check_run_lock(report: Report, options: RunOptions) -> bool:
+    """Write the run's lock for --record, and warn about how it differs from the lock
+    given to --replay. Returns False if either lock couldn't be read or written."""
+    output_digests = {file: file_digest(file) for file in report.input_digests}
+    lock = build_lock(report, output_digests, VERSION)
+    succeeded = True
+    if options.replay:
+        try:
+            differences = lock_differences(read_lock(options.replay), lock)
+        except ValueError as e:
+            logger.error(f"Can't replay: {e}")
+            succeeded = False
+        else:
+            for difference in differences:
+                logger.warning(f"Differs from {options.replay}: {difference}")
+            if not differences:
+                logger.info(f"The run matches {options.replay}")
+    if options.record:
+        try:
+            write_lock(options.record, lock)
+            logger.info(f"Recorded the run in {options.record}")
+        except OSError as e:
+            logger.error(f"Failed to record the run in {options.record}: {e}")
+            succeeded = False
+    return succeeded
+
+
+def daemon_main() -> None:
+    """Entry point of the taidy-daemon script, the same as `taidy daemon`"""
+    setup_logging()
+    sys.exit(daemon_command(sys.argv[1:]))
+
+
+def lsp_main() -> None:
+    """Entry point of the taidy-lsp script, the same as `taidy lsp`, for editors that want
+    a server command without arguments"""
+    setup_logging()
+    sys.exit(lsp_command(sys.argv[1:]))
+
+
+def render_report(report: Report, report_format: str, exit_code: int) -> str:
+    """Render a run's report in one of the --output or --report formats"""
+    if report_format == "sarif":
+        return to_sarif(report)
+    if report_format == "plain-verbose":
+        return to_plain_verbose(report, exit_code)
+    if report_format in ["junit", "junit-rule"]:
+        return to_junit(report, by_rule=report_format == "junit-rule")
+    return report.to_json(exit_code)
+
+
 def main() -> None:
     """Main entry point"""
     setup_logging()
 
-    if len(sys.argv) < 2:
-        show_usage()
-        sys.exit(1)
-
     # Handle version and help flags
-    arg = sys.argv[1]
+    arg = sys.argv[1] if len(sys.argv) > 1 else ""
     if arg in ["-v", "--version"]:
         show_version()
         sys.exit(0)
@@ -1458,37 +6138,188 @@ def main() -> None:
         show_help()
         sys.exit(0)
 
-    # Parse command and files
+    # Docker passes its arguments through untouched to taidy inside the container
+    if arg == "docker":
+        if len(sys.argv) < 3:
+            show_usage()
+            sys.exit(1)
+        exit_code = docker_run(sys.argv[2:])
+        sys.exit(exit_code)
+
+    # A newer command's name that is also a path here, such as a tools/ directory, is
+    # processed as the path rather than shadowing it
+    subcommand = "" if os.path.exists(arg) else arg
+
+    if subcommand == "explain":
+        sys.exit(explain_command(sys.argv[2:]))
+
+    if subcommand == "explain-rule":
+        sys.exit(explain_rule(sys.argv[2:]))
+
+    if subcommand == "sync-ignores":
+        patterns = load_config(".").get("ignore", [])
+        sys.exit(sync_ignores(find_project_root("."), patterns, check="--check" in sys.argv[2:]))
+
+    if subcommand == "config":
+        sys.exit(config_command(sys.argv[2:]))
+
+    if subcommand == "import":
+        sys.exit(import_command(sys.argv[2:]))
+
+    if subcommand == "export":
+        sys.exit(export_command(sys.argv[2:]))
+
+    if subcommand == "init":
+        sys.exit(init_command(sys.argv[2:]))
+
+    if subcommand == "license":
+        sys.exit(license_command(sys.argv[2:]))
+
+    if subcommand == "generate":
+        if sys.argv[2:3] != ["hooks"]:
+            print(GENERATE_USAGE, file=sys.stderr)
+            sys.exit(1)
+        sys.exit(generate_hooks(sys.argv[3:]))
+
+    if subcommand == "install-hooks":
+        sys.exit(install_hooks(sys.argv[2:]))
+
+    if subcommand == "audit":
+        sys.exit(audit(sys.argv[2:]))
+
+    if subcommand == "bundle-image":
+        sys.exit(bundle_image_command(sys.argv[2:]))
+
+    if subcommand == "which":
+        sys.exit(which_command(sys.argv[2:]))
+
+    if subcommand == "tools":
+        sys.exit(tools_command(sys.argv[2:]))
+
+    if subcommand == "doctor":
+        sys.exit(doctor())
+
+    if subcommand == "trends":
+        sys.exit(trends(find_project_root("."), sys.argv[2:]))
+
+    if subcommand == "compare":
+        sys.exit(compare(sys.argv[2:]))
+
+    if subcommand == "daemon":
+        sys.exit(daemon_command(sys.argv[2:]))
+
+    if subcommand == "client":
+        sys.exit(client_command(sys.argv[2:]))
+
+    if subcommand == "lsp":
+        sys.exit(lsp_command(sys.argv[2:]))
+
+    # Parse flags, then command and files
+    try:
+        options, args = parse_flags(sys.argv[1:])
+    except ValueError as e:
+        print(f"Error: {e}", file=sys.stderr)
+        show_usage()
+        sys.exit(1)
+
+    # The preset applies wherever config is loaded, file discovery included
+    select_preset(options.preset)
+    skip_tools(options.skip_tools)
+    enable_ci_mode(bool(options.ci))
+    enable_colourless_tools(options.output == "plain-verbose")
+    enable_show_env(options.show_env)
+
+    # Progress messages move to stderr, so stdout holds nothing but the report
+    if options.output != "text" or options.stdout or options.stdin:
+        for handler in logger.handlers:
+            if isinstance(handler, logging.StreamHandler):
+                handler.setStream(sys.stderr)
+
+    # `taidy hook` is `taidy --staged`, for use as a git pre-commit hook
+    if args and args[0] == "hook" and not os.path.exists("hook"):
+        options.staged = True
+        args = args[1:]
+
+    # Content piped in for a named file, as editors send unsaved buffers
+    if options.stdin:
+        if args[:1] not in [["lint"], ["format"]] or len(args) > 1 or not options.stdin_filename:
+            print(
+                "Error: --stdin needs `taidy lint` or `taidy format`, --stdin-filename "
+                "and no other files",
+                file=sys.stderr,
+            )
+            sys.exit(1)
+        sys.exit(process_stdin(Mode(args[0]), options))
+
+    # Bare `taidy` inside a git repository processes the whole repository, like `taidy .`,
+    # and an archive is processed whole in the same way
+    in_repository = is_git_repository(Path.cwd()) or options.from_archive is not None
+
+    if not args:
+        if not in_repository:
+            show_usage()
+            sys.exit(1)
+        args = ["."]
+
     mode = Mode.BOTH
     files = []
 
-    if sys.argv[1] == "lint":
+    if args[0] == "lint":
         mode = Mode.LINT
-        if len(sys.argv) < 3:
+        if len(args) < 2 and not in_repository:
             show_usage()
             sys.exit(1)
-        files = sys.argv[2:]
-    elif sys.argv[1] == "format":
+        files = args[1:] or ["."]
+    elif args[0] == "format":
         mode = Mode.FORMAT
-        if len(sys.argv) < 3:
+        if len(args) < 2 and not in_repository:
             show_usage()
             sys.exit(1)
-        files = sys.argv[2:]
-    elif sys.argv[1] == "suggest":
-        exit_code = suggest_tools()
-        sys.exit(exit_code)
-    elif sys.argv[1] == "docker":
-        if len(sys.argv) < 3:
+        files = args[1:] or ["."]
+    elif args[0] in ["imports", "spell", "fix"] and not os.path.exists(args[0]):
+        mode = Mode(args[0])
+        if len(args) < 2 and not in_repository:
             show_usage()
             sys.exit(1)
-        exit_code = docker_run(sys.argv[2:])
+        files = args[1:] or ["."]
+    elif args[0] == "suggest":
+        exit_code = suggest_tools()
         sys.exit(exit_code)
     else:
         # No subcommand, treat first arg as file
         mode = Mode.BOTH
-        files = sys.argv[1:]
+        files = args
+
+    if options.stdout:
+        if mode != Mode.FORMAT or len(files) != 1 or not os.path.isfile(files[0]):
+            print("Error: --stdout needs `taidy format` and a single file", file=sys.stderr)
+            sys.exit(1)
+        sys.exit(format_to_stdout(files[0], options))
 
-    exit_code = process_files(files, mode)
+    # A CI runner's timeout sends SIGTERM; the results so far are still reported
+    signal.signal(signal.SIGTERM, raise_interrupted)
+    try:
+        if options.from_archive is not None:
+            exit_code = process_archive(options.from_archive, files, mode, options)
+        else:
+            exit_code = process_files(files, mode, options)
+    except Interrupted:
+        exit_code = INTERRUPTED_EXIT_CODE
+    signal.signal(signal.SIGTERM, signal.SIG_DFL)
+    if options.report is not None:
+        options.report.mode = mode.value
+        if options.output != "text":
+            print(render_report(options.report, options.output, exit_code))
+        for report_format, path in options.report_files:
+            rendered = render_report(options.report, report_format, exit_code)
+            try:
+                Path(path).parent.mkdir(parents=True, exist_ok=True)
+                Path(path).write_text(rendered + "\n")
+            except OSError as e:
+                logger.error(f"Failed to write {report_format} report to {path}: {e}")
+                exit_code = exit_code or 1
+        if (options.record or options.replay) and not check_run_lock(options.report, options):
+            exit_code = exit_code or 1
     sys.exit(exit_code)
 
 
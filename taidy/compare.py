@@ -0,0 +1,125 @@
+"""Compare two JSON reports, as written by --report json=PATH, for a "no new findings" gate
+in CI without a server to keep the baseline:
+
+    taidy compare main.json branch.json
+
+Findings are matched by file, tool, rule and message rather than by line, so a finding
+that merely moved when lines were added above it still counts as the same one.
+"""
+
+import json
+import os
+from pathlib import Path
+from typing import Any, Dict, List, Tuple
+
+FindingKey = Tuple[str, str, str, str]
+
+
+def load_findings(path: str) -> List[Dict[str, Any]]:
+    """Read the findings from a JSON report, raising ValueError if it isn't one"""
+    try:
+        document = json.loads(Path(path).read_text())
+    except OSError as e:
+        raise ValueError(f"Couldn't read {path}: {e.strerror}") from None
+    except ValueError:
+        raise ValueError(f"{path} isn't JSON") from None
+    if not isinstance(document, dict) or not isinstance(document.get("diagnostics"), list):
+        raise ValueError(f"{path} isn't a taidy JSON report")
+    return [entry for entry in document["diagnostics"] if isinstance(entry, dict)]
+
+
+def finding_key(entry: Dict[str, Any]) -> FindingKey:
+    """Identify a finding without its position"""
+    return (
+        os.path.normpath(str(entry.get("file", ""))),
+        str(entry.get("tool", "")),
+        str(entry.get("rule") or ""),
+        str(entry.get("message", "")),
+    )
+
+
+def compare_findings(
+    old: List[Dict[str, Any]], new: List[Dict[str, Any]]
+) -> Tuple[List[Dict[str, Any]], List[Dict[str, Any]], List[Dict[str, Any]]]:
+    """Split findings into those only in the new report, those only in the old one, and
+    those in both (as the new report has them). Matching findings on the same line are
+    paired first, then the rest in line order."""
+    old_by_key: Dict[FindingKey, List[Dict[str, Any]]] = {}
+    for entry in old:
+        old_by_key.setdefault(finding_key(entry), []).append(entry)
+
+    introduced: List[Dict[str, Any]] = []
+    persisting: List[Dict[str, Any]] = []
+    unmatched: Dict[FindingKey, List[Dict[str, Any]]] = {}
+    for entry in new:
+        candidates = old_by_key.get(finding_key(entry), [])
+        same_line = [c for c in candidates if c.get("line") == entry.get("line")]
+        if same_line:
+            candidates.remove(same_line[0])
+            persisting.append(entry)
+        else:
+            unmatched.setdefault(finding_key(entry), []).append(entry)
+
+    for key, entries in unmatched.items():
+        candidates = old_by_key.get(key, [])
+        candidates.sort(key=lambda c: c.get("line") or 0)
+        for entry in sorted(entries, key=lambda e: e.get("line") or 0):
+            if candidates:
+                candidates.pop(0)
+                persisting.append(entry)
+            else:
+                introduced.append(entry)
+
+    fixed = [entry for entries in old_by_key.values() for entry in entries]
+    return sort_findings(introduced), sort_findings(fixed), sort_findings(persisting)
+
+
+def sort_findings(entries: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+    """Order findings by file and position"""
+    return sorted(
+        entries,
+        key=lambda e: (str(e.get("file", "")), e.get("line") or 0, e.get("column") or 0),
+    )
+
+
+def describe(entry: Dict[str, Any]) -> str:
+    """Render a finding on one line, as --show-context heads them"""
+    column = f":{entry['column']}" if entry.get("column") else ""
+    rule = f"{entry['rule']} " if entry.get("rule") else ""
+    return (
+        f"{entry.get('file')}:{entry.get('line')}{column}: {rule}{entry.get('message')} "
+        f"[{entry.get('tool')}]"
+    )
+
+
+def compare(args: List[str]) -> int:
+    """Handle `taidy compare OLD.json NEW.json [--json]`, failing if NEW has findings
+    OLD didn't"""
+    as_json = "--json" in args
+    paths = [arg for arg in args if arg != "--json"]
+    if len(paths) != 2:
+        print("Usage: taidy compare OLD.json NEW.json [--json]")
+        return 1
+
+    try:
+        old, new = load_findings(paths[0]), load_findings(paths[1])
+    except ValueError as e:
+        print(f"Error: {e}")
+        return 1
+    introduced, fixed, persisting = compare_findings(old, new)
+
+    if as_json:
+        result = {"new": introduced, "fixed": fixed, "persisting": persisting}
+        print(json.dumps(result, indent=2))
+    else:
+        for title, entries in [("New", introduced), ("Fixed", fixed)]:
+            if entries:
+                print(f"{title} ({len(entries)}):")
+                for entry in entries:
+                    print(f"  {describe(entry)}")
+                print()
+        print(
+            f"{len(introduced)} new, {len(fixed)} fixed, {len(persisting)} persisting "
+            f"since {paths[0]}"
+        )
+    return 1 if introduced else 0
@@ -0,0 +1,68 @@
+"""Conditions on tool chain entries, beyond whether the tool is installed.
+
+A condition is a short expression, and an entry is used only when all of its hold:
+
+    files < 50           the number of files the tool would run on (<, <=, >, >=, ==, !=)
+    exists tsconfig.json a file matching the glob exists in the project root
+    os != windows        the operating system: windows, macos or linux (== or !=)
+    env CI               the environment variable is set and not empty
+    not exists .nolint   any condition, negated
+"""
+
+import glob
+import operator
+import os
+import sys
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Callable, Dict
+
+COMPARISONS: Dict[str, Callable[[int, int], bool]] = {
+    "<": operator.lt,
+    "<=": operator.le,
+    ">": operator.gt,
+    ">=": operator.ge,
+    "==": operator.eq,
+    "!=": operator.ne,
+}
+
+PLATFORMS = ["windows", "macos", "linux"]
+
+
+@dataclass
+class ConditionContext:
+    """What conditions are judged against, for one chain entry and the files it would run on"""
+
+    file_count: int
+    root: Path
+    platform: str
+
+
+def current_platform() -> str:
+    """Name the operating system as conditions do"""
+    if sys.platform.startswith("win"):
+        return "windows"
+    if sys.platform == "darwin":
+        return "macos"
+    return "linux"
+
+
+def evaluate_condition(condition: str, context: ConditionContext) -> bool:
+    """Check whether a condition holds, raising ValueError if it's malformed"""
+    words = condition.split()
+    if words[:1] == ["not"]:
+        return not evaluate_condition(" ".join(words[1:]), context)
+
+    if len(words) == 3 and words[0] == "files" and words[1] in COMPARISONS:
+        if not words[2].isdigit():
+            raise ValueError(f'condition "{condition}": expected a whole number of files')
+        return COMPARISONS[words[1]](context.file_count, int(words[2]))
+    if len(words) == 3 and words[0] == "os" and words[1] in ["==", "!="]:
+        if words[2] not in PLATFORMS:
+            raise ValueError(f'condition "{condition}": os is one of {", ".join(PLATFORMS)}')
+        return (context.platform == words[2]) == (words[1] == "==")
+    if len(words) == 2 and words[0] == "exists":
+        return bool(glob.glob(str(context.root / words[1])))
+    if len(words) == 2 and words[0] == "env":
+        return bool(os.environ.get(words[1]))
+    raise ValueError(f'condition "{condition}": expected files, exists, os or env')
@@ -0,0 +1,60 @@
+"""Check files for merge conflict markers left behind after resolving a conflict.
+
+No language's tools reliably catch these, so taidy lints every file for them itself.
+A `=======` line only counts between `<<<<<<<` and `>>>>>>>`, since on its own it's
+as likely to underline a Markdown heading. Files that look binary are skipped.
+
+    python -m taidy.conflicts FILE...
+"""
+
+import sys
+from typing import List
+
+# Each marker, followed by a space and a label or by the end of the line
+OPENING = "<<<<<<<"
+BASE = "|||||||"
+SEPARATOR = "======="
+CLOSING = ">>>>>>>"
+
+
+def is_marker(line: str, marker: str) -> bool:
+    """Check whether a line is a conflict marker, as git writes them"""
+    return line == marker or line.startswith(marker + " ")
+
+
+def conflict_markers(path: str) -> List[str]:
+    """Describe each conflict marker in a file"""
+    try:
+        with open(path, "rb") as f:
+            data = f.read()
+    except OSError as e:
+        return [f"{path}:1: {e.strerror}"]
+    if b"\0" in data[:8192]:
+        return []
+
+    found = []
+    in_conflict = False
+    for number, line in enumerate(data.decode(errors="replace").splitlines(), 1):
+        line = line.rstrip("\r")
+        if is_marker(line, OPENING):
+            in_conflict = True
+        elif is_marker(line, CLOSING):
+            in_conflict = False
+        elif not (in_conflict and (is_marker(line, BASE) or line == SEPARATOR)):
+            continue
+        found.append(f"{path}:{number}:1: merge conflict marker {line[:7]}")
+    return found
+
+
+def main(paths: List[str]) -> int:
+    """Check each file, printing every conflict marker found"""
+    failed = False
+    for path in paths:
+        for marker in conflict_markers(path):
+            print(marker)
+            failed = True
+    return 1 if failed else 0
+
+
+if __name__ == "__main__":
+    sys.exit(main(sys.argv[1:]))
@@ -0,0 +1,82 @@
+"""Tools the project defines itself, in the config's "tools" table, run on the files
+matching their patterns alongside taidy's built-in chains:
+
+    "tools": {"stylelint": {"command": "stylelint --fix", "files": ["*.css"], "kind": "format"}}
+
+"command" is a string or a list, and gets the matching files added to its end. "files"
+holds glob patterns, with {a,b} alternatives, matched against each file's path and its
+name. "kind" is "lint" (the default) or "format", deciding which commands run it.
+"""
+
+import fnmatch
+import re
+import shlex
+from dataclasses import dataclass
+from typing import Any, Dict, List
+
+KINDS = ["lint", "format"]
+
+
+def expand_braces(pattern: str) -> List[str]:
+    """Expand {a,b} alternatives in a glob, which fnmatch doesn't understand"""
+    match = re.search(r"\{([^{}]*)\}", pattern)
+    if match is None:
+        return [pattern]
+    return [
+        expanded
+        for choice in match.group(1).split(",")
+        for expanded in expand_braces(pattern[: match.start()] + choice + pattern[match.end() :])
+    ]
+
+
+@dataclass
+class CustomTool:
+    """A tool from the config's "tools" table"""
+
+    name: str
+    command: List[str]
+    patterns: List[str]
+    kind: str = "lint"
+
+    def matches(self, path: str) -> bool:
+        """Check whether a file is one the tool runs on"""
+        path = path.replace("\\", "/")
+        while path.startswith("./"):
+            path = path[2:]
+        name = path.rsplit("/", 1)[-1]
+        return any(
+            fnmatch.fnmatch(path, pattern)
+            or fnmatch.fnmatch(name, pattern)
+            # ** also matches no directories at all, as in src/**/*.js for src/a.js
+            or fnmatch.fnmatch(path, pattern.replace("**/", ""))
+            for pattern in self.patterns
+        )
+
+
+def parse_custom_tools(config: Dict[str, Any]) -> List[CustomTool]:
+    """Read the config's "tools" table, raising ValueError for a malformed entry"""
+    table = config.get("tools", {})
+    if not isinstance(table, dict):
+        raise ValueError('"tools" must map tool names to their definitions')
+
+    tools = []
+    for name, definition in table.items():
+        if not isinstance(definition, dict):
+            raise ValueError(f'tool "{name}" must be a table with "command" and "files"')
+        command = definition.get("command")
+        if isinstance(command, str):
+            command = shlex.split(command)
+        if not isinstance(command, list) or not command:
+            raise ValueError(f'tool "{name}" needs a "command"')
+        files = definition.get("files")
+        if isinstance(files, str):
+            files = [files]
+        if not isinstance(files, list) or not files:
+            raise ValueError(f'tool "{name}" needs "files" patterns to run on')
+        kind = definition.get("kind", "lint")
+        if kind not in KINDS:
+            raise ValueError(f'tool "{name}": "kind" must be one of: {", ".join(KINDS)}')
+
+        patterns = [expanded for pattern in files for expanded in expand_braces(str(pattern))]
+        tools.append(CustomTool(str(name), [str(part) for part in command], patterns, kind))
+    return tools
@@ -0,0 +1,88 @@
+"""Serve the taidy protocol over a Unix socket from one resident process."""
+
+import os
+import socket
+import socketserver
+import threading
+from pathlib import Path
+from typing import Any, Dict
+
+from .protocol import Client, Handler, serve
+
+# Where `taidy daemon` listens unless --socket is given, relative to where it starts
+DEFAULT_SOCKET_PATH = ".taidy/daemon.sock"
+
+
+def remove_stale_socket(path: str) -> None:
+    """Remove a socket left by a daemon that has exited, raising OSError if one is running"""
+    if not os.path.exists(path):
+        return
+
+    probe = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+    try:
+        probe.connect(path)
+    except OSError:
+        os.unlink(path)
+        return
+    finally:
+        probe.close()
+    raise OSError(f"A daemon is already listening on {path}")
+
+
+def serve_socket(path: str, handlers: Dict[str, Handler]) -> None:
+    """Answer protocol requests on a Unix socket until a client asks for shutdown.
+
+    Several clients may stay connected at once, such as an editor and a git hook, but
+    requests are handled one at a time, since each run uses the process's global state.
+    Raises OSError if the socket can't be created.
+    """
+    if not hasattr(socket, "AF_UNIX"):
+        raise OSError("Unix sockets aren't supported on this platform")
+
+    lock = threading.Lock()
+
+    def serialized(handler: Handler) -> Handler:
+        def run(params: Dict[str, Any]) -> Any:
+            with lock:
+                return handler(params)
+
+        return run
+
+    locked = {name: serialized(handler) for name, handler in handlers.items()}
+
+    class Connection(socketserver.StreamRequestHandler):
+        def handle(self) -> None:
+            reader = self.request.makefile("r", encoding="utf-8", errors="replace")
+            writer = self.request.makefile("w", encoding="utf-8")
+            if serve(reader, writer, locked):
+                # Called from this connection's thread, while serve_forever runs in another
+                server.shutdown()
+
+    remove_stale_socket(path)
+    Path(path).parent.mkdir(parents=True, exist_ok=True)
+    server = socketserver.ThreadingUnixStreamServer(path, Connection)
+    server.daemon_threads = True
+    try:
+        # Only the user who started the daemon may send it requests
+        os.chmod(path, 0o600)
+        server.serve_forever()
+    finally:
+        server.server_close()
+        if os.path.exists(path):
+            os.unlink(path)
+
+
+def connect(path: str) -> Client:
+    """Connect to a daemon's socket, raising OSError if nothing is listening there"""
+    if not hasattr(socket, "AF_UNIX"):
+        raise OSError("Unix sockets aren't supported on this platform")
+
+    connection = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+    try:
+        connection.connect(path)
+    except OSError:
+        connection.close()
+        raise
+    reader = connection.makefile("r", encoding="utf-8", errors="replace")
+    writer = connection.makefile("w", encoding="utf-8")
+    return Client(reader, writer)
@@ -0,0 +1,420 @@
+"""Diagnostics reported by linters, normalised across tools."""
+
+import json
+import os
+import re
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Any, Callable, Dict, List, Optional
+
+
+@dataclass
+class Diagnostic:
+    """A single finding reported by a tool"""
+
+    file: str
+    line: int
+    column: Optional[int]
+    message: str
+    tool: str
+    rule: Optional[str] = None
+    severity: str = "error"
+    end_column: Optional[int] = None
+
+
+# Called with each tool run's findings as soon as they are parsed, for embedders that
+# forward them as a run goes, such as bots and dashboards
+DiagnosticsSink = Callable[[List[Diagnostic]], None]
+
+diagnostics_sinks: List[DiagnosticsSink] = []
+
+
+def add_diagnostics_sink(sink: DiagnosticsSink) -> None:
+    """Register a sink for findings from every later run in this process.
+
+    Sinks are called one at a time, so they needn't be thread-safe, but they hold up
+    the run while they work.
+    """
+    diagnostics_sinks.append(sink)
+
+
+def remove_diagnostics_sink(sink: DiagnosticsSink) -> None:
+    """Stop sending findings to a sink registered with add_diagnostics_sink"""
+    if sink in diagnostics_sinks:
+        diagnostics_sinks.remove(sink)
+
+
+# Matches the common `path:line[:column]: message` output style used by most linters,
+# and cspell's `path:line:column - message`
+LOCATION_PATTERN = re.compile(
+    r"^(?P<file>[^:\s][^:]*):(?P<line>\d+)(?::(?P<column>\d+))?(?::|\s+-\s)\s*(?P<message>.+)$"
+)
+
+# Matches a leading rule code such as `E501`, `F401` or `SC2086`
+RULE_PATTERN = re.compile(r"^(?P<rule>[A-Z]+[0-9]+)\b\s*(?:\[\*\]\s*)?(?P<message>.*)$")
+
+
+def parse_location_lines(output: str, tool: str) -> List[Diagnostic]:
+    """Parse `path:line:column: message` lines from a tool's output"""
+    diagnostics = []
+
+    for line in output.splitlines():
+        match = LOCATION_PATTERN.match(line.strip())
+        if not match:
+            continue
+
+        message = match.group("message").strip()
+        rule = None
+        rule_match = RULE_PATTERN.match(message)
+        if rule_match:
+            rule = rule_match.group("rule")
+            message = rule_match.group("message").strip()
+
+        column = match.group("column")
+        diagnostics.append(
+            Diagnostic(
+                file=match.group("file"),
+                line=int(match.group("line")),
+                column=int(column) if column else None,
+                message=message,
+                tool=tool,
+                rule=rule,
+            )
+        )
+
+    return diagnostics
+
+
+# Arguments that switch a tool to JSON output, keyed by tool name or "tool subcommand".
+# They're only added when taidy renders the findings itself, so plain runs keep each
+# tool's own output for people to read
+STRUCTURED_OUTPUT_ARGS: Dict[str, List[str]] = {
+    "ruff check": ["--output-format", "json"],
+    "eslint": ["--format", "json"],
+    "rubocop": ["--format", "json"],
+    "shellcheck": ["--format", "json1"],
+}
+
+# eslint's default "stylish" format: a file name line, then `  line:column  severity  message  rule`
+ESLINT_STYLISH_PATTERN = re.compile(
+    r"^\s+(?P<line>\d+):(?P<column>\d+)\s+(?P<severity>error|warning)\s+(?P<message>.+?)"
+    r"(?:\s{2,}(?P<rule>[\w@/-]+))?$"
+)
+
+# rubocop's default format: `path:line:column: C: [Correctable] Department/Cop: message`
+RUBOCOP_PATTERN = re.compile(
+    r"^(?P<file>[^:\s][^:]*):(?P<line>\d+):(?P<column>\d+): (?P<severity>[CWEFRI]): "
+    r"(?:\[Correctable\] )?(?P<rule>[A-Z]\w*/\w+): (?P<message>.+)$"
+)
+
+# shellcheck's default format: `In path line N:`, the source line, then a caret marker
+SHELLCHECK_HEADER_PATTERN = re.compile(r"^In (?P<file>.+) line (?P<line>\d+):$")
+SHELLCHECK_MARKER_PATTERN = re.compile(
+    r"^(?P<indent>\s*)\^-*\s*(?P<rule>SC\d+)(?: \((?P<severity>\w+)\))?: (?P<message>.+)$"
+)
+
+# hadolint's default format: `path:line rule severity: message`, with shellcheck's rules for
+# RUN instructions
+HADOLINT_PATTERN = re.compile(
+    r"^(?P<file>[^:\s][^:]*):(?P<line>\d+) (?P<rule>(?:DL|SC)\d+) "
+    r"(?P<severity>error|warning|info|style): (?P<message>.+)$"
+)
+
+RUBOCOP_SEVERITIES = {
+    "C": "info",
+    "R": "info",
+    "I": "info",
+    "W": "warning",
+    "E": "error",
+    "F": "error",
+    "convention": "info",
+    "refactor": "info",
+    "info": "info",
+    "warning": "warning",
+    "error": "error",
+    "fatal": "error",
+}
+
+SHELLCHECK_SEVERITIES = {"error": "error", "warning": "warning", "info": "info", "style": "info"}
+
+
+def load_json(output: str) -> Optional[Any]:
+    """Parse a tool's output as JSON, or None if it isn't JSON"""
+    if not output.lstrip().startswith(("[", "{")):
+        return None
+    try:
+        return json.loads(output)
+    except ValueError:
+        return None
+
+
+def printable_path(path: str) -> str:
+    """Show a path safely in text or JSON, whatever bytes or control characters its name has.
+
+    Undecodable bytes, kept as surrogates in file names, become `\\xNN` escapes, as they
+    do in decoded tool output, and newlines and other control characters are escaped too.
+    """
+    text = os.fsencode(path).decode("utf-8", "backslashreplace")
+    return "".join(
+        char if char.isprintable() else char.encode("unicode_escape").decode("ascii")
+        for char in text
+    )
+
+
+def relative_path(path: str) -> str:
+    """Make a tool's absolute path relative to the current directory, as paths given to it are"""
+    return os.path.relpath(path) if os.path.isabs(path) else path
+
+
+def parse_ruff(stdout: str, stderr: str) -> List[Diagnostic]:
+    """Parse ruff's JSON output, or its default `path:line:column: CODE message` lines"""
+    data = load_json(stdout)
+    if not isinstance(data, list):
+        return parse_location_lines(stdout, "ruff") + parse_location_lines(stderr, "ruff")
+
+    return [
+        Diagnostic(
+            file=relative_path(item["filename"]),
+            line=item["location"]["row"],
+            column=item["location"]["column"],
+            message=item["message"],
+            tool="ruff",
+            rule=item.get("code"),
+            end_column=(item.get("end_location") or {}).get("column"),
+        )
+        for item in data
+    ]
+
+
+def parse_eslint(stdout: str, stderr: str) -> List[Diagnostic]:
+    """Parse eslint's JSON output, or its default stylish format"""
+    data = load_json(stdout)
+    diagnostics = []
+
+    if isinstance(data, list):
+        for result in data:
+            for item in result.get("messages", []):
+                diagnostics.append(
+                    Diagnostic(
+                        file=relative_path(result["filePath"]),
+                        # Fatal parse errors can come without a position
+                        line=item.get("line") or 1,
+                        column=item.get("column"),
+                        message=item["message"],
+                        tool="eslint",
+                        rule=item.get("ruleId"),
+                        severity="error" if item.get("severity") == 2 else "warning",
+                        end_column=item.get("endColumn"),
+                    )
+                )
+        return diagnostics
+
+    file = None
+    for line in stdout.splitlines():
+        match = ESLINT_STYLISH_PATTERN.match(line)
+        if match and file is not None:
+            diagnostics.append(
+                Diagnostic(
+                    file=file,
+                    line=int(match.group("line")),
+                    column=int(match.group("column")),
+                    message=match.group("message").strip(),
+                    tool="eslint",
+                    rule=match.group("rule"),
+                    severity=match.group("severity"),
+                )
+            )
+        elif line and not line[0].isspace() and not line.startswith("✖"):
+            file = relative_path(line.strip())
+    # Other formats, such as unix or compact, use `path:line:column: message` lines
+    return diagnostics or parse_location_lines(stdout, "eslint")
+
+
+def parse_rubocop(stdout: str, stderr: str) -> List[Diagnostic]:
+    """Parse rubocop's JSON output, or its default `path:line:column: C: Cop: message` lines"""
+    data = load_json(stdout)
+    diagnostics = []
+
+    if isinstance(data, dict):
+        for result in data.get("files", []):
+            for offense in result.get("offenses", []):
+                location = offense.get("location", {})
+                diagnostics.append(
+                    Diagnostic(
+                        file=relative_path(result["path"]),
+                        line=location.get("start_line") or location.get("line") or 1,
+                        column=location.get("start_column") or location.get("column"),
+                        message=offense["message"],
+                        tool="rubocop",
+                        rule=offense.get("cop_name"),
+                        severity=RUBOCOP_SEVERITIES.get(offense.get("severity", ""), "error"),
+                        end_column=location.get("last_column"),
+                    )
+                )
+        return diagnostics
+
+    for line in stdout.splitlines():
+        match = RUBOCOP_PATTERN.match(line.strip())
+        if match:
+            diagnostics.append(
+                Diagnostic(
+                    file=match.group("file"),
+                    line=int(match.group("line")),
+                    column=int(match.group("column")),
+                    message=match.group("message").strip(),
+                    tool="rubocop",
+                    rule=match.group("rule"),
+                    severity=RUBOCOP_SEVERITIES[match.group("severity")],
+                )
+            )
+    return diagnostics
+
+
+def parse_shellcheck(stdout: str, stderr: str) -> List[Diagnostic]:
+    """Parse shellcheck's json1 output, or its default format with caret markers"""
+    data = load_json(stdout)
+    diagnostics = []
+
+    if isinstance(data, dict):
+        for item in data.get("comments", []):
+            diagnostics.append(
+                Diagnostic(
+                    file=item["file"],
+                    line=item["line"],
+                    column=item.get("column"),
+                    message=item["message"],
+                    tool="shellcheck",
+                    rule=f"SC{item['code']}",
+                    severity=SHELLCHECK_SEVERITIES.get(item.get("level", ""), "error"),
+                    end_column=item.get("endColumn"),
+                )
+            )
+        return diagnostics
+
+    file, line_number = None, 0
+    for line in stdout.splitlines():
+        header = SHELLCHECK_HEADER_PATTERN.match(line)
+        if header:
+            file, line_number = header.group("file"), int(header.group("line"))
+            continue
+        marker = SHELLCHECK_MARKER_PATTERN.match(line)
+        if marker and file is not None:
+            diagnostics.append(
+                Diagnostic(
+                    file=file,
+                    line=line_number,
+                    column=len(marker.group("indent")) + 1,
+                    message=marker.group("message").strip(),
+                    tool="shellcheck",
+                    rule=marker.group("rule"),
+                    severity=SHELLCHECK_SEVERITIES.get(marker.group("severity") or "", "error"),
+                )
+            )
+    return diagnostics or parse_location_lines(stdout, "shellcheck")
+
+
+def parse_hadolint(stdout: str, stderr: str) -> List[Diagnostic]:
+    """Parse hadolint's default `path:line rule severity: message` lines"""
+    diagnostics = []
+    for line in stdout.splitlines():
+        match = HADOLINT_PATTERN.match(line.strip())
+        if match:
+            diagnostics.append(
+                Diagnostic(
+                    file=match.group("file"),
+                    line=int(match.group("line")),
+                    column=None,
+                    message=match.group("message").strip(),
+                    tool="hadolint",
+                    rule=match.group("rule"),
+                    severity=SHELLCHECK_SEVERITIES[match.group("severity")],
+                )
+            )
+    return diagnostics + parse_location_lines(stderr, "hadolint")
+
+
+def parse_gofmt(stdout: str, stderr: str) -> List[Diagnostic]:
+    """Parse `gofmt -l` output, a list of files whose formatting differs, plus syntax errors"""
+    drifted = [
+        Diagnostic(
+            file=line.strip(),
+            line=1,
+            column=None,
+            message="File is not formatted with gofmt",
+            tool="gofmt",
+        )
+        for line in stdout.splitlines()
+        if line.strip() and not LOCATION_PATTERN.match(line.strip())
+    ]
+    return drifted + parse_location_lines(stderr, "gofmt")
+
+
+def parse_indentation(stdout: str, stderr: str) -> List[Diagnostic]:
+    """Parse taidy's own indentation check, whose findings are only ever warnings"""
+    diagnostics = parse_location_lines(stdout, "taidy.indentation")
+    for diagnostic in diagnostics:
+        diagnostic.severity = "warning"
+    return diagnostics + parse_location_lines(stderr, "taidy.indentation")
+
+
+# Tools whose output needs more than the generic `path:line:column: message` parser
+PARSERS: Dict[str, Callable[[str, str], List[Diagnostic]]] = {
+    "ruff": parse_ruff,
+    "eslint": parse_eslint,
+    "rubocop": parse_rubocop,
+    "shellcheck": parse_shellcheck,
+    "gofmt": parse_gofmt,
+    "hadolint": parse_hadolint,
+    "taidy.indentation": parse_indentation,
+}
+
+
+def parse_diagnostics(tool: str, stdout: str, stderr: str) -> List[Diagnostic]:
+    """Parse diagnostics from a tool's captured output"""
+    if tool in PARSERS:
+        try:
+            return PARSERS[tool](stdout, stderr)
+        except (KeyError, TypeError, AttributeError):
+            # Output in a shape the parser doesn't expect, e.g. from an unusual version
+            pass
+    return parse_location_lines(stdout, tool) + parse_location_lines(stderr, tool)
+
+
+def format_diagnostic(diagnostic: Diagnostic) -> str:
+    """Render a diagnostic as a single `path:line:column: message [tool]` line"""
+    location = f"{diagnostic.file}:{diagnostic.line}"
+    if diagnostic.column is not None:
+        location += f":{diagnostic.column}"
+    rule = f"{diagnostic.rule} " if diagnostic.rule else ""
+    return f"{location}: {rule}{diagnostic.message} [{diagnostic.tool}]"
+
+
+def format_context(diagnostic: Diagnostic, source_cache: Dict[str, List[str]]) -> str:
+    """Render a diagnostic with its offending source line and a caret marker"""
+    header = format_diagnostic(diagnostic)
+
+    if diagnostic.file not in source_cache:
+        try:
+            text = Path(diagnostic.file).read_text(errors="replace")
+            source_cache[diagnostic.file] = text.splitlines()
+        except OSError:
+            source_cache[diagnostic.file] = []
+
+    lines = source_cache[diagnostic.file]
+    if not 1 <= diagnostic.line <= len(lines):
+        return header
+
+    source_line = lines[diagnostic.line - 1].expandtabs(4)
+    gutter = " " * len(str(diagnostic.line))
+    rendered = [
+        header,
+        f"{gutter} |",
+        f"{diagnostic.line} | {source_line}",
+    ]
+
+    if diagnostic.column is not None:
+        start = max(diagnostic.column - 1, 0)
+        end = diagnostic.end_column - 1 if diagnostic.end_column else start + 1
+        rendered.append(f"{gutter} | {' ' * start}{'^' * max(end - start, 1)}")
+
+    return "\n".join(rendered)
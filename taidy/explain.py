@@ -0,0 +1,192 @@
+"""Explain findings in plain English, with suggested patches, by asking a language model.
+
+Nothing is sent anywhere unless the project's config has an "explain" table naming the
+provider and model; the API key comes from an environment variable, so it never sits in
+a committed config file:
+
+    "explain": {"provider": "anthropic", "model": "...", "api_key_env": "ANTHROPIC_API_KEY"}
+
+"endpoint" overrides the provider's URL, for proxies and self-hosted servers that speak
+the same API. It and "api_key_env" decide where findings and the key are sent, so only
+the user-level config can change them: a repository's own config could otherwise send a
+key of the user's to a server of its choosing.
+"""
+
+import json
+import os
+import urllib.error
+import urllib.request
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Any, Dict, List
+
+from .diagnostics import Diagnostic, format_diagnostic, printable_path
+
+# Where each provider's API is, and the environment variable its key is read from
+PROVIDERS: Dict[str, Dict[str, str]] = {
+    "anthropic": {
+        "endpoint": "https://api.anthropic.com/v1/messages",
+        "api_key_env": "ANTHROPIC_API_KEY",
+    },
+    "openai": {
+        "endpoint": "https://api.openai.com/v1/chat/completions",
+        "api_key_env": "OPENAI_API_KEY",
+    },
+}
+
+# Settings only the user-level config may give, as a project's config can't be trusted
+# with where findings and API keys are sent
+USER_ONLY_SETTINGS = ["endpoint", "api_key_env"]
+
+# Keeps requests, and their cost, bounded on a file with many findings
+MAX_DIAGNOSTICS = 20
+
+# Lines of source sent either side of each finding
+CONTEXT_LINES = 3
+
+SYSTEM_PROMPT = (
+    "You explain findings from linters and formatters to a developer. For each finding, "
+    "say in plain English what it means and why it matters, then suggest a fix as a "
+    "unified diff against the source shown. Be brief, and say so when a finding looks "
+    "like a false positive."
+)
+
+NOT_CONFIGURED = """\
+taidy explain sends findings and nearby source to a language model, so it needs turning
+on in the project's config first, for example:
+
+    "explain": {"provider": "anthropic", "model": "MODEL", "api_key_env": "ANTHROPIC_API_KEY"}
+
+provider is one of: """ + ", ".join(sorted(PROVIDERS))
+
+
+@dataclass
+class ExplainSettings:
+    """Where to send findings, from the config's "explain" table"""
+
+    provider: str
+    endpoint: str
+    model: str
+    api_key: str
+
+
+def explain_settings(config: Dict[str, Any], user_config: Dict[str, Any]) -> ExplainSettings:
+    """Read the "explain" config, raising ValueError if it's missing or incomplete, or if
+    the project's config changes what only the user-level config may"""
+    table = config.get("explain")
+    if not isinstance(table, dict):
+        raise ValueError(NOT_CONFIGURED)
+
+    provider = table.get("provider")
+    if provider not in PROVIDERS:
+        raise ValueError(f'explain "provider" must be one of: {", ".join(sorted(PROVIDERS))}')
+    model = table.get("model")
+    if not isinstance(model, str) or not model:
+        raise ValueError('explain needs a "model" to ask')
+
+    # The config is layered, so the user's own settings show up in it unchanged
+    user_table = user_config.get("explain")
+    settings = dict(PROVIDERS[provider], **(user_table if isinstance(user_table, dict) else {}))
+    for key in USER_ONLY_SETTINGS:
+        if table.get(key, settings[key]) not in [settings[key], PROVIDERS[provider][key]]:
+            raise ValueError(
+                f'explain "{key}" can only be changed in the user-level config, as a project\'s '
+                "config could send findings or API keys elsewhere"
+            )
+
+    key_variable = settings["api_key_env"]
+    api_key = os.environ.get(str(key_variable), "")
+    if not api_key:
+        raise ValueError(f"explain reads its API key from ${key_variable}, which isn't set")
+    return ExplainSettings(provider, str(settings["endpoint"]), model, api_key)
+
+
+def build_prompt(diagnostics: List[Diagnostic]) -> str:
+    """Describe findings with the source lines around each, for the model to explain"""
+    sources: Dict[str, List[str]] = {}
+    sections = []
+    for diagnostic in diagnostics[:MAX_DIAGNOSTICS]:
+        if diagnostic.file not in sources:
+            try:
+                text = Path(diagnostic.file).read_text(errors="replace")
+                sources[diagnostic.file] = text.splitlines()
+            except OSError:
+                sources[diagnostic.file] = []
+
+        lines = sources[diagnostic.file]
+        first = max(diagnostic.line - CONTEXT_LINES, 1)
+        last = min(diagnostic.line + CONTEXT_LINES, len(lines))
+        numbered = [f"{number:>5} | {lines[number - 1]}" for number in range(first, last + 1)]
+        excerpt = "\n".join(numbered)
+        section = f"Finding: {format_diagnostic(diagnostic)}"
+        if excerpt:
+            section += f"\nSource of {printable_path(diagnostic.file)}:\n{excerpt}"
+        sections.append(section)
+
+    if len(diagnostics) > MAX_DIAGNOSTICS:
+        sections.append(f"({len(diagnostics) - MAX_DIAGNOSTICS} further findings left out)")
+    return "\n\n".join(sections)
+
+
+def request_body(settings: ExplainSettings, prompt: str) -> Dict[str, Any]:
+    """Build the provider's request for an explanation of the prompt"""
+    if settings.provider == "anthropic":
+        return {
+            "model": settings.model,
+            "max_tokens": 4096,
+            "system": SYSTEM_PROMPT,
+            "messages": [{"role": "user", "content": prompt}],
+        }
+    return {
+        "model": settings.model,
+        "messages": [
+            {"role": "system", "content": SYSTEM_PROMPT},
+            {"role": "user", "content": prompt},
+        ],
+    }
+
+
+def request_headers(settings: ExplainSettings) -> Dict[str, str]:
+    """Get the provider's headers, authentication included"""
+    if settings.provider == "anthropic":
+        return {
+            "Content-Type": "application/json",
+            "x-api-key": settings.api_key,
+            "anthropic-version": "2023-06-01",
+        }
+    return {"Content-Type": "application/json", "Authorization": f"Bearer {settings.api_key}"}
+
+
+def response_text(provider: str, response: Dict[str, Any]) -> str:
+    """Get the text of a provider's reply, raising ValueError if it holds none"""
+    try:
+        if provider == "anthropic":
+            blocks = response["content"]
+            return "".join(block["text"] for block in blocks if block.get("type") == "text")
+        return str(response["choices"][0]["message"]["content"])
+    except (KeyError, IndexError, TypeError):
+        raise ValueError("The explanation response held no text") from None
+
+
+def request_explanation(settings: ExplainSettings, prompt: str, timeout: float = 120) -> str:
+    """Ask the model to explain the findings, raising ValueError if the request fails"""
+    request = urllib.request.Request(
+        settings.endpoint,
+        data=json.dumps(request_body(settings, prompt)).encode(),
+        headers=request_headers(settings),
+        method="POST",
+    )
+    try:
+        with urllib.request.urlopen(request, timeout=timeout) as response:
+            reply = json.loads(response.read().decode("utf-8", errors="replace"))
+    except urllib.error.HTTPError as e:
+        detail = e.read().decode("utf-8", errors="replace").strip()
+        raise ValueError(f"{settings.endpoint} answered {e.code}: {detail}") from None
+    except (urllib.error.URLError, OSError) as e:
+        raise ValueError(f"Couldn't reach {settings.endpoint}: {e}") from None
+    except ValueError:
+        raise ValueError(f"{settings.endpoint} didn't answer with JSON") from None
+
+    if not isinstance(reply, dict):
+        raise ValueError(f"{settings.endpoint} didn't answer with a JSON object")
+    return response_text(settings.provider, reply)
@@ -0,0 +1,146 @@
+"""Record a summary of each lint run, and report how lint debt changes over time."""
+
+import json
+from collections import Counter
+from datetime import datetime
+from pathlib import Path
+from typing import Any, Dict, List
+
+from .diagnostics import Diagnostic
+
+HISTORY_FILE = Path(".taidy") / "history.jsonl"
+
+DEFAULT_TREND_RUNS = 10
+
+# How much each finding counts towards the tidiness score, by severity
+SEVERITY_WEIGHTS: Dict[str, float] = {"error": 1.0, "warning": 0.5, "info": 0.1}
+
+# Weighted findings per thousand lines at which the tidiness score halves to 50
+HALF_SCORE_DENSITY = 10.0
+
+
+def count_lines(files: List[str]) -> int:
+    """Count the lines in a set of files, skipping any that can't be read"""
+    total = 0
+    for file in set(files):
+        try:
+            with open(file, "rb") as f:
+                total += sum(1 for _ in f)
+        except OSError:
+            continue
+    return total
+
+
+def tidiness_score(diagnostics: List[Diagnostic], line_count: int) -> float:
+    """Score a run from 0 to 100 by its severity-weighted findings per thousand lines.
+
+    100 means no findings; the score halves at HALF_SCORE_DENSITY and keeps falling
+    towards 0, so repositories of any size can be compared on one number.
+    """
+    weighted = sum(SEVERITY_WEIGHTS.get(d.severity, 1.0) for d in diagnostics)
+    density = weighted / max(line_count / 1000, 1.0)
+    return round(100 / (1 + density / HALF_SCORE_DENSITY), 1)
+
+
+def run_summary(
+    mode: str, files: List[str], exit_code: int, diagnostics: List[Diagnostic]
+) -> Dict[str, Any]:
+    """Summarise a run's findings by severity and by tool, with its tidiness score"""
+    line_count = count_lines(files)
+    return {
+        "timestamp": datetime.now().isoformat(timespec="seconds"),
+        "mode": mode,
+        "files": len(files),
+        "lines": line_count,
+        "exit_code": exit_code,
+        "total": len(diagnostics),
+        "by_severity": dict(Counter(d.severity for d in diagnostics)),
+        "by_tool": dict(Counter(d.tool for d in diagnostics)),
+        "score": tidiness_score(diagnostics, line_count),
+    }
+
+
+def record_run(root: Path, summary: Dict[str, Any]) -> None:
+    """Append a run summary to the project's history"""
+    path = root / HISTORY_FILE
+    try:
+        path.parent.mkdir(parents=True, exist_ok=True)
+        with path.open("a") as f:
+            f.write(json.dumps(summary, sort_keys=True) + "\n")
+    except OSError:
+        pass
+
+
+def load_history(root: Path) -> List[Dict[str, Any]]:
+    """Load the recorded run summaries, oldest first, skipping unreadable lines"""
+    path = root / HISTORY_FILE
+    if not path.exists():
+        return []
+
+    runs = []
+    for line in path.read_text().splitlines():
+        try:
+            run = json.loads(line)
+        except ValueError:
+            continue
+        if isinstance(run, dict) and "total" in run:
+            runs.append(run)
+    return runs
+
+
+def signed(change: int) -> str:
+    """Format a change in count, such as +3 or -2"""
+    return f"{change:+d}" if change else "0"
+
+
+def trends(root: Path, args: List[str]) -> int:
+    """Handle `taidy trends [--last N]`, showing whether lint debt is rising or falling"""
+    last = DEFAULT_TREND_RUNS
+    if args:
+        value = args[1] if args[0] == "--last" and len(args) == 2 else None
+        if value is None or not value.isdigit() or int(value) < 2:
+            print("Usage: taidy trends [--last N], with N of at least 2")
+            return 1
+        last = int(value)
+
+    runs = load_history(root)[-last:]
+    if not runs:
+        print("No runs recorded yet; lint runs are recorded in .taidy/history.jsonl")
+        return 0
+
+    print(
+        f"{'Run':<20} {'Mode':<6} {'Files':>6} {'Errors':>7} {'Warnings':>9} {'Score':>6} "
+        f"{'Total':>6}"
+    )
+    previous = None
+    for run in runs:
+        severity = run.get("by_severity", {})
+        change = "" if previous is None else f"  ({signed(run['total'] - previous)})"
+        print(
+            f"{run.get('timestamp', '?').replace('T', ' '):<20} {run.get('mode', '?'):<6} "
+            f"{run.get('files', 0):>6} {severity.get('error', 0):>7} "
+            f"{severity.get('warning', 0):>9} {run.get('score', '-'):>6} {run['total']:>6}{change}"
+        )
+        previous = run["total"]
+
+    first, latest = runs[0], runs[-1]
+    if len(runs) < 2:
+        print("\nOnly one run recorded so far; run taidy again to see a trend")
+        return 0
+
+    print("\nBy tool (oldest → latest run):")
+    tools = sorted(set(first.get("by_tool", {})) | set(latest.get("by_tool", {})))
+    for tool in tools:
+        before = first.get("by_tool", {}).get(tool, 0)
+        after = latest.get("by_tool", {}).get(tool, 0)
+        print(f"  {tool:<18} {before:>5} → {after:<5} ({signed(after - before)})")
+
+    change = latest["total"] - first["total"]
+    direction = "rising" if change > 0 else "falling" if change < 0 else "flat"
+    print(
+        f"\nLint debt is {direction}: {first['total']} → {latest['total']} findings "
+        f"over the last {len(runs)} runs"
+    )
+    if "score" in first and "score" in latest:
+        print(f"Tidiness score: {first['score']} → {latest['score']}")
+    return 0
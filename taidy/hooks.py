@@ -0,0 +1,167 @@
+"""Install taidy as a git pre-commit hook, and remove it again, or wire it into a hook
+manager such as husky, lefthook or pre-commit."""
+
+import os
+import subprocess
+import sys
+from pathlib import Path
+from typing import Dict, List, Optional
+
+# Identifies hooks taidy wrote, so it only ever replaces or removes its own
+HOOK_MARKER = "# Installed by taidy install-hooks"
+
+# Where a hook that was there before is kept when --force replaces it
+BACKUP_SUFFIX = ".taidy-backup"
+
+USAGE = "Usage: taidy install-hooks [uninstall] [--force]"
+
+# Config running taidy on staged files from each hook manager, for `taidy generate hooks`.
+# husky and lefthook leave staging to taidy --staged, which restages the files it fixes;
+# pre-commit passes the staged files itself, and fails the commit if a hook changes them
+MANAGER_CONFIGS: Dict[str, str] = {
+    "husky": """\
+# .husky/pre-commit
+taidy --staged
+""",
+    "lefthook": """\
+# lefthook.yml
+pre-commit:
+  commands:
+    taidy:
+      run: taidy --staged
+""",
+    "pre-commit": """\
+# .pre-commit-config.yaml
+repos:
+  - repo: local
+    hooks:
+      - id: taidy
+        name: taidy
+        entry: taidy
+        language: system
+        types: [text]
+        # taidy runs its tools in parallel itself
+        require_serial: true
+""",
+}
+
+GENERATE_USAGE = f"Usage: taidy generate hooks --manager={'|'.join(MANAGER_CONFIGS)}"
+
+
+def hook_script() -> str:
+    """Get the pre-commit hook, which runs taidy on the staged files.
+
+    taidy is found on PATH when the hook runs; failing that, the interpreter that
+    installed the hook runs it, for installs in a virtualenv that isn't activated.
+    """
+    return f"""#!/bin/sh
+{HOOK_MARKER}; remove with `taidy install-hooks uninstall`
+if command -v taidy >/dev/null 2>&1; then
+    exec taidy --staged
+fi
+exec "{sys.executable}" -m taidy --staged
+"""
+
+
+def hooks_directory(cwd: Path) -> Optional[Path]:
+    """Find where git looks for hooks, following core.hooksPath, or None outside git"""
+    try:
+        result = subprocess.run(
+            ["git", "rev-parse", "--git-path", "hooks"],
+            cwd=cwd,
+            capture_output=True,
+            text=True,
+        )
+    except OSError:
+        return None
+    if result.returncode != 0:
+        return None
+    return cwd / result.stdout.strip()
+
+
+def is_taidy_hook(path: Path) -> bool:
+    """Check whether a hook is one taidy installed"""
+    try:
+        return HOOK_MARKER in path.read_text(errors="replace")
+    except OSError:
+        return False
+
+
+def install_hook(hook: Path, force: bool) -> int:
+    """Write the pre-commit hook, keeping any other hook it replaces with --force"""
+    backup = hook.with_name(hook.name + BACKUP_SUFFIX)
+    if hook.exists() and not is_taidy_hook(hook):
+        if not force:
+            print(
+                f"{hook} already exists; use --force to replace it "
+                f"(it's kept as {backup.name})",
+                file=sys.stderr,
+            )
+            return 1
+        os.replace(hook, backup)
+        print(f"Moved the existing hook to {backup}", file=sys.stderr)
+
+    hook.parent.mkdir(parents=True, exist_ok=True)
+    hook.write_text(hook_script())
+    hook.chmod(0o755)
+    print(f"Installed {hook}", file=sys.stderr)
+    return 0
+
+
+def uninstall_hook(hook: Path, force: bool) -> int:
+    """Remove the pre-commit hook, restoring one --force replaced"""
+    if not hook.exists():
+        print(f"No hook at {hook}", file=sys.stderr)
+        return 0
+    if not is_taidy_hook(hook) and not force:
+        print(f"{hook} wasn't installed by taidy; use --force to remove it", file=sys.stderr)
+        return 1
+
+    hook.unlink()
+    backup = hook.with_name(hook.name + BACKUP_SUFFIX)
+    if backup.exists():
+        os.replace(backup, hook)
+        print(f"Removed taidy's hook and restored {hook}", file=sys.stderr)
+    else:
+        print(f"Removed {hook}", file=sys.stderr)
+    return 0
+
+
+def install_hooks(args: List[str]) -> int:
+    """Handle `taidy install-hooks [uninstall] [--force]`"""
+    force = "--force" in args
+    positional = [arg for arg in args if arg != "--force"]
+    if positional not in [[], ["uninstall"]]:
+        print(USAGE, file=sys.stderr)
+        return 1
+
+    directory = hooks_directory(Path.cwd())
+    if directory is None:
+        print("install-hooks only works inside a git repository", file=sys.stderr)
+        return 1
+
+    hook = directory / "pre-commit"
+    if positional == ["uninstall"]:
+        return uninstall_hook(hook, force)
+    return install_hook(hook, force)
+
+
+def generate_hooks(args: List[str]) -> int:
+    """Handle `taidy generate hooks --manager=NAME`, printing the manager's config"""
+    manager = None
+    remaining = list(args)
+    while remaining:
+        arg = remaining.pop(0)
+        if arg.startswith("--manager="):
+            manager = arg.split("=", 1)[1]
+        elif arg == "--manager" and remaining:
+            manager = remaining.pop(0)
+        else:
+            manager = None
+            break
+
+    if manager is None or manager not in MANAGER_CONFIGS:
+        print(GENERATE_USAGE, file=sys.stderr)
+        return 1
+    print(MANAGER_CONFIGS[manager], end="")
+    return 0
@@ -0,0 +1,222 @@
+"""Read .taidyignore, and keep other tools' ignore settings in sync with taidy's ignore list."""
+
+import json
+import re
+from dataclasses import dataclass
+from pathlib import Path
+from typing import List, Optional, Tuple
+
+IGNORE_FILE = ".taidyignore"
+
+BLOCK_START = "# BEGIN taidy ignores (managed by `taidy sync-ignores`)"
+BLOCK_END = "# END taidy ignores"
+
+BLOCK_PATTERN = re.compile(re.escape(BLOCK_START) + r".*?" + re.escape(BLOCK_END) + r"\n?", re.S)
+
+
+@dataclass
+class IgnoreRule:
+    """One gitignore-style pattern from .taidyignore"""
+
+    pattern: "re.Pattern[str]"
+    negate: bool = False
+    directory_only: bool = False
+
+
+def translate_pattern(pattern: str) -> str:
+    """Translate a gitignore glob into a regular expression over a posix relative path"""
+    regex = ""
+    i = 0
+    while i < len(pattern):
+        if pattern.startswith("**/", i):
+            regex += "(?:.*/)?"
+            i += 3
+        elif pattern.startswith("/**", i) and i + 3 == len(pattern):
+            regex += "/.*"
+            i += 3
+        elif pattern[i] == "*":
+            regex += "[^/]*"
+            i += 1
+        elif pattern[i] == "?":
+            regex += "[^/]"
+            i += 1
+        elif pattern[i] == "[" and "]" in pattern[i + 1 :]:
+            end = pattern.index("]", i + 1)
+            regex += "[" + pattern[i + 1 : end].replace("!", "^", 1) + "]"
+            i = end + 1
+        else:
+            regex += re.escape(pattern[i])
+            i += 1
+    return regex
+
+
+def compile_pattern(pattern: str) -> Optional[IgnoreRule]:
+    """Compile one gitignore-style pattern, or None if it matches nothing"""
+    negate = pattern.startswith("!")
+    if negate or pattern.startswith("\\"):
+        pattern = pattern[1:]
+
+    directory_only = pattern.endswith("/")
+    pattern = pattern.rstrip("/")
+    if not pattern:
+        return None
+
+    # Patterns with a slash are relative to the project root; others match at any depth
+    anchored = "/" in pattern
+    regex = translate_pattern(pattern.lstrip("/"))
+    if not anchored:
+        regex = "(?:.*/)?" + regex
+    return IgnoreRule(re.compile(regex), negate, directory_only)
+
+
+def parse_ignore_file(text: str) -> List[IgnoreRule]:
+    """Parse gitignore-style patterns, skipping blank lines and comments"""
+    rules = []
+    for line in text.splitlines():
+        line = line.rstrip()
+        if not line or line.startswith("#"):
+            continue
+
+        rule = compile_pattern(line)
+        if rule is not None:
+            rules.append(rule)
+    return rules
+
+
+def rule_matches(rule: IgnoreRule, relative_path: str) -> bool:
+    """Check whether a rule matches a file, directly or through one of its directories"""
+    parts = relative_path.split("/")
+    for depth in range(1, len(parts) + 1):
+        if rule.directory_only and depth == len(parts):
+            continue
+        if rule.pattern.fullmatch("/".join(parts[:depth])):
+            return True
+    return False
+
+
+def load_ignore_file(root: Path) -> List[IgnoreRule]:
+    """Load the rules from the project's .taidyignore, if it has one"""
+    path = root / IGNORE_FILE
+    if not path.is_file():
+        return []
+    return parse_ignore_file(path.read_text())
+
+
+def is_ignored(relative_path: str, rules: List[IgnoreRule]) -> bool:
+    """Check whether a posix path relative to the project root is ignored.
+
+    As with git, the last matching rule wins, and nothing inside an ignored directory
+    can be re-included.
+    """
+    parts = relative_path.split("/")
+    for depth in range(1, len(parts) + 1):
+        path = "/".join(parts[:depth])
+        is_directory = depth < len(parts)
+        ignored = False
+        for rule in rules:
+            if rule.directory_only and not is_directory:
+                continue
+            if rule.pattern.fullmatch(path):
+                ignored = not rule.negate
+        if ignored:
+            return True
+    return False
+
+
+def replace_block(text: str, block: str, insert_at: Optional[int] = None) -> str:
+    """Replace the managed block in text, inserting it (default: appending) if absent"""
+    if BLOCK_PATTERN.search(text):
+        return BLOCK_PATTERN.sub(lambda _: block, text, count=1)
+
+    if insert_at is not None:
+        return text[:insert_at] + block + text[insert_at:]
+
+    if text and not text.endswith("\n"):
+        text += "\n"
+    if text and not text.endswith("\n\n"):
+        text += "\n"
+    return text + block
+
+
+def ignore_file_block(patterns: List[str]) -> str:
+    """Build a managed block for gitignore-style files such as .prettierignore"""
+    return "\n".join([BLOCK_START] + patterns + [BLOCK_END]) + "\n"
+
+
+def ruff_block(patterns: List[str]) -> str:
+    """Build a managed block setting ruff's extend-exclude"""
+    return "\n".join([BLOCK_START, f"extend-exclude = {json.dumps(patterns)}", BLOCK_END]) + "\n"
+
+
+def updated_ruff_config(config: Path, patterns: List[str]) -> str:
+    """Return ruff's config file contents with the managed extend-exclude block in place"""
+    text = config.read_text()
+    block = ruff_block(patterns)
+
+    if config.name != "pyproject.toml":
+        # Top-level keys must come before any table, so the block goes first
+        return replace_block(text, block, 0)
+
+    header = re.search(r"^\[tool\.ruff\]\s*\n", text, re.M)
+    if header:
+        return replace_block(text, block, header.end())
+
+    return replace_block(text, "[tool.ruff]\n" + block)
+
+
+def has_unmanaged_key(path: Path, key: str) -> bool:
+    """Check whether a config file sets a key outside taidy's managed block"""
+    text = BLOCK_PATTERN.sub("", path.read_text())
+    return re.search(rf"^\s*{re.escape(key)}\s*=", text, re.M) is not None
+
+
+def sync_ignores(root: Path, patterns: List[str], check: bool = False) -> int:
+    """Write taidy's ignore patterns into .prettierignore, ruff and eslint configuration.
+
+    With check=True nothing is written; the exit code is 1 if any file is out of date.
+    """
+    if not patterns:
+        print("No ignore patterns configured, nothing to sync")
+        return 0
+
+    updates: List[Tuple[Path, str]] = []
+
+    # Legacy eslintrc setups read .eslintignore; flat configs need an `ignores` entry instead
+    ignore_files = [root / ".prettierignore"]
+    flat_configs = sorted(root.glob("eslint.config.*"))
+    if (root / ".eslintignore").exists() or (not flat_configs and any(root.glob(".eslintrc*"))):
+        ignore_files.append(root / ".eslintignore")
+
+    for ignore_file in ignore_files:
+        text = ignore_file.read_text() if ignore_file.exists() else ""
+        updates.append((ignore_file, replace_block(text, ignore_file_block(patterns))))
+
+    ruff_configs = [root / "ruff.toml", root / ".ruff.toml", root / "pyproject.toml"]
+    ruff_config = next((p for p in ruff_configs if p.exists()), None)
+    if ruff_config and has_unmanaged_key(ruff_config, "extend-exclude"):
+        print(f"⚠️  {ruff_config.name} already sets extend-exclude; merge these by hand:")
+        print(f"    {json.dumps(patterns)}")
+    elif ruff_config:
+        updates.append((ruff_config, updated_ruff_config(ruff_config, patterns)))
+
+    out_of_date = [
+        (path, text)
+        for path, text in updates
+        if not path.exists() or path.read_text() != text
+    ]
+
+    for path, text in out_of_date:
+        if check:
+            print(f"Out of date: {path.relative_to(root)}")
+        else:
+            path.write_text(text)
+            print(f"Updated {path.relative_to(root)}")
+
+    if flat_configs:
+        print(f"{flat_configs[0].name} uses a flat config; add this entry to its exported array:")
+        print(f"  {{ ignores: {json.dumps(patterns)} }}")
+
+    if not out_of_date:
+        print("Ignore files are already in sync")
+
+    return 1 if check and out_of_date else 0
@@ -0,0 +1,179 @@
+"""Generate Dockerfiles for images with taidy and its tools preinstalled, from the tools in
+taidy's own chains, so the images keep up as tools are added:
+
+    taidy bundle-image full             # print the Dockerfile for taidy/full
+    taidy bundle-image --write docker   # write docker/Dockerfile.NAME for every image
+
+"full" has every tool in every chain; the others hold their languages' preferred tools,
+the first in each chain that can be installed.
+"""
+
+from typing import Dict, List, Optional, Tuple
+
+# Languages (or extension keys) each image covers, with None for all of them
+IMAGES: Dict[str, Optional[List[str]]] = {
+    "full": None,
+    "python": ["python", "toml", "pyproject.toml"],
+    "node": [
+        "javascript",
+        "typescript",
+        "json",
+        "css",
+        "html",
+        "markdown",
+        "yaml",
+        "package.json",
+    ],
+}
+
+BASE_IMAGE = "ubuntu:22.04"
+
+# How each tool is installed: an installer and the package to give it. A package of None
+# means the tool comes with the installer's runtime, as gofmt comes with Go
+INSTALLS: Dict[str, Tuple[str, Optional[str]]] = {
+    "ruff": ("pip", "ruff"),
+    "black": ("pip", "black"),
+    "flake8": ("pip", "flake8"),
+    "pylint": ("pip", "pylint"),
+    "isort": ("pip", "isort"),
+    "yamllint": ("pip", "yamllint"),
+    "beautysh": ("pip", "beautysh"),
+    "codespell": ("pip", "codespell"),
+    "validate-pyproject": ("pip", "validate-pyproject"),
+    "eslint": ("npm", "eslint"),
+    "prettier": ("npm", "prettier"),
+    "tsc": ("npm", "typescript"),
+    "stylelint": ("npm", "stylelint"),
+    "markdownlint": ("npm", "markdownlint-cli"),
+    "cspell": ("npm", "cspell"),
+    "publint": ("npm", "publint"),
+    "npm-groovy-lint": ("npm", "npm-groovy-lint"),
+    "shellcheck": ("apt", "shellcheck"),
+    "gofmt": ("go", None),
+    "go": ("go", None),
+    "goimports": ("go", "golang.org/x/tools/cmd/goimports@latest"),
+    "shfmt": ("go", "mvdan.cc/sh/v3/cmd/shfmt@latest"),
+    "actionlint": ("go", "github.com/rhysd/actionlint/cmd/actionlint@latest"),
+    "editorconfig-checker": (
+        "go",
+        "github.com/editorconfig-checker/editorconfig-checker/v3/cmd/editorconfig-checker@latest",
+    ),
+    "rustfmt": ("cargo", None),
+    "cargo": ("cargo", None),
+    "typos": ("cargo", "typos-cli"),
+    "just": ("cargo", "just"),
+    "checkmake": ("go", "github.com/mrtazz/checkmake/cmd/checkmake@latest"),
+    "buildifier": ("go", "github.com/bazelbuild/buildtools/buildifier@latest"),
+    "rubocop": ("gem", "rubocop"),
+    "php-cs-fixer": ("composer", "friendsofphp/php-cs-fixer"),
+    "taplo": (
+        "script",
+        "curl -fsSL https://github.com/tamasfe/taplo/releases/latest/download/"
+        "taplo-linux-x86_64.gz | gzip -d > /usr/local/bin/taplo && chmod +x /usr/local/bin/taplo",
+    ),
+    "terraform": (
+        "script",
+        "curl -fsSLo /tmp/terraform.zip https://releases.hashicorp.com/terraform/1.6.6/"
+        "terraform_1.6.6_linux_amd64.zip && unzip /tmp/terraform.zip -d /usr/local/bin "
+        "&& rm /tmp/terraform.zip",
+    ),
+    "tflint": (
+        "script",
+        "curl -fsSL https://raw.githubusercontent.com/terraform-linters/tflint/master/"
+        "install_linux.sh | bash",
+    ),
+    "hadolint": (
+        "script",
+        "curl -fsSLo /usr/local/bin/hadolint https://github.com/hadolint/hadolint/releases/"
+        "latest/download/hadolint-Linux-x86_64 && chmod +x /usr/local/bin/hadolint",
+    ),
+    "trufflehog": (
+        "script",
+        "curl -fsSL https://raw.githubusercontent.com/trufflesecurity/trufflehog/main/"
+        "scripts/install.sh | sh -s -- -b /usr/local/bin",
+    ),
+}
+
+# What each installer needs set up first, in the order they're set up. pip and apt need
+# nothing more than the base image, which always has Python for taidy itself
+RUNTIMES: Dict[str, str] = {
+    "npm": """\
+RUN curl -fsSL https://deb.nodesource.com/setup_20.x | bash - \\
+    && apt-get install -y --no-install-recommends nodejs \\
+    && rm -rf /var/lib/apt/lists/*""",
+    "go": """\
+RUN curl -fsSL https://go.dev/dl/go1.22.5.linux-amd64.tar.gz | tar -C /usr/local -xz
+ENV PATH=/usr/local/go/bin:$PATH""",
+    "cargo": """\
+RUN curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs \\
+    | sh -s -- -y --profile minimal --component rustfmt
+ENV PATH=/root/.cargo/bin:$PATH""",
+    "gem": """\
+RUN apt-get update && apt-get install -y --no-install-recommends ruby ruby-dev build-essential \\
+    && rm -rf /var/lib/apt/lists/*""",
+    "composer": """\
+RUN apt-get update && apt-get install -y --no-install-recommends php-cli php-mbstring php-xml \\
+    && rm -rf /var/lib/apt/lists/* \\
+    && curl -sS https://getcomposer.org/installer \\
+    | php -- --install-dir=/usr/local/bin --filename=composer
+ENV PATH=/root/.composer/vendor/bin:$PATH""",
+}
+
+# The command each installer installs a list of packages with
+INSTALL_COMMANDS: Dict[str, str] = {
+    "pip": "pip3 install --no-cache-dir",
+    "npm": "npm install -g",
+    "go": "GOBIN=/usr/local/bin go install",
+    "cargo": "cargo install --locked",
+    "gem": "gem install --no-document",
+    "composer": "composer global require",
+}
+
+
+def run_step(command: str, packages: List[str]) -> str:
+    """Write a RUN step installing packages, one per line"""
+    return f"RUN {command} \\\n    " + " \\\n    ".join(packages)
+
+
+def dockerfile(name: str, tools: List[str]) -> str:
+    """Write the Dockerfile for an image bundling the tools, skipping any taidy has no
+    recipe for, such as runners like npx"""
+    installs = [(tool, INSTALLS[tool]) for tool in tools if tool in INSTALLS]
+    installers = {installer for _, (installer, _) in installs}
+    apt_packages = ["ca-certificates", "curl", "git", "unzip", "python3", "python3-pip"]
+    apt_packages += [p for _, (i, p) in installs if i == "apt" and p is not None]
+
+    steps = [
+        f"# Generated by `taidy bundle-image {name}` from taidy's tool chains; regenerate it",
+        "# rather than editing it",
+        f"FROM {BASE_IMAGE}",
+        "",
+        "ENV DEBIAN_FRONTEND=noninteractive",
+        "RUN apt-get update && apt-get install -y --no-install-recommends \\\n    "
+        + " \\\n    ".join(dict.fromkeys(apt_packages))
+        + " \\\n    && rm -rf /var/lib/apt/lists/*",
+    ]
+    for installer, setup in RUNTIMES.items():
+        if installer in installers:
+            steps += ["", setup]
+
+    for installer, command in INSTALL_COMMANDS.items():
+        packages = [p for _, (i, p) in installs if i == installer and p is not None]
+        if packages:
+            steps += ["", run_step(command, list(dict.fromkeys(packages)))]
+    for tool, (installer, script) in installs:
+        if installer == "script" and script is not None:
+            steps += ["", f"# {tool}", f"RUN {script}"]
+
+    steps += [
+        "",
+        "WORKDIR /app",
+        "COPY taidy/ /app/taidy/",
+        "COPY pyproject.toml README.md /app/",
+        "RUN pip3 install --no-cache-dir /app",
+        "",
+        "WORKDIR /workspace",
+        'ENTRYPOINT ["python3", "-m", "taidy"]',
+        'CMD ["--help"]',
+    ]
+    return "\n".join(steps) + "\n"
@@ -0,0 +1,349 @@
+"""Detect the tools a project already uses, to scaffold taidy configuration."""
+
+import importlib
+import json
+import re
+import shlex
+from pathlib import Path
+from typing import Any, Dict, List, Tuple
+
+# Tools taidy knows how to run, in the order they are suggested
+KNOWN_TOOLS = [
+    "ruff",
+    "black",
+    "flake8",
+    "pylint",
+    "eslint",
+    "tsc",
+    "prettier",
+    "gofmt",
+    "rustfmt",
+    "rubocop",
+    "php-cs-fixer",
+    "shellcheck",
+    "shfmt",
+    "beautysh",
+    "yamllint",
+    "taplo",
+    "terraform",
+    "tflint",
+    "actionlint",
+    "trufflehog",
+    "typos",
+    "codespell",
+    "cspell",
+    "editorconfig-checker",
+]
+
+# pre-commit hook ids that correspond to a known tool
+PRE_COMMIT_HOOKS: Dict[str, str] = {
+    "ruff": "ruff",
+    "ruff-format": "ruff",
+    "ruff-check": "ruff",
+    "black": "black",
+    "flake8": "flake8",
+    "pylint": "pylint",
+    "eslint": "eslint",
+    "prettier": "prettier",
+    "shellcheck": "shellcheck",
+    "shfmt": "shfmt",
+    "beautysh": "beautysh",
+    "yamllint": "yamllint",
+    "taplo-format": "taplo",
+    "taplo-lint": "taplo",
+    "terraform_fmt": "terraform",
+    "terraform_validate": "terraform",
+    "terraform_tflint": "tflint",
+    "actionlint": "actionlint",
+    "rubocop": "rubocop",
+    "php-cs-fixer": "php-cs-fixer",
+    "trufflehog": "trufflehog",
+    "go-fmt": "gofmt",
+    "fmt": "rustfmt",
+    "typos": "typos",
+    "codespell": "codespell",
+    "cspell": "cspell",
+    "editorconfig-checker": "editorconfig-checker",
+}
+
+# The "args" key each pre-commit hook's arguments belong under, where it isn't the tool's
+# name: ruff's hooks each run one subcommand
+PRE_COMMIT_ARGS_KEYS: Dict[str, str] = {
+    "ruff": "ruff check",
+    "ruff-check": "ruff check",
+    "ruff-format": "ruff format",
+}
+
+# Hook arguments choosing between checking and fixing, which taidy decides for itself
+MODE_ARGS = {"--fix", "--exit-non-zero-on-fix", "--write", "--check", "--diff"}
+
+# lint-staged command arguments that mean the command rewrites the files it's given
+FIXING_ARGS = {"--fix", "--write", "-w", "--apply", "--autocorrect", "-a"}
+
+# Tool configuration files that indicate a tool is in use
+TOOL_CONFIG_FILES: Dict[str, List[str]] = {
+    "ruff": ["ruff.toml", ".ruff.toml"],
+    "flake8": [".flake8"],
+    "pylint": [".pylintrc", "pylintrc"],
+    "eslint": [".eslintrc", ".eslintrc.js", ".eslintrc.cjs", ".eslintrc.json", ".eslintrc.yml"]
+    + ["eslint.config.js", "eslint.config.mjs", "eslint.config.cjs", "eslint.config.ts"],
+    "prettier": [".prettierrc", ".prettierrc.json", ".prettierrc.yml", ".prettierrc.yaml"]
+    + [".prettierrc.js", ".prettierrc.cjs", "prettier.config.js", "prettier.config.cjs"],
+    "tsc": ["tsconfig.json"],
+    "rustfmt": ["rustfmt.toml", ".rustfmt.toml"],
+    "rubocop": [".rubocop.yml"],
+    "php-cs-fixer": [".php-cs-fixer.php", ".php-cs-fixer.dist.php"],
+    "shellcheck": [".shellcheckrc"],
+    "yamllint": [".yamllint", ".yamllint.yml", ".yamllint.yaml"],
+    "taplo": ["taplo.toml", ".taplo.toml"],
+    "tflint": [".tflint.hcl"],
+    "typos": ["typos.toml", "_typos.toml", ".typos.toml"],
+    "codespell": [".codespellrc"],
+    "cspell": ["cspell.json", ".cspell.json", "cspell.config.yaml"],
+    "editorconfig-checker": [".editorconfig-checker.json", ".ecrc"],
+}
+
+# pyproject.toml sections that indicate a tool is in use
+PYPROJECT_SECTIONS: Dict[str, str] = {
+    "ruff": "tool.ruff",
+    "black": "tool.black",
+    "pylint": "tool.pylint",
+}
+
+MAKE_TARGETS = {"lint", "format", "fmt", "check", "style", "tidy"}
+
+
+def find_tools_in_text(text: str) -> List[str]:
+    """Find known tool names mentioned as words in a command line or script"""
+    return [
+        tool
+        for tool in KNOWN_TOOLS
+        if re.search(rf"(?<![\w-]){re.escape(tool)}(?![\w-])", text)
+    ]
+
+
+def detect_pre_commit_tools(root: Path) -> List[str]:
+    """Find tools wired up as hooks in .pre-commit-config.yaml"""
+    config = root / ".pre-commit-config.yaml"
+    if not config.exists():
+        return []
+
+    tools = []
+    for hook_id in re.findall(r"^\s*-?\s*id:\s*['\"]?([\w.-]+)", config.read_text(), re.M):
+        tool = PRE_COMMIT_HOOKS.get(hook_id)
+        if tool:
+            tools.append(tool)
+    return tools
+
+
+def detect_package_json_tools(root: Path) -> List[str]:
+    """Find tools invoked by package.json scripts"""
+    package_json = root / "package.json"
+    if not package_json.exists():
+        return []
+
+    try:
+        scripts = json.loads(package_json.read_text()).get("scripts", {})
+    except (ValueError, AttributeError):
+        return []
+
+    tools = []
+    for command in scripts.values():
+        if isinstance(command, str):
+            tools.extend(find_tools_in_text(command))
+    return tools
+
+
+def detect_makefile_tools(root: Path) -> List[str]:
+    """Find tools invoked by lint-like targets in a Makefile"""
+    makefiles = [root / name for name in ["Makefile", "makefile", "GNUmakefile"]]
+    makefile = next((path for path in makefiles if path.exists()), None)
+    if makefile is None:
+        return []
+
+    tools = []
+    in_lint_target = False
+    for line in makefile.read_text().splitlines():
+        target = re.match(r"^([\w.-]+)\s*:(?!=)", line)
+        if target:
+            in_lint_target = target.group(1) in MAKE_TARGETS
+        elif in_lint_target and line.startswith("\t"):
+            tools.extend(find_tools_in_text(line))
+    return tools
+
+
+def tool_config_files(root: Path) -> List[Tuple[str, str]]:
+    """Find tools' configuration files, as (where, tool) pairs such as ("ruff.toml", "ruff")
+    or ("pyproject.toml [tool.black]", "black")"""
+    found = [
+        (name, tool)
+        for tool, names in TOOL_CONFIG_FILES.items()
+        for name in names
+        if (root / name).exists()
+    ]
+
+    pyproject = root / "pyproject.toml"
+    if pyproject.exists():
+        text = pyproject.read_text()
+        for tool, section in PYPROJECT_SECTIONS.items():
+            if re.search(rf"^\[{re.escape(section)}[\].]", text, re.M):
+                found.append((f"pyproject.toml [{section}]", tool))
+
+    return found
+
+
+def detect_config_file_tools(root: Path) -> List[str]:
+    """Find tools whose configuration files are present"""
+    return list(dict.fromkeys(tool for _, tool in tool_config_files(root)))
+
+
+def detect_project_tools(root: Path) -> Dict[str, List[str]]:
+    """Detect which tools a project already uses, keyed by where they were found"""
+    return {
+        ".pre-commit-config.yaml": detect_pre_commit_tools(root),
+        "package.json scripts": detect_package_json_tools(root),
+        "Makefile": detect_makefile_tools(root),
+        "tool configuration files": detect_config_file_tools(root),
+    }
+
+
+def scaffold_config(root: Path) -> Dict[str, List[str]]:
+    """Build a taidy config that prefers the tools the project already uses"""
+    found = {tool for tools in detect_project_tools(root).values() for tool in tools}
+    return {"prefer": [tool for tool in KNOWN_TOOLS if tool in found]}
+
+
+def parse_pre_commit_hooks(text: str) -> List[Dict[str, Any]]:
+    """Read the hooks from a .pre-commit-config.yaml, each with its id and any args.
+
+    PyYAML reads it when installed; otherwise the usual layout, one key per line with
+    args as a flow list or a block list, is read line by line.
+    """
+    yaml: Any = None
+    try:
+        yaml = importlib.import_module("yaml")
+    except ImportError:
+        pass
+    if yaml is not None:
+        config = yaml.safe_load(text) or {}
+        repos = config.get("repos", []) if isinstance(config, dict) else []
+        return [
+            hook
+            for repo in repos
+            if isinstance(repo, dict)
+            for hook in repo.get("hooks") or []
+            if isinstance(hook, dict) and "id" in hook
+        ]
+
+    hooks: List[Dict[str, Any]] = []
+    in_args = False
+    for line in text.splitlines():
+        line = line.split(" #")[0].rstrip()
+        hook_id = re.match(r"^\s*-\s*id:\s*['\"]?([\w.-]+)", line)
+        if hook_id:
+            hooks.append({"id": hook_id.group(1)})
+            in_args = False
+            continue
+        if not hooks:
+            continue
+        args = re.match(r"^\s*args:\s*(.*)$", line)
+        if args:
+            flow = args.group(1).strip()
+            in_args = not flow
+            if flow.startswith("["):
+                items = re.findall(r"\"([^\"]*)\"|'([^']*)'|([^,\s][^,]*)", flow.strip("[]"))
+                hooks[-1]["args"] = ["".join(item).strip() for item in items]
+            continue
+        item = re.match(r"^\s*-\s*(.+)$", line)
+        if in_args and item:
+            hooks[-1].setdefault("args", []).append(item.group(1).strip().strip("'\""))
+        elif re.match(r"^\s*[\w-]+:", line):
+            in_args = False
+    return hooks
+
+
+def import_pre_commit(text: str) -> Tuple[Dict[str, Any], List[str]]:
+    """Convert pre-commit hooks into taidy config, with the ids of hooks it couldn't"""
+    prefer: List[str] = []
+    args: Dict[str, List[str]] = {}
+    skipped: List[str] = []
+    for hook in parse_pre_commit_hooks(text):
+        hook_id = str(hook["id"])
+        tool = PRE_COMMIT_HOOKS.get(hook_id)
+        if tool is None:
+            skipped.append(hook_id)
+            continue
+        if tool not in prefer:
+            prefer.append(tool)
+        extra = [str(arg) for arg in hook.get("args") or [] if str(arg) not in MODE_ARGS]
+        if extra:
+            key = PRE_COMMIT_ARGS_KEYS.get(hook_id, tool)
+            args[key] = args.get(key, []) + extra
+
+    config: Dict[str, Any] = {"prefer": prefer}
+    if args:
+        config["args"] = args
+    return config, skipped
+
+
+def import_lint_staged(text: str) -> Tuple[Dict[str, Any], List[str]]:
+    """Convert the "lint-staged" table in a package.json into custom tool definitions,
+    with the commands it couldn't convert"""
+    try:
+        rules = json.loads(text).get("lint-staged", {})
+    except (ValueError, AttributeError):
+        raise ValueError("package.json isn't a JSON object") from None
+    if not isinstance(rules, dict):
+        raise ValueError('"lint-staged" in package.json must map globs to commands')
+
+    tools: Dict[str, Dict[str, Any]] = {}
+    skipped: List[str] = []
+    for pattern, commands in rules.items():
+        for command in [commands] if isinstance(commands, str) else commands:
+            if not isinstance(command, str):
+                skipped.append(str(command))
+                continue
+            words = shlex.split(command)
+            # Older lint-staged needed `git add` to restage fixes; taidy --staged does it
+            if not words or words[:2] == ["git", "add"]:
+                continue
+            runner = words[0] in ["npx", "bunx", "pnpm", "yarn"] and len(words) > 1
+            name = words[1] if runner else words[0]
+            kind = "format" if FIXING_ARGS & set(words) or "format" in words else "lint"
+            # The same command on several globs is one tool on all of them
+            for definition in tools.values():
+                if definition["command"] == words and definition["kind"] == kind:
+                    definition["files"].append(pattern)
+                    break
+            else:
+                key, number = name, 2
+                while key in tools:
+                    key, number = f"{name}-{number}", number + 1
+                tools[key] = {"command": words, "files": [pattern], "kind": kind}
+
+    return {"tools": tools}, skipped
+
+
+def to_toml(config: Dict[str, Any], comments: List[str]) -> str:
+    """Write imported config as TOML: top-level values, then tables, each of which may
+    hold tables of its own"""
+    lines = [f"# {comment}" for comment in comments]
+
+    def write_table(name: str, table: Dict[str, Any]) -> None:
+        values = {key: value for key, value in table.items() if not isinstance(value, dict)}
+        if values or not table:
+            lines.append("")
+            lines.append(f"[{name}]")
+            for key, value in values.items():
+                lines.append(f"{json.dumps(key)} = {json.dumps(value)}")
+        for key, value in table.items():
+            if isinstance(value, dict):
+                write_table(f"{name}.{json.dumps(key)}", value)
+
+    for key, value in config.items():
+        if not isinstance(value, dict):
+            lines.append(f"{key} = {json.dumps(value)}")
+    for key, value in config.items():
+        if isinstance(value, dict):
+            write_table(key, value)
+    return "\n".join(lines) + "\n"
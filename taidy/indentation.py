@@ -0,0 +1,90 @@
+"""Check files for inconsistent indentation, for languages with no formatter to fix it.
+
+Each file is measured against itself rather than a style guide: lines indented with
+both tabs and spaces are reported, as are lines using whichever of tabs or spaces the
+rest of the file doesn't, and steps in space indentation that aren't a multiple of the
+width the file mostly steps by. Lines starting with `*`, inside block comments, are
+left out of the width check. Files that look binary are skipped.
+
+Findings are a cheap signal rather than errors, so this exits 0 whatever it finds.
+
+    python -m taidy.indentation FILE...
+"""
+
+import sys
+from collections import Counter
+from typing import List, Optional, Tuple
+
+
+def leading_whitespace(line: str) -> str:
+    """Get a line's indentation"""
+    return line[: len(line) - len(line.lstrip(" \t"))]
+
+
+def usual_width(lines: List[Tuple[int, str, str]]) -> Optional[int]:
+    """Find the width space indentation most often steps by, or None if it never does"""
+    steps: Counter = Counter()
+    previous = 0
+    for _, indent, content in lines:
+        if "\t" in indent or content.startswith("*"):
+            continue
+        if len(indent) > previous:
+            steps[len(indent) - previous] += 1
+        previous = len(indent)
+    if not steps:
+        return None
+    return steps.most_common(1)[0][0]
+
+
+def indentation_problems(path: str) -> List[str]:
+    """Describe each inconsistently indented line in a file"""
+    try:
+        with open(path, "rb") as f:
+            data = f.read()
+    except OSError as e:
+        return [f"{path}:1: {e.strerror}"]
+    if b"\0" in data[:8192]:
+        return []
+
+    lines = []
+    for number, line in enumerate(data.decode(errors="replace").splitlines(), 1):
+        indent = leading_whitespace(line.rstrip("\r"))
+        content = line[len(indent) :].strip()
+        if content:
+            lines.append((number, indent, content))
+
+    tabbed = sum(1 for _, indent, _ in lines if indent.startswith("\t"))
+    spaced = sum(1 for _, indent, _ in lines if indent.startswith(" "))
+    width = usual_width(lines)
+
+    found = []
+    previous = 0
+    for number, indent, content in lines:
+        location = f"{path}:{number}:1:"
+        if " " in indent and "\t" in indent:
+            found.append(f"{location} indentation mixes tabs and spaces")
+        elif indent.startswith("\t") and spaced > tabbed:
+            found.append(f"{location} indented with tabs, but most of the file uses spaces")
+        elif indent.startswith(" ") and tabbed > spaced:
+            found.append(f"{location} indented with spaces, but most of the file uses tabs")
+        elif indent and width and not content.startswith("*"):
+            step = len(indent) - previous
+            if step > 0 and step % width:
+                found.append(
+                    f"{location} indented by {step}, but the file usually indents by {width}"
+                )
+        if "\t" not in indent and not content.startswith("*"):
+            previous = len(indent)
+    return found
+
+
+def main(paths: List[str]) -> int:
+    """Check each file, printing every inconsistently indented line"""
+    for path in paths:
+        for problem in indentation_problems(path):
+            print(problem)
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main(sys.argv[1:]))
@@ -0,0 +1,67 @@
+"""Convert a run's report into JUnit XML, so CI systems show findings as test failures."""
+
+import os
+import xml.etree.ElementTree as ET
+from typing import Any, Dict, List
+
+from .diagnostics import Diagnostic, format_diagnostic
+from .report import Report
+
+
+def entry_fields(entry: Dict[str, Any]) -> Dict[str, Any]:
+    """Get a report entry's Diagnostic fields, dropping extras such as owners"""
+    return {key: value for key, value in entry.items() if key in Diagnostic.__dataclass_fields__}
+
+
+def diagnostic_lines(diagnostics: List[Dict[str, Any]]) -> str:
+    """Render diagnostics as one `path:line:column: message [tool]` line each"""
+    return "\n".join(format_diagnostic(Diagnostic(**entry_fields(d))) for d in diagnostics)
+
+
+def add_case(suite: ET.Element, name: str, tool: str, failure: str, message: str) -> None:
+    """Add a test case to a suite, failing with the given text if there is any"""
+    case = ET.SubElement(suite, "testcase", name=name, classname=tool)
+    if failure:
+        ET.SubElement(case, "failure", message=message, type="lint").text = failure
+
+
+def to_junit(report: Report, by_rule: bool = False) -> str:
+    """Render a report as JUnit XML: one suite per tool, one case per file (or per rule)"""
+    by_tool: Dict[str, Dict[str, List[int]]] = {}
+    for path, results in report.file_results.items():
+        for tool, exit_code in results:
+            by_tool.setdefault(tool, {}).setdefault(path, []).append(exit_code)
+    for entry in report.diagnostics:
+        by_tool.setdefault(entry["tool"], {})
+
+    root = ET.Element("testsuites", name="taidy")
+    for tool in sorted(by_tool):
+        suite = ET.SubElement(root, "testsuite", name=tool)
+        diagnostics = [d for d in report.diagnostics if d["tool"] == tool]
+
+        if by_rule:
+            rules = sorted({d["rule"] or "(no rule)" for d in diagnostics})
+            for rule in rules:
+                matching = [d for d in diagnostics if (d["rule"] or "(no rule)") == rule]
+                add_case(suite, rule, tool, diagnostic_lines(matching), f"{len(matching)} findings")
+            if not rules:
+                failing = [p for p, codes in by_tool[tool].items() if any(codes)]
+                failure = "\n".join(f"{tool} failed on {path}" for path in failing)
+                add_case(suite, tool, tool, failure, f"{tool} failed on {len(failing)} files")
+        else:
+            paths = set(by_tool[tool]) | {os.path.normpath(d["file"]) for d in diagnostics}
+            for path in sorted(paths):
+                matching = [d for d in diagnostics if os.path.normpath(d["file"]) == path]
+                failure = diagnostic_lines(matching)
+                if not failure and any(by_tool[tool].get(path, [])):
+                    failure = f"{tool} exited with status {max(by_tool[tool][path])}"
+                add_case(suite, path, tool, failure, f"{len(matching)} findings")
+
+        cases = suite.findall("testcase")
+        suite.set("tests", str(len(cases)))
+        suite.set("failures", str(sum(1 for case in cases if case.find("failure") is not None)))
+
+    suites = root.findall("testsuite")
+    root.set("tests", str(sum(int(suite.get("tests", "0")) for suite in suites)))
+    root.set("failures", str(sum(int(suite.get("failures", "0")) for suite in suites)))
+    return '<?xml version="1.0" encoding="UTF-8"?>\n' + ET.tostring(root, encoding="unicode")
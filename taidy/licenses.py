@@ -0,0 +1,130 @@
+"""Check and insert license headers, using each language's comment syntax."""
+
+import re
+from datetime import date
+from pathlib import Path
+from typing import Dict, List, Tuple, Union
+
+# Comment delimiters (prefix, suffix) used to write a header line, keyed like LINTER_MAP
+COMMENT_STYLES: Dict[str, Tuple[str, str]] = {
+    ".py": ("#", ""),
+    ".sh": ("#", ""),
+    ".bash": ("#", ""),
+    ".zsh": ("#", ""),
+    ".rb": ("#", ""),
+    ".yaml": ("#", ""),
+    ".yml": ("#", ""),
+    ".toml": ("#", ""),
+    ".tf": ("#", ""),
+    ".tfvars": ("#", ""),
+    "justfile": ("#", ""),
+    ".github-workflow": ("#", ""),
+    ".js": ("//", ""),
+    ".jsx": ("//", ""),
+    ".ts": ("//", ""),
+    ".tsx": ("//", ""),
+    ".go": ("//", ""),
+    ".rs": ("//", ""),
+    ".php": ("//", ""),
+    ".scss": ("//", ""),
+    ".pug": ("//-", ""),
+    ".css": ("/*", " */"),
+    ".html": ("<!--", " -->"),
+    ".md": ("<!--", " -->"),
+}
+
+# First lines that must stay above the header: shebangs, encoding cookies and the like
+PREAMBLE_PATTERN = re.compile(r"^(#!|#.*coding[:=]|<\?php|<!doctype)", re.I)
+
+YEAR_PATTERN = r"\d{4}(?:-\d{4})?"
+
+# How far into a file to look for an existing header
+HEADER_SEARCH_LINES = 10
+
+
+def template_lines(template: Union[str, List[str]]) -> List[str]:
+    """Split a license_header template, given as a string or a list of lines"""
+    return template.splitlines() if isinstance(template, str) else list(template)
+
+
+def render_header(template: Union[str, List[str]], key: str, year: int) -> List[str]:
+    """Render a header template as comment lines for a file type"""
+    prefix, suffix = COMMENT_STYLES[key]
+    return [
+        f"{prefix} {line.replace('{year}', str(year))}{suffix}" if line else prefix
+        for line in template_lines(template)
+    ]
+
+
+def header_pattern(template: Union[str, List[str]], key: str) -> "re.Pattern[str]":
+    """Build a pattern matching a rendered header with any year (or year range)"""
+    prefix, suffix = COMMENT_STYLES[key]
+    lines = []
+    for line in template_lines(template):
+        if not line:
+            lines.append(re.escape(prefix))
+            continue
+        parts = [re.escape(part) for part in line.split("{year}")]
+        lines.append(re.escape(f"{prefix} ") + YEAR_PATTERN.join(parts) + re.escape(suffix))
+    return re.compile(r"\s*\n".join(lines), re.M)
+
+
+def preamble_length(lines: List[str]) -> int:
+    """Count the leading lines that must stay above a license header"""
+    count = 0
+    while count < len(lines) and PREAMBLE_PATTERN.match(lines[count]):
+        count += 1
+    return count
+
+
+def has_header(text: str, template: Union[str, List[str]], key: str) -> bool:
+    """Check whether a file starts with the license header"""
+    lines = text.splitlines()
+    head = len(template_lines(template)) + preamble_length(lines) + HEADER_SEARCH_LINES
+    return header_pattern(template, key).search("\n".join(lines[:head])) is not None
+
+
+def add_header(text: str, template: Union[str, List[str]], key: str, year: int) -> str:
+    """Insert the license header below any preamble, separated by a blank line"""
+    lines = text.splitlines(keepends=True)
+    position = preamble_length([line.rstrip("\n") for line in lines])
+    header = "\n".join(render_header(template, key, year)) + "\n"
+    rest = "".join(lines[position:])
+    if rest.strip():
+        header += "\n"
+    return "".join(lines[:position]) + header + rest
+
+
+def check_license_headers(
+    files: List[Tuple[Path, str]], template: Union[str, List[str]], fix: bool = False
+) -> int:
+    """Check (or with fix=True, insert) license headers in (path, extension key) pairs.
+
+    Files whose type has no comment syntax, such as JSON, are skipped. Without fix the
+    exit code is 1 if any file is missing its header.
+    """
+    year = date.today().year
+    missing = 0
+
+    for path, key in files:
+        if key not in COMMENT_STYLES:
+            continue
+
+        try:
+            text = path.read_text()
+        except (OSError, UnicodeDecodeError):
+            continue
+
+        if has_header(text, template, key):
+            continue
+
+        if fix:
+            path.write_text(add_header(text, template, key, year))
+            print(f"Added license header to {path}")
+        else:
+            print(f"Missing license header: {path}")
+            missing += 1
+
+    if missing:
+        print(f"{missing} file(s) missing a license header; run `taidy license --fix` to add it")
+    return 1 if missing else 0
@@ -0,0 +1,207 @@
+"""A Language Server Protocol server, for editors to get taidy's findings and formatting.
+
+Documents are linted when opened and saved, and their findings published with
+textDocument/publishDiagnostics. textDocument/formatting replaces the whole document with
+what its formatter makes of it. Messages are JSON-RPC with Content-Length headers, on
+stdin and stdout.
+"""
+
+import json
+from typing import IO, Any, Callable, Dict, List, Optional
+from urllib.parse import urlparse
+from urllib.request import url2pathname
+
+from .diagnostics import Diagnostic
+
+# Lints a document's text given its path, and formats it, returning None if it can't
+Linter = Callable[[str, str], List[Diagnostic]]
+Formatter = Callable[[str, str], Optional[str]]
+
+# LSP's DiagnosticSeverity values
+SEVERITIES = {"error": 1, "warning": 2, "info": 3, "hint": 4}
+
+# Standard JSON-RPC error codes
+METHOD_NOT_FOUND = -32601
+INTERNAL_ERROR = -32603
+
+# textDocumentSync kind where each change sends the whole document
+FULL_SYNC = 1
+
+
+def read_message(reader: IO[bytes]) -> Optional[Dict[str, Any]]:
+    """Read one message, or None once the client has closed the stream"""
+    length = None
+    while True:
+        line = reader.readline()
+        if not line:
+            return None
+        line = line.strip()
+        if not line:
+            break
+        name, _, value = line.decode("ascii").partition(":")
+        if name.strip().lower() == "content-length":
+            length = int(value)
+
+    if length is None:
+        raise ValueError("Message has no Content-Length header")
+    message = json.loads(reader.read(length))
+    if not isinstance(message, dict):
+        raise ValueError("Expected a JSON-RPC message object")
+    return message
+
+
+def write_message(writer: IO[bytes], message: Dict[str, Any]) -> None:
+    """Write one message with its Content-Length header"""
+    body = json.dumps(message).encode()
+    writer.write(f"Content-Length: {len(body)}\r\n\r\n".encode() + body)
+    writer.flush()
+
+
+def uri_to_path(uri: str) -> str:
+    """Get the path of a file:// URI"""
+    return url2pathname(urlparse(uri).path)
+
+
+def to_lsp_diagnostic(diagnostic: Diagnostic) -> Dict[str, Any]:
+    """Convert a finding to an LSP diagnostic; findings without a column cover their line"""
+    line = max(diagnostic.line - 1, 0)
+    if diagnostic.column is None:
+        start, end = {"line": line, "character": 0}, {"line": line + 1, "character": 0}
+    else:
+        column = max(diagnostic.column - 1, 0)
+        end_column = column + 1
+        if diagnostic.end_column is not None and diagnostic.end_column - 1 > column:
+            end_column = diagnostic.end_column - 1
+        start, end = {"line": line, "character": column}, {"line": line, "character": end_column}
+
+    entry: Dict[str, Any] = {
+        "range": {"start": start, "end": end},
+        "severity": SEVERITIES.get(diagnostic.severity, SEVERITIES["error"]),
+        "source": diagnostic.tool,
+        "message": diagnostic.message,
+    }
+    if diagnostic.rule is not None:
+        entry["code"] = diagnostic.rule
+    return entry
+
+
+def whole_document_edit(text: str, new_text: str) -> Dict[str, Any]:
+    """Build a TextEdit replacing all of a document's text"""
+    lines = text.split("\n")
+    end = {"line": len(lines) - 1, "character": len(lines[-1])}
+    return {"range": {"start": {"line": 0, "character": 0}, "end": end}, "newText": new_text}
+
+
+class LanguageServer:
+    """The state of one editor session: its open documents, and whether it's shutting down"""
+
+    def __init__(self, writer: IO[bytes], linter: Linter, formatter: Formatter, version: str):
+        self.writer = writer
+        self.linter = linter
+        self.formatter = formatter
+        self.version = version
+        # Text of each open document, by URI, as the editor last sent it
+        self.documents: Dict[str, str] = {}
+        self.shutdown_requested = False
+
+    def notify(self, method: str, params: Dict[str, Any]) -> None:
+        """Send the client a notification"""
+        write_message(self.writer, {"jsonrpc": "2.0", "method": method, "params": params})
+
+    def publish(self, uri: str) -> None:
+        """Lint a document and publish its findings, or clear them once it's closed"""
+        text = self.documents.get(uri)
+        findings = [] if text is None else self.linter(uri_to_path(uri), text)
+        diagnostics = [to_lsp_diagnostic(finding) for finding in findings]
+        self.notify("textDocument/publishDiagnostics", {"uri": uri, "diagnostics": diagnostics})
+
+    def formatting(self, uri: str) -> List[Dict[str, Any]]:
+        """Get the edits that format a document, none if it's formatted or can't be"""
+        path = uri_to_path(uri)
+        text = self.documents.get(uri)
+        if text is None:
+            with open(path, encoding="utf-8", errors="replace") as f:
+                text = f.read()
+
+        formatted = self.formatter(path, text)
+        if formatted is None or formatted == text:
+            return []
+        return [whole_document_edit(text, formatted)]
+
+    def handle(self, method: str, params: Dict[str, Any]) -> Any:
+        """Handle a request or notification, returning a request's result"""
+        document = params.get("textDocument", {})
+        uri = document.get("uri", "")
+
+        if method == "initialize":
+            return {
+                "capabilities": {
+                    "textDocumentSync": {
+                        "openClose": True,
+                        "change": FULL_SYNC,
+                        "save": {"includeText": True},
+                    },
+                    "documentFormattingProvider": True,
+                },
+                "serverInfo": {"name": "taidy", "version": self.version},
+            }
+        if method == "shutdown":
+            self.shutdown_requested = True
+            return None
+        if method == "textDocument/didOpen":
+            self.documents[uri] = document.get("text", "")
+            self.publish(uri)
+        elif method == "textDocument/didChange":
+            changes = params.get("contentChanges", [])
+            if changes:
+                self.documents[uri] = changes[-1].get("text", "")
+        elif method == "textDocument/didSave":
+            if "text" in params:
+                self.documents[uri] = params["text"]
+            self.publish(uri)
+        elif method == "textDocument/didClose":
+            self.documents.pop(uri, None)
+            self.publish(uri)
+        elif method == "textDocument/formatting":
+            return self.formatting(uri)
+        return None
+
+
+def serve_lsp(
+    reader: IO[bytes], writer: IO[bytes], linter: Linter, formatter: Formatter, version: str
+) -> int:
+    """Serve an editor until it sends exit, returning the exit code LSP expects"""
+    server = LanguageServer(writer, linter, formatter, version)
+    known_requests = ["initialize", "shutdown", "textDocument/formatting"]
+
+    while True:
+        message = read_message(reader)
+        if message is None:
+            return 1
+        method = message.get("method")
+        if method == "exit":
+            return 0 if server.shutdown_requested else 1
+        if not isinstance(method, str):
+            # Responses to requests taidy never sends
+            continue
+
+        params = message.get("params") or {}
+        is_request = "id" in message
+        if is_request and method not in known_requests:
+            error = {"code": METHOD_NOT_FOUND, "message": f"Unknown method: {method}"}
+            write_message(writer, {"jsonrpc": "2.0", "id": message["id"], "error": error})
+            continue
+
+        try:
+            result = server.handle(method, params)
+        except Exception as e:
+            if is_request:
+                error = {"code": INTERNAL_ERROR, "message": str(e)}
+                write_message(writer, {"jsonrpc": "2.0", "id": message["id"], "error": error})
+            else:
+                # Notifications have no reply, so the failure goes to the editor's log
+                server.notify("window/logMessage", {"type": 1, "message": f"taidy: {e}"})
+            continue
+
+        if is_request:
+            write_message(writer, {"jsonrpc": "2.0", "id": message["id"], "result": result})
@@ -0,0 +1,269 @@
+"""taidy's own messages, in English and the locales contributors have translated them to.
+
+The locale comes from TAIDY_LANG, such as "de" or "de_DE.UTF-8"; without it, or for a
+locale or message that hasn't been translated, messages are in English. Only taidy's
+own messages are translated: tools' output passes through untouched.
+
+To add a locale, copy the "en" table and translate its values, keeping the {names}.
+"""
+
+import os
+from typing import Any, Dict
+
+MESSAGES: Dict[str, Dict[str, str]] = {
+    "en": {
+        "running": "Running: {command}",
+        "command_not_found": "Error executing {command}: command not found",
+        "interactive_setup": (
+            '{command} requires interactive setup: it stopped to ask "{prompt}". '
+            "Run {command} once in a terminal to answer it, then run taidy again"
+        ),
+        "no_linter_for_extension": "No available linter found for {extension} files",
+        "no_formatter_for_extension": "No available formatter found for {extension} files",
+        "no_linter_for_file": "No available linter found for {file}",
+        "no_formatter_for_file": "No available formatter found for {file}",
+        "no_linter_configured": "No linter configured for file {file} (extension: {extension})",
+        "path_missing": "Path {path} does not exist, skipping",
+        "no_files_match": "No files match {pattern}, skipping",
+        "discovered": "Discovered {count} supported files in {directory}",
+        "no_supported_in_directory": "No supported files found in directory {directory}",
+        "no_supported_files": "No supported files provided, no files were linted",
+        "no_tools": "No tools available for these files; see `taidy suggest`",
+        "no_tools_login_shell": (
+            "No tools available for these files; see `taidy suggest`, or try --login-shell"
+        ),
+        "no_staged_files": "No staged files to process",
+        "staged_fixes": "Staged formatting fixes to {count} file(s)",
+        "reformatted": "Reformatted {count} file(s)",
+        "reformatted_in": "Reformatted {count} file(s) in {seconds:.1f}s",
+        "finished_in": "Finished in {seconds:.1f}s",
+        "tidiness_score": "Tidiness score: {score} ({total} findings)",
+        "interrupted": "Interrupted: stopping the tools still running and reporting results so far",
+        "summary_language": "Language",
+        "summary_files": "Files",
+        "summary_errors": "Errors",
+        "summary_warnings": "Warnings",
+        "summary_tools": "Tools",
+        "summary_no_tools": "(none available)",
+        "minified_skipped": (
+            "Not passing {file} to linters or formatters as it looks minified (name it "
+            'explicitly, or set "skip_minified": false, to override)'
+        ),
+        "oversized_skipped": "Skipping {file} ({size}), over the {limit} --max-file-size",
+        "sensitive_not_scanned": "Not scanning {file} for secrets as trufflehog is not installed",
+        "sensitive_skipped": (
+            "Not passing {file} to linters or formatters as it may contain secrets (use "
+            "--allow-sensitive to override, or --scan-sensitive to scan it)"
+        ),
+        "no_files_modified": "No files modified in the --modified-since window",
+        "format_first": "Formatting before linting: {languages}",
+        "tool_unusable": "--tool {tool}: {tool} isn't installed, or its conditions aren't met",
+        "custom_tool_missing": "Custom tool {tool}: {command} not found, skipping",
+        "tool_args_ambiguous": (
+            "Arguments after a second `--` go to one tool, but this run uses {tools}; "
+            "narrow it with `taidy lint` or `taidy format`, --lang or --tool"
+        ),
+        "resuming": "Resuming: skipped {count} file checks completed by the previous run",
+        "command_failed": "Error executing {command}: {error}",
+        "budget_files": "{count} files (limit {limit})",
+        "budget_lines": "{count} lines (limit {limit})",
+        "budget_exceeded": (
+            "Formatting would change {changes}; no files were changed. Raise the limit or "
+            "format a smaller set of files"
+        ),
+        "within_thresholds": "{tool}: {count} findings, within its thresholds",
+        "moved_downgraded": "Downgraded {count} findings on moved code to info",
+        "runs_failed": "{failed} of {total} tool runs reported issues",
+        "runs_passed": "All {total} tool runs passed",
+        "custom_tools_ignored": "Ignoring custom tools: {error}",
+        "thresholds_ignored": "Ignoring {error}",
+    },
+    "de": {
+        "running": "Ausführen: {command}",
+        "command_not_found": "Fehler beim Ausführen von {command}: Befehl nicht gefunden",
+        "interactive_setup": (
+            '{command} muss interaktiv eingerichtet werden: es wartet auf eine Antwort auf '
+            '"{prompt}". Führe {command} einmal in einem Terminal aus, beantworte die Frage '
+            "und starte taidy dann erneut"
+        ),
+        "no_linter_for_extension": "Kein verfügbarer Linter für {extension}-Dateien gefunden",
+        "no_formatter_for_extension": (
+            "Kein verfügbarer Formatierer für {extension}-Dateien gefunden"
+        ),
+        "no_linter_for_file": "Kein verfügbarer Linter für {file} gefunden",
+        "no_formatter_for_file": "Kein verfügbarer Formatierer für {file} gefunden",
+        "no_linter_configured": (
+            "Kein Linter für die Datei {file} konfiguriert (Endung: {extension})"
+        ),
+        "path_missing": "Pfad {path} existiert nicht, wird übersprungen",
+        "no_files_match": "Keine Dateien passen zu {pattern}, wird übersprungen",
+        "discovered": "{count} unterstützte Dateien in {directory} gefunden",
+        "no_supported_in_directory": "Keine unterstützten Dateien im Verzeichnis {directory}",
+        "no_supported_files": "Keine unterstützten Dateien angegeben, nichts wurde geprüft",
+        "no_tools": "Keine Werkzeuge für diese Dateien verfügbar; siehe `taidy suggest`",
+        "no_tools_login_shell": (
+            "Keine Werkzeuge für diese Dateien verfügbar; siehe `taidy suggest` "
+            "oder versuche --login-shell"
+        ),
+        "no_staged_files": "Keine vorgemerkten Dateien zu verarbeiten",
+        "staged_fixes": "Formatierungskorrekturen an {count} Datei(en) vorgemerkt",
+        "reformatted": "{count} Datei(en) neu formatiert",
+        "reformatted_in": "{count} Datei(en) in {seconds:.1f}s neu formatiert",
+        "finished_in": "Fertig in {seconds:.1f}s",
+        "tidiness_score": "Sauberkeitswert: {score} ({total} Befunde)",
+        "interrupted": (
+            "Unterbrochen: laufende Werkzeuge werden beendet, bisherige Ergebnisse folgen"
+        ),
+        "summary_language": "Sprache",
+        "summary_files": "Dateien",
+        "summary_errors": "Fehler",
+        "summary_warnings": "Warnungen",
+        "summary_tools": "Werkzeuge",
+        "summary_no_tools": "(keine verfügbar)",
+        "minified_skipped": (
+            "{file} wird nicht an Linter oder Formatierer übergeben, da sie minifiziert "
+            'aussieht (zum Übergehen ausdrücklich angeben oder "skip_minified": false setzen)'
+        ),
+        "oversized_skipped": (
+            "{file} ({size}) wird übersprungen, größer als die --max-file-size von {limit}"
+        ),
+        "sensitive_not_scanned": (
+            "{file} wird nicht nach Geheimnissen durchsucht, da trufflehog nicht installiert ist"
+        ),
+        "sensitive_skipped": (
+            "{file} wird nicht an Linter oder Formatierer übergeben, da sie Geheimnisse "
+            "enthalten könnte (--allow-sensitive übergeht das, --scan-sensitive durchsucht sie)"
+        ),
+        "no_files_modified": "Keine Dateien im --modified-since-Zeitraum geändert",
+        "format_first": "Formatieren vor dem Prüfen: {languages}",
+        "tool_unusable": (
+            "--tool {tool}: {tool} ist nicht installiert oder seine Bedingungen sind nicht erfüllt"
+        ),
+        "custom_tool_missing": (
+            "Eigenes Werkzeug {tool}: {command} nicht gefunden, wird übersprungen"
+        ),
+        "tool_args_ambiguous": (
+            "Argumente nach einem zweiten `--` gehen an ein Werkzeug, aber dieser Lauf nutzt "
+            "{tools}; schränke ihn mit `taidy lint` oder `taidy format`, --lang oder --tool ein"
+        ),
+        "resuming": (
+            "Fortsetzen: {count} im vorigen Lauf abgeschlossene Dateiprüfungen übersprungen"
+        ),
+        "command_failed": "Fehler beim Ausführen von {command}: {error}",
+        "budget_files": "{count} Dateien (Grenze {limit})",
+        "budget_lines": "{count} Zeilen (Grenze {limit})",
+        "budget_exceeded": (
+            "Das Formatieren würde {changes} ändern; keine Dateien wurden geändert. Erhöhe "
+            "die Grenze oder formatiere weniger Dateien"
+        ),
+        "within_thresholds": "{tool}: {count} Befunde, innerhalb der Schwellenwerte",
+        "moved_downgraded": "{count} Befunde in verschobenem Code zu Hinweisen herabgestuft",
+        "runs_failed": "{failed} von {total} Werkzeugläufen meldeten Probleme",
+        "runs_passed": "Alle {total} Werkzeugläufe erfolgreich",
+        "custom_tools_ignored": "Eigene Werkzeuge werden ignoriert: {error}",
+        "thresholds_ignored": "Wird ignoriert: {error}",
+    },
+    "es": {
+        "running": "Ejecutando: {command}",
+        "command_not_found": "Error al ejecutar {command}: no se encontró el comando",
+        "interactive_setup": (
+            '{command} necesita configurarse de forma interactiva: se detuvo a preguntar '
+            '"{prompt}". Ejecuta {command} una vez en una terminal para responder y luego '
+            "vuelve a ejecutar taidy"
+        ),
+        "no_linter_for_extension": "No hay ningún linter disponible para archivos {extension}",
+        "no_formatter_for_extension": (
+            "No hay ningún formateador disponible para archivos {extension}"
+        ),
+        "no_linter_for_file": "No hay ningún linter disponible para {file}",
+        "no_formatter_for_file": "No hay ningún formateador disponible para {file}",
+        "no_linter_configured": (
+            "No hay ningún linter configurado para el archivo {file} (extensión: {extension})"
+        ),
+        "path_missing": "La ruta {path} no existe, se omite",
+        "no_files_match": "Ningún archivo coincide con {pattern}, se omite",
+        "discovered": "Se encontraron {count} archivos compatibles en {directory}",
+        "no_supported_in_directory": (
+            "No se encontraron archivos compatibles en el directorio {directory}"
+        ),
+        "no_supported_files": "No se indicaron archivos compatibles, no se revisó ninguno",
+        "no_tools": "No hay herramientas disponibles para estos archivos; consulta `taidy suggest`",
+        "no_tools_login_shell": (
+            "No hay herramientas disponibles para estos archivos; consulta `taidy suggest` "
+            "o prueba --login-shell"
+        ),
+        "no_staged_files": "No hay archivos preparados para procesar",
+        "staged_fixes": "Se prepararon las correcciones de formato de {count} archivo(s)",
+        "reformatted": "Se reformatearon {count} archivo(s)",
+        "reformatted_in": "Se reformatearon {count} archivo(s) en {seconds:.1f}s",
+        "finished_in": "Terminado en {seconds:.1f}s",
+        "tidiness_score": "Puntuación de limpieza: {score} ({total} hallazgos)",
+        "interrupted": (
+            "Interrumpido: se detienen las herramientas en curso y se muestran los resultados "
+            "obtenidos"
+        ),
+        "summary_language": "Lenguaje",
+        "summary_files": "Archivos",
+        "summary_errors": "Errores",
+        "summary_warnings": "Avisos",
+        "summary_tools": "Herramientas",
+        "summary_no_tools": "(ninguna disponible)",
+        "minified_skipped": (
+            "No se pasa {file} a linters ni formateadores porque parece minificado (nómbralo "
+            'explícitamente, o define "skip_minified": false, para incluirlo)'
+        ),
+        "oversized_skipped": "Se omite {file} ({size}), supera el --max-file-size de {limit}",
+        "sensitive_not_scanned": (
+            "No se busca secretos en {file} porque trufflehog no está instalado"
+        ),
+        "sensitive_skipped": (
+            "No se pasa {file} a linters ni formateadores porque puede contener secretos "
+            "(usa --allow-sensitive para incluirlo, o --scan-sensitive para analizarlo)"
+        ),
+        "no_files_modified": "Ningún archivo modificado en el intervalo de --modified-since",
+        "format_first": "Formateando antes de revisar: {languages}",
+        "tool_unusable": (
+            "--tool {tool}: {tool} no está instalado o no se cumplen sus condiciones"
+        ),
+        "custom_tool_missing": (
+            "Herramienta personalizada {tool}: no se encontró {command}, se omite"
+        ),
+        "tool_args_ambiguous": (
+            "Los argumentos tras un segundo `--` van a una sola herramienta, pero esta ejecución "
+            "usa {tools}; limítala con `taidy lint` o `taidy format`, --lang o --tool"
+        ),
+        "resuming": (
+            "Reanudando: se omitieron {count} revisiones de archivos completadas en la "
+            "ejecución anterior"
+        ),
+        "command_failed": "Error al ejecutar {command}: {error}",
+        "budget_files": "{count} archivos (límite {limit})",
+        "budget_lines": "{count} líneas (límite {limit})",
+        "budget_exceeded": (
+            "El formateo cambiaría {changes}; no se cambió ningún archivo. Sube el límite o "
+            "formatea menos archivos"
+        ),
+        "within_thresholds": "{tool}: {count} hallazgos, dentro de sus umbrales",
+        "moved_downgraded": "Se rebajaron a informativos {count} hallazgos en código movido",
+        "runs_failed": "{failed} de {total} ejecuciones de herramientas informaron problemas",
+        "runs_passed": "Las {total} ejecuciones de herramientas pasaron",
+        "custom_tools_ignored": "Se ignoran las herramientas personalizadas: {error}",
+        "thresholds_ignored": "Se ignora: {error}",
+    },
+}
+
+
+def current_locale() -> str:
+    """Get the locale TAIDY_LANG selects, such as "de" for de_DE.UTF-8, or "en" """
+    setting = os.environ.get("TAIDY_LANG", "")
+    language = setting.split(".")[0].replace("-", "_").lower()
+    if language in MESSAGES:
+        return language
+    language = language.split("_")[0]
+    return language if language in MESSAGES else "en"
+
+
+def message(key: str, **values: Any) -> str:
+    """Get a message in the current locale, with its {names} filled in from values"""
+    template = MESSAGES[current_locale()].get(key, MESSAGES["en"][key])
+    return template.format(**values)
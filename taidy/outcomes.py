@@ -0,0 +1,124 @@
+"""Judge whether a tool run passed, found problems, or failed to run properly."""
+
+from dataclasses import dataclass, field
+from enum import Enum
+from typing import Any, Dict, List, Optional
+
+from .diagnostics import Diagnostic
+
+
+class Outcome(Enum):
+    PASSED = "passed"
+    FINDINGS = "findings"  # The tool ran and reported problems in the files
+    ERROR = "error"  # The tool itself failed, e.g. bad usage or a crash
+
+
+# Streams a tool's output can be checked on, for fail_on_output
+OUTPUT_STREAMS = ["stdout", "stderr", "any"]
+
+
+@dataclass
+class SuccessCriteria:
+    """How to read a tool's exit code and output"""
+
+    success_codes: List[int] = field(default_factory=lambda: [0])
+    # Exit codes meaning the tool failed to run, rather than that it found problems
+    error_codes: List[int] = field(default_factory=list)
+    # Output on this stream means findings, for tools that always exit 0, like gofmt -l
+    fail_on_output: Optional[str] = None
+    # Parsed findings mean failure even with a success code, as with eslint's warnings
+    fail_on_findings: bool = False
+
+
+# Tools whose exit codes don't follow 0 for clean, 1 for findings, anything else for errors
+BUILTIN_CRITERIA: Dict[str, SuccessCriteria] = {
+    "gofmt": SuccessCriteria(fail_on_output="stdout"),
+    # Exit 2 is a configuration or internal error for these
+    "ruff": SuccessCriteria(error_codes=[2]),
+    "eslint": SuccessCriteria(error_codes=[2]),
+    "prettier": SuccessCriteria(error_codes=[2]),
+    "rubocop": SuccessCriteria(error_codes=[2]),
+    "actionlint": SuccessCriteria(error_codes=[2, 3]),
+    "shellcheck": SuccessCriteria(error_codes=[2, 3, 4]),
+    "black": SuccessCriteria(error_codes=[123]),
+    # tflint exits 2 for issues and 1 for its own errors
+    "tflint": SuccessCriteria(error_codes=[1]),
+    # pylint's exit code is a bit mask, where 1 is a fatal message and 32 a usage error
+    "pylint": SuccessCriteria(error_codes=[code for code in range(64) if code & 33]),
+}
+
+
+def parse_criteria(key: str, entry: Dict[str, Any]) -> SuccessCriteria:
+    """Build criteria from a config "success" entry, raising ValueError if it's malformed"""
+    criteria = SuccessCriteria()
+    for name, value in entry.items():
+        if name in ["success_codes", "error_codes"]:
+            if not isinstance(value, list) or not all(isinstance(code, int) for code in value):
+                raise ValueError(f'"success" for {key}: {name} must be a list of exit codes')
+            setattr(criteria, name, value)
+        elif name == "fail_on_output":
+            if value not in OUTPUT_STREAMS:
+                streams = ", ".join(OUTPUT_STREAMS)
+                raise ValueError(f'"success" for {key}: fail_on_output must be one of {streams}')
+            criteria.fail_on_output = value
+        elif name == "fail_on_findings":
+            if not isinstance(value, bool):
+                raise ValueError(f'"success" for {key}: fail_on_findings must be true or false')
+            criteria.fail_on_findings = value
+        else:
+            raise ValueError(f'"success" for {key}: unknown setting {name}')
+    return criteria
+
+
+def classify(
+    criteria: SuccessCriteria, exit_code: int, stdout: str, stderr: str, findings: int = 0
+) -> Outcome:
+    """Decide a run's outcome from its exit code, output and number of parsed findings"""
+    if exit_code in criteria.error_codes:
+        return Outcome.ERROR
+    if exit_code not in criteria.success_codes:
+        return Outcome.FINDINGS
+
+    output = {"stdout": stdout, "stderr": stderr, "any": stdout + stderr}
+    if criteria.fail_on_output is not None and output[criteria.fail_on_output].strip():
+        return Outcome.FINDINGS
+    if criteria.fail_on_findings and findings:
+        return Outcome.FINDINGS
+    return Outcome.PASSED
+
+
+def outcome_exit_code(outcome: Outcome, exit_code: int) -> int:
+    """Get the exit code taidy treats a run as having, so passing runs count as 0"""
+    if outcome == Outcome.PASSED:
+        return 0
+    return exit_code or 1
+
+
+# Threshold settings, and the severity each one counts (None counts every finding)
+THRESHOLD_SETTINGS: Dict[str, Optional[str]] = {
+    "max_errors": "error",
+    "max_warnings": "warning",
+    "max_findings": None,
+}
+
+
+def threshold_violations(
+    tool: str, diagnostics: List[Diagnostic], limits: Dict[str, Any]
+) -> List[str]:
+    """Check a tool's findings against its configured limits, describing each one exceeded.
+
+    Raises ValueError if the limits are malformed.
+    """
+    violations = []
+    for name, limit in limits.items():
+        if name not in THRESHOLD_SETTINGS:
+            raise ValueError(f'"thresholds" for {tool}: unknown setting {name}')
+        if not isinstance(limit, int) or limit < 0:
+            raise ValueError(f'"thresholds" for {tool}: {name} must be a whole number')
+
+        severity = THRESHOLD_SETTINGS[name]
+        count = sum(1 for d in diagnostics if severity is None or d.severity == severity)
+        if count > limit:
+            noun = f"{severity}s" if severity else "findings"
+            violations.append(f"{count} {noun}, over the limit of {limit}")
+    return violations
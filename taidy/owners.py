@@ -0,0 +1,44 @@
+"""Attribute files to their owners using a CODEOWNERS file."""
+
+from pathlib import Path
+from typing import List, Tuple
+
+from .ignores import IgnoreRule, compile_pattern, rule_matches
+
+# Where GitHub, GitLab and Bitbucket look for CODEOWNERS, in GitHub's order of precedence
+CODEOWNERS_PATHS = [".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"]
+
+UNOWNED = "(no owner)"
+
+
+def parse_codeowners(text: str) -> List[Tuple[IgnoreRule, List[str]]]:
+    """Parse CODEOWNERS lines into (pattern, owners) pairs, skipping comments and sections"""
+    entries = []
+    for line in text.splitlines():
+        line = line.split(" #", 1)[0].strip()
+        # GitLab section headers such as `[Docs]` or `^[Optional]`
+        if not line or line.startswith(("#", "[", "^[")):
+            continue
+
+        pattern, *owners = line.split()
+        rule = compile_pattern(pattern)
+        if rule is not None:
+            entries.append((rule, owners))
+    return entries
+
+
+def load_codeowners(root: Path) -> List[Tuple[IgnoreRule, List[str]]]:
+    """Load the project's CODEOWNERS, if it has one"""
+    for name in CODEOWNERS_PATHS:
+        path = root / name
+        if path.is_file():
+            return parse_codeowners(path.read_text())
+    return []
+
+
+def owners_of(relative_path: str, entries: List[Tuple[IgnoreRule, List[str]]]) -> List[str]:
+    """Find a file's owners; the last matching line wins, and may assign no owners"""
+    for rule, owners in reversed(entries):
+        if rule_matches(rule, relative_path):
+            return owners
+    return []
@@ -0,0 +1,101 @@
+"""Render a run's report as plain text for screen readers and other assistive technology.
+
+Every line starts with a word saying what it is (RUN, OUTPUT, FINDING, FILE, SUMMARY),
+results are spelled out as PASS or FAIL rather than shown by colour or symbols, and no
+control sequences are ever written, even from tools that coloured their own output.
+"""
+
+import re
+from typing import Any, Dict, List
+
+from .diagnostics import load_json
+from .report import Report
+
+# Environment asking tools not to colour their output or treat the terminal as capable
+NO_COLOUR_ENVIRONMENT = {"NO_COLOR": "1", "FORCE_COLOR": "0", "TERM": "dumb"}
+
+# Terminal escape sequences (window titles and links, then colours and cursor movement),
+# and control characters other than tab
+CONTROL_PATTERN = re.compile(
+    r"\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b[@-_]"
+    r"|[\x00-\x08\x0b-\x1f\x7f]"
+)
+
+
+def plain(text: str) -> str:
+    """Remove escape sequences and control characters, and expand tabs"""
+    return CONTROL_PATTERN.sub("", text).expandtabs(4)
+
+
+def plural(count: int, noun: str) -> str:
+    """Say how many of something there are, as in "1 finding" or "2 findings" """
+    return f"{count} {noun}" if count == 1 else f"{count} {noun}s"
+
+
+def finding_line(entry: Dict[str, Any]) -> str:
+    """Describe a finding in words, with its location spelled out"""
+    location = f"{entry['file']} line {entry['line']}"
+    if entry.get("column") is not None:
+        location += f" column {entry['column']}"
+    rule = f"{entry['rule']} " if entry.get("rule") else ""
+    severity = str(entry.get("severity", "error")).upper()
+    return plain(f"FINDING {severity} {location}: {rule}{entry['message']}, from {entry['tool']}")
+
+
+def to_plain_verbose(report: Report, exit_code: int) -> str:
+    """Render a report one self-describing line at a time"""
+    lines: List[str] = []
+    if report.mode:
+        lines.append(f"MODE {report.mode}")
+
+    failed_runs = 0
+    for run in report.runs:
+        status = "PASS" if run.exit_code == 0 else "FAIL"
+        failed_runs += run.exit_code != 0
+        lines.append(
+            plain(
+                f"RUN {status} {' '.join(run.command)}, "
+                f"exit status {run.exit_code}, {run.duration:.1f} seconds"
+            )
+        )
+        if run.exit_code == 0:
+            continue
+        # Findings the tool reported as JSON are listed below; anything else is shown as is
+        streams = [run.stderr] if load_json(run.stdout) is not None else [run.stdout, run.stderr]
+        for stream in streams:
+            for line in stream.splitlines():
+                if line.strip():
+                    lines.append(f"OUTPUT {run.tool}: {plain(line)}")
+
+    for entry in report.diagnostics:
+        lines.append(finding_line(entry))
+
+    counts: Dict[str, int] = {}
+    for entry in report.diagnostics:
+        counts[entry["file"]] = counts.get(entry["file"], 0) + 1
+    failed_files = 0
+    for path, results in sorted(report.file_results.items()):
+        if not results:
+            lines.append(plain(f"FILE SKIPPED {path}"))
+        elif any(code != 0 for _, code in results) or counts.get(path):
+            failed_files += 1
+            lines.append(plain(f"FILE FAIL {path}, {plural(counts.get(path, 0), 'finding')}"))
+        else:
+            lines.append(plain(f"FILE PASS {path}"))
+
+    if report.reason is not None:
+        lines.append(f"NOTE nothing was processed: {report.reason}")
+
+    severities: Dict[str, int] = {}
+    for entry in report.diagnostics:
+        severity = str(entry.get("severity", "error"))
+        severities[severity] = severities.get(severity, 0) + 1
+    lines.append(
+        f"SUMMARY {'PASS' if exit_code == 0 else 'FAIL'}: "
+        f"{plural(len(report.file_results), 'file')}, {failed_files} failed; "
+        f"{plural(len(report.runs), 'tool run')}, {failed_runs} failed; "
+        f"{plural(len(report.diagnostics), 'finding')}, "
+        f"{plural(severities.get('error', 0), 'error')} and "
+        f"{plural(severities.get('warning', 0), 'warning')}; exit status {exit_code}"
+    )
+    return "\n".join(lines)
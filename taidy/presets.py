@@ -0,0 +1,64 @@
+"""Built-in config presets, selected with --preset or a config's "preset" key."""
+
+from typing import Any, Dict, List
+
+# Checks beyond each language's main linter and formatter: type checkers, slower
+# linters, secret scanning and manifest validation
+EXTRA_TOOLS = [
+    "pylint",
+    "flake8",
+    "tsc",
+    "tflint",
+    "actionlint",
+    "trufflehog",
+    "publint",
+    "validate-pyproject",
+    "cargo",
+    "go",
+    "editorconfig-checker",
+    "ec",
+]
+
+# Each preset is a config layered between the user-level config and the project's own,
+# so a project can still override any key it sets
+PRESETS: Dict[str, Dict[str, Any]] = {
+    "minimal": {
+        "description": "formatters and each language's main linter only",
+        "disable": EXTRA_TOOLS,
+    },
+    "standard": {
+        "description": "the default chains, as if no preset were given",
+    },
+    "strict": {
+        "description": "extra ruff rules, and warnings fail eslint and yamllint",
+        "args": {
+            "ruff check": ["--extend-select", "B,UP,SIM,C4,RET"],
+            "eslint": ["--max-warnings=0"],
+            "yamllint": ["--strict"],
+        },
+    },
+    "frontend": {
+        "description": "JavaScript, TypeScript, styles, markup and their config files",
+        "languages": ["javascript", "typescript", "css", "html", "markdown", "json", "yaml"],
+        "prefer": ["eslint", "prettier"],
+    },
+    "python-ds": {
+        "description": "Python and data files, tolerant of notebook-style scripts",
+        "languages": ["python", "markdown", "json", "yaml", "toml"],
+        # Imports after setup code and long lines are normal in exploratory scripts
+        "args": {"ruff check": ["--extend-ignore", "E402,E501"]},
+        "disable": ["pylint"],
+    },
+}
+
+
+def preset_names() -> List[str]:
+    """List the built-in presets' names"""
+    return list(PRESETS)
+
+
+def preset_config(name: str) -> Dict[str, Any]:
+    """Get a preset's config keys, without its description"""
+    if name not in PRESETS:
+        raise ValueError(f"Unknown preset: {name} (known presets: {', '.join(PRESETS)})")
+    return {key: value for key, value in PRESETS[name].items() if key != "description"}
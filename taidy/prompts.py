@@ -0,0 +1,136 @@
+"""Notice tools that stop to ask a question nobody is there to answer, such as a licence
+agreement or an activation prompt, and stop them rather than let the whole run hang.
+
+Tools run with their output captured, so a prompt is never seen. A tool is taken to be
+prompting once it has printed nothing for a while and the last thing it printed reads
+like a question.
+"""
+
+import os
+import re
+import subprocess
+import threading
+import time
+from typing import IO, Any, Dict, List, Optional, Set
+
+# Seconds a tool may sit silent after printing what looks like a prompt
+PROMPT_IDLE_SECONDS = 10.0
+
+# How often a running tool's output is looked at
+POLL_SECONDS = 0.2
+
+# The last line of a prompt: a question, a choice, or a request to accept or activate
+PROMPT_PATTERN = re.compile(
+    r"(\?|\[y/n\]|\(y\)|\(y/n\)|yes/no|press (enter|any key)|accept|agree"
+    r"|licen[cs]e key|activation code|activate)\W*$",
+    re.IGNORECASE,
+)
+
+# Tools running now, so they can be stopped when taidy itself is told to stop
+running: "Set[subprocess.Popen[Any]]" = set()
+running_lock = threading.Lock()
+stopping = threading.Event()
+
+
+def stop_running_tools() -> None:
+    """Kill every tool running now, and any started from now on"""
+    with running_lock:
+        stopping.set()
+        for process in running:
+            process.kill()
+
+
+class InteractivePrompt(Exception):
+    """A tool stopped to wait for an answer, and was killed"""
+
+    def __init__(self, prompt: str, stdout: bytes, stderr: bytes):
+        super().__init__(prompt)
+        self.prompt = prompt
+        self.stdout = stdout
+        self.stderr = stderr
+
+
+def prompt_line(output: bytes) -> Optional[str]:
+    """Get the last line of a tool's output if it reads like a prompt"""
+    lines = output.decode(errors="replace").strip().splitlines()
+    last = lines[-1].strip() if lines else ""
+    return last if PROMPT_PATTERN.search(last) else None
+
+
+def run_unattended(
+    command: List[str],
+    env: Optional[Dict[str, str]] = None,
+    stdin_closed: bool = False,
+    idle_seconds: float = PROMPT_IDLE_SECONDS,
+) -> "subprocess.CompletedProcess[bytes]":
+    """Run a command capturing its output, raising InteractivePrompt if it stops to ask
+    for input. stdin is either closed, so reads see end of input, or left open with
+    nothing ever written, so a prompt waits and can be noticed.
+    """
+    process = subprocess.Popen(
+        command,
+        stdin=subprocess.DEVNULL if stdin_closed else subprocess.PIPE,
+        stdout=subprocess.PIPE,
+        stderr=subprocess.PIPE,
+        env=env,
+    )
+    with running_lock:
+        running.add(process)
+        if stopping.is_set():
+            process.kill()
+    stdout: List[bytes] = []
+    stderr: List[bytes] = []
+    # Both streams' output in arrival order, for finding the prompt, and when it last came
+    combined: List[bytes] = []
+    last_output = [time.monotonic()]
+    lock = threading.Lock()
+
+    def collect(stream: IO[bytes], chunks: List[bytes]) -> None:
+        while True:
+            # Whatever is available, rather than waiting for a full buffer
+            chunk = os.read(stream.fileno(), 65536)
+            if not chunk:
+                return
+            with lock:
+                chunks.append(chunk)
+                combined.append(chunk)
+                last_output[0] = time.monotonic()
+
+    assert process.stdout is not None and process.stderr is not None
+    readers = [
+        threading.Thread(target=collect, args=(process.stdout, stdout), daemon=True),
+        threading.Thread(target=collect, args=(process.stderr, stderr), daemon=True),
+    ]
+    for reader in readers:
+        reader.start()
+
+    prompt = None
+    while True:
+        try:
+            process.wait(timeout=POLL_SECONDS)
+            break
+        except subprocess.TimeoutExpired:
+            pass
+        with lock:
+            idle = time.monotonic() - last_output[0]
+            tail = b"".join(combined[-8:])
+        if idle >= idle_seconds:
+            prompt = prompt_line(tail)
+            if prompt is not None:
+                process.kill()
+                break
+
+    process.wait()
+    with running_lock:
+        running.discard(process)
+    for reader in readers:
+        # Something the killed tool started may still hold its output open
+        reader.join(timeout=1.0 if prompt is not None or stopping.is_set() else None)
+    if process.stdin is not None:
+        process.stdin.close()
+
+    if prompt is not None:
+        raise InteractivePrompt(prompt, b"".join(stdout), b"".join(stderr))
+    return subprocess.CompletedProcess(
+        command, process.returncode, b"".join(stdout), b"".join(stderr)
+    )
@@ -0,0 +1,161 @@
+"""The JSON-RPC protocol spoken by the taidy daemon, for editor and IDE integrations.
+
+`taidy daemon` serves it on a Unix socket, .taidy/daemon.sock unless --socket is given.
+
+Messages are JSON-RPC 2.0 objects, one per line (newline-delimited JSON). Clients should
+call `capabilities` first and check `protocol_version`: the major version changes only when
+a method or field is removed or changes meaning, while new methods and optional fields
+bump the minor version.
+
+Methods:
+
+    capabilities  {}                       -> {protocol_version, taidy_version, methods,
+                                                extensions}
+    lint          {files, options?}        -> {exit_code, diagnostics, output}
+    format        {files, options?}        -> {exit_code, output}
+    shutdown      {}                       -> null, after which the daemon exits
+
+`files` are paths relative to the daemon's working directory (or absolute). `options`
+takes the same names as the command-line flags, with dashes as underscores, such as
+{"prefer_fast": true}. Each diagnostic has the fields of taidy.diagnostics.Diagnostic.
+
+Client speaks the protocol from the other end, as `taidy client` does:
+
+    taidy client lint '{"files": ["src/app.py"]}'
+"""
+
+import json
+from typing import IO, Any, Callable, Dict, List, Optional
+
+PROTOCOL_VERSION = "1.0"
+
+METHODS = ["capabilities", "lint", "format", "shutdown"]
+
+# Standard JSON-RPC 2.0 error codes
+PARSE_ERROR = -32700
+INVALID_REQUEST = -32600
+METHOD_NOT_FOUND = -32601
+INVALID_PARAMS = -32602
+INTERNAL_ERROR = -32603
+
+Handler = Callable[[Dict[str, Any]], Any]
+
+
+class ProtocolError(Exception):
+    """An error to report to the client as a JSON-RPC error response"""
+
+    def __init__(self, code: int, message: str):
+        super().__init__(message)
+        self.code = code
+        self.message = message
+
+
+def response(request_id: Any, result: Any) -> Dict[str, Any]:
+    """Build a successful JSON-RPC response"""
+    return {"jsonrpc": "2.0", "id": request_id, "result": result}
+
+
+def error_response(request_id: Any, code: int, message: str) -> Dict[str, Any]:
+    """Build a JSON-RPC error response"""
+    return {"jsonrpc": "2.0", "id": request_id, "error": {"code": code, "message": message}}
+
+
+def parse_request(line: str) -> Dict[str, Any]:
+    """Parse and validate one request line"""
+    try:
+        request = json.loads(line)
+    except ValueError as e:
+        raise ProtocolError(PARSE_ERROR, f"Invalid JSON: {e}")
+
+    if not isinstance(request, dict) or request.get("jsonrpc") != "2.0":
+        raise ProtocolError(INVALID_REQUEST, "Expected a JSON-RPC 2.0 request object")
+    if not isinstance(request.get("method"), str):
+        raise ProtocolError(INVALID_REQUEST, "Request has no method")
+    if not isinstance(request.get("params", {}), dict):
+        raise ProtocolError(INVALID_PARAMS, "params must be an object")
+    return request
+
+
+def request_files(params: Dict[str, Any]) -> List[str]:
+    """Read the `files` parameter of a lint or format request"""
+    files = params.get("files")
+    if not isinstance(files, list) or not files or not all(isinstance(f, str) for f in files):
+        raise ProtocolError(INVALID_PARAMS, "files must be a non-empty list of paths")
+    return files
+
+
+def handle_line(line: str, handlers: Dict[str, Handler]) -> Optional[Dict[str, Any]]:
+    """Handle one request line, returning the response (None for notifications)"""
+    request_id = None
+    try:
+        request = parse_request(line)
+        request_id = request.get("id")
+        handler = handlers.get(request["method"])
+        if handler is None:
+            raise ProtocolError(METHOD_NOT_FOUND, f"Unknown method: {request['method']}")
+        result = handler(request.get("params", {}))
+    except ProtocolError as e:
+        return error_response(request_id, e.code, e.message)
+    except Exception as e:
+        return error_response(request_id, INTERNAL_ERROR, str(e))
+
+    if "id" not in request:
+        return None
+    return response(request_id, result)
+
+
+def serve(reader: IO[str], writer: IO[str], handlers: Dict[str, Handler]) -> bool:
+    """Answer requests from reader until it closes or a shutdown request arrives.
+
+    Returns whether the client asked for shutdown, rather than just disconnecting.
+    """
+    stopping: List[bool] = []
+
+    def shutdown(params: Dict[str, Any]) -> None:
+        stopping.append(True)
+
+    handlers = dict(handlers, shutdown=shutdown)
+    for line in reader:
+        if not line.strip():
+            continue
+
+        reply = handle_line(line, handlers)
+        if reply is not None:
+            writer.write(json.dumps(reply) + "\n")
+            writer.flush()
+
+        if stopping:
+            break
+
+    return bool(stopping)
+
+
+class Client:
+    """Send requests over a connection to a server and wait for each response"""
+
+    def __init__(self, reader: IO[str], writer: IO[str]):
+        self.reader = reader
+        self.writer = writer
+        self.last_id = 0
+
+    def call(self, method: str, params: Optional[Dict[str, Any]] = None) -> Any:
+        """Make a request, returning its result, or raising ProtocolError if the server
+        answered with an error or closed the connection without answering"""
+        self.last_id += 1
+        request = {"jsonrpc": "2.0", "id": self.last_id, "method": method, "params": params or {}}
+        self.writer.write(json.dumps(request) + "\n")
+        self.writer.flush()
+
+        line = self.reader.readline()
+        if not line:
+            raise ProtocolError(INTERNAL_ERROR, "The server closed the connection")
+        try:
+            reply = json.loads(line)
+        except ValueError as e:
+            raise ProtocolError(PARSE_ERROR, f"Invalid JSON from the server: {e}")
+        if not isinstance(reply, dict) or reply.get("id") != self.last_id:
+            raise ProtocolError(INVALID_REQUEST, "The server's response doesn't match the request")
+        error = reply.get("error")
+        if isinstance(error, dict):
+            raise ProtocolError(int(error.get("code", INTERNAL_ERROR)), str(error.get("message")))
+        return reply.get("result")
@@ -0,0 +1,102 @@
+"""Collect a run's results as a machine-readable report, for --output json."""
+
+import json
+import os
+from dataclasses import asdict, dataclass, field
+from typing import Any, Dict, List, Optional, Tuple
+
+from .diagnostics import Diagnostic, printable_path
+
+# Bumped when a field is removed or changes meaning; new fields don't bump it
+REPORT_VERSION = 1
+
+
+@dataclass
+class ToolRun:
+    """One execution of a tool"""
+
+    tool: str
+    command: List[str]
+    exit_code: int
+    duration: float
+    stdout: str
+    stderr: str
+    # The first line of the tool's --version output, when it gives one
+    version: Optional[str] = None
+    # Where the tool was found, when it was found on the search path
+    path: Optional[str] = None
+
+
+@dataclass
+class Report:
+    """Everything a run did, gathered as it happens and written out at the end"""
+
+    # Whether tool output is kept off the terminal, as when the report itself is printed
+    quiet: bool = True
+    mode: str = ""
+    runs: List[ToolRun] = field(default_factory=list)
+    # (tool, exit code) of each run that covered a file, keyed by path
+    file_results: Dict[str, List[Tuple[str, int]]] = field(default_factory=dict)
+    diagnostics: List[Dict[str, Any]] = field(default_factory=list)
+    summary: Optional[Dict[str, Any]] = None
+    # Why nothing was processed, when nothing was
+    reason: Optional[str] = None
+    # Each file's content hash before the run, for --record and --replay
+    input_digests: Dict[str, Optional[str]] = field(default_factory=dict)
+
+    def add_files(self, files: List[str]) -> None:
+        """Register the files a run was asked to process"""
+        for file in files:
+            self.file_results.setdefault(printable_path(os.path.normpath(file)), [])
+
+    def record_result(self, tool: str, files: List[str], exit_code: int) -> None:
+        """Record a tool's exit code against each file it covered"""
+        for file in files:
+            path = printable_path(os.path.normpath(file))
+            self.file_results.setdefault(path, []).append((tool, exit_code))
+
+    def add_diagnostic(self, diagnostic: Diagnostic, owners: Optional[List[str]] = None) -> None:
+        """Record a finding, with its owners when grouping by owner"""
+        entry = asdict(diagnostic)
+        # Paths are keyed the way files are, so odd names match up and stay valid JSON text
+        entry["file"] = printable_path(diagnostic.file)
+        if owners is not None:
+            entry["owners"] = owners
+        self.diagnostics.append(entry)
+
+    def to_json(self, exit_code: int) -> str:
+        """Render the report as a JSON document"""
+        counts: Dict[str, int] = {}
+        for entry in self.diagnostics:
+            path = os.path.normpath(entry["file"])
+            counts[path] = counts.get(path, 0) + 1
+
+        files = []
+        for path, results in sorted(self.file_results.items()):
+            if not results:
+                status = "skipped"
+            elif any(code != 0 for _, code in results):
+                status = "failed"
+            else:
+                status = "ok"
+            files.append(
+                {
+                    "path": path,
+                    "status": status,
+                    "tools": sorted({tool for tool, _ in results}),
+                    "diagnostics": counts.get(path, 0),
+                }
+            )
+
+        document: Dict[str, Any] = {
+            "version": REPORT_VERSION,
+            "mode": self.mode,
+            "exit_code": exit_code,
+            "runs": [asdict(run) for run in self.runs],
+            "files": files,
+            "diagnostics": self.diagnostics,
+            "summary": self.summary,
+        }
+        if self.reason is not None:
+            document["reason"] = self.reason
+        return json.dumps(document, indent=2)
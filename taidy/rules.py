@@ -0,0 +1,174 @@
+"""Bundled index mapping rule codes back to their owning tool and documentation."""
+
+import re
+import webbrowser
+from dataclasses import dataclass
+from typing import Dict, List, Optional
+
+RUFF_RULES_URL = "https://docs.astral.sh/ruff/rules/"
+
+# Ruff documents rules by name rather than code, so common codes are bundled here
+RUFF_RULE_NAMES: Dict[str, str] = {
+    "E101": "mixed-spaces-and-tabs",
+    "E401": "multiple-imports-on-one-line",
+    "E402": "module-import-not-at-top-of-file",
+    "E501": "line-too-long",
+    "E701": "multiple-statements-on-one-line-colon",
+    "E711": "none-comparison",
+    "E712": "true-false-comparison",
+    "E713": "not-in-test",
+    "E721": "type-comparison",
+    "E722": "bare-except",
+    "E731": "lambda-assignment",
+    "E741": "ambiguous-variable-name",
+    "E902": "io-error",
+    "F401": "unused-import",
+    "F403": "undefined-local-with-import-star",
+    "F405": "undefined-local-with-import-star-usage",
+    "F541": "f-string-missing-placeholders",
+    "F811": "redefined-while-unused",
+    "F821": "undefined-name",
+    "F841": "unused-variable",
+    "W291": "trailing-whitespace",
+    "W292": "missing-newline-at-end-of-file",
+    "W293": "blank-line-with-whitespace",
+    "W605": "invalid-escape-sequence",
+    "I001": "unsorted-imports",
+    "B006": "mutable-argument-default",
+    "B008": "function-call-in-default-argument",
+    "UP035": "deprecated-import",
+}
+
+# Pylint documents messages by category and symbolic name
+PYLINT_MESSAGES: Dict[str, str] = {
+    "C0114": "convention/missing-module-docstring",
+    "C0115": "convention/missing-class-docstring",
+    "C0116": "convention/missing-function-docstring",
+    "C0301": "convention/line-too-long",
+    "C0103": "convention/invalid-name",
+    "W0611": "warning/unused-import",
+    "W0612": "warning/unused-variable",
+    "W0613": "warning/unused-argument",
+    "E0401": "error/import-error",
+    "E1101": "error/no-member",
+    "R0913": "refactor/too-many-arguments",
+}
+
+YAMLLINT_RULES = {
+    "anchors",
+    "braces",
+    "brackets",
+    "colons",
+    "commas",
+    "comments",
+    "comments-indentation",
+    "document-end",
+    "document-start",
+    "empty-lines",
+    "empty-values",
+    "float-values",
+    "hyphens",
+    "indentation",
+    "key-duplicates",
+    "key-ordering",
+    "line-length",
+    "new-line-at-end-of-file",
+    "new-lines",
+    "octal-values",
+    "quoted-strings",
+    "trailing-spaces",
+    "truthy",
+}
+
+
+@dataclass
+class RuleDoc:
+    """Documentation for a rule, as known to the bundled index"""
+
+    rule: str
+    tool: str
+    url: str
+    name: Optional[str] = None
+
+
+def lookup_rule(rule: str) -> List[RuleDoc]:
+    """Find every tool that owns a rule code, most likely first"""
+    matches = []
+
+    if re.fullmatch(r"SC\d{4}", rule):
+        matches.append(RuleDoc(rule, "shellcheck", f"https://www.shellcheck.net/wiki/{rule}"))
+
+    if re.fullmatch(r"DL\d{4}", rule):
+        matches.append(
+            RuleDoc(rule, "hadolint", f"https://github.com/hadolint/hadolint/wiki/{rule}")
+        )
+
+    if re.fullmatch(r"[CRWEF]\d{4}", rule):
+        message = PYLINT_MESSAGES.get(rule)
+        overview = "https://pylint.readthedocs.io/en/stable/user_guide/messages/"
+        if message:
+            url = f"{overview}{message}.html"
+            matches.append(RuleDoc(rule, "pylint", url, message.split("/")[1]))
+        else:
+            matches.append(RuleDoc(rule, "pylint", f"{overview}messages_overview.html"))
+
+    # Ruff codes, excluding the tool-specific prefixes and pylint's letter-plus-four-digits style
+    if (
+        re.fullmatch(r"[A-Z]{1,4}\d{3,4}", rule)
+        and not rule.startswith(("SC", "DL"))
+        and not re.fullmatch(r"[CRWEF]\d{4}", rule)
+    ):
+        name = RUFF_RULE_NAMES.get(rule)
+        url = f"{RUFF_RULES_URL}{name}/" if name else RUFF_RULES_URL
+        matches.append(RuleDoc(rule, "ruff", url, name))
+
+    if re.fullmatch(r"[A-Z][A-Za-z]+/[A-Z][A-Za-z]+", rule):
+        department, cop = rule.split("/")
+        anchor = f"{department}{cop}".lower()
+        url = f"https://docs.rubocop.org/rubocop/cops_{department.lower()}.html#{anchor}"
+        matches.append(RuleDoc(rule, "rubocop", url))
+
+    if re.fullmatch(r"terraform_[a-z_]+", rule):
+        url = (
+            "https://github.com/terraform-linters/tflint-ruleset-terraform"
+            f"/blob/main/docs/rules/{rule}.md"
+        )
+        matches.append(RuleDoc(rule, "tflint", url))
+
+    if rule in YAMLLINT_RULES:
+        module = rule.replace("-", "_")
+        url = f"https://yamllint.readthedocs.io/en/stable/rules.html#module-yamllint.rules.{module}"
+        matches.append(RuleDoc(rule, "yamllint", url))
+
+    elif re.fullmatch(r"[a-z]+(-[a-z]+)*", rule):
+        matches.append(RuleDoc(rule, "eslint", f"https://eslint.org/docs/latest/rules/{rule}"))
+
+    return matches
+
+
+def explain_rule(args: List[str]) -> int:
+    """Print (or open with --open) the documentation for rule codes"""
+    open_docs = "--open" in args
+    rules = [arg for arg in args if arg != "--open"]
+
+    if not rules:
+        print("Usage: taidy explain-rule [--open] <rule>...")
+        return 1
+
+    exit_code = 0
+    for rule in rules:
+        docs = lookup_rule(rule)
+        if not docs:
+            print(f"Unknown rule: {rule}")
+            exit_code = 1
+            continue
+
+        for doc in docs:
+            name = f": {doc.name}" if doc.name else ""
+            print(f"{doc.rule} ({doc.tool}){name}")
+            print(f"  {doc.url}")
+
+        if open_docs:
+            webbrowser.open(docs[0].url)
+
+    return exit_code
@@ -0,0 +1,123 @@
+"""Record what a run did in a lock file, and check a later run against it.
+
+`--record run.lock` writes the exact tools a run used (their paths, versions and
+arguments) and a hash of every file before and after the run. `--replay run.lock`
+makes the same kind of record for the current run and warns about every difference, so
+"the formatter changed my file differently than yesterday" comes down to which tool,
+version or input changed.
+"""
+
+import json
+import os
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Tuple
+
+from .diagnostics import printable_path
+from .report import Report
+
+# Bumped when a field is removed or changes meaning; new fields don't bump it
+LOCK_VERSION = 1
+
+
+def lock_tools(report: Report) -> List[Dict[str, Any]]:
+    """Merge a run's tool runs into one entry per tool and arguments, with every file
+    the tool was given; files are left out of the arguments, which then match between
+    runs over different files"""
+    files = {printable_path(os.path.normpath(path)) for path in report.input_digests}
+    entries: Dict[Tuple[str, Tuple[str, ...]], Dict[str, Any]] = {}
+    for run in report.runs:
+        arguments = tuple(arg for arg in run.command[1:] if os.path.normpath(arg) not in files)
+        given = [os.path.normpath(arg) for arg in run.command[1:] if os.path.normpath(arg) in files]
+        entry = entries.setdefault(
+            (run.tool, arguments),
+            {
+                "tool": run.tool,
+                "path": run.path,
+                "version": run.version,
+                "arguments": list(arguments),
+                "files": [],
+            },
+        )
+        entry["files"] = sorted(set(entry["files"]) | set(given))
+    return [entries[key] for key in sorted(entries)]
+
+
+def build_lock(
+    report: Report, output_digests: Dict[str, Optional[str]], taidy_version: str
+) -> Dict[str, Any]:
+    """Describe a finished run: its tools, and each file's hash before and after"""
+    files = {}
+    for path, digest in sorted(report.input_digests.items()):
+        files[printable_path(os.path.normpath(path))] = {
+            "input": digest,
+            "output": output_digests.get(path),
+        }
+    return {
+        "version": LOCK_VERSION,
+        "taidy_version": taidy_version,
+        "mode": report.mode,
+        "tools": lock_tools(report),
+        "files": files,
+    }
+
+
+def write_lock(path: str, lock: Dict[str, Any]) -> None:
+    """Write a lock file, creating its directory if need be"""
+    Path(path).parent.mkdir(parents=True, exist_ok=True)
+    Path(path).write_text(json.dumps(lock, indent=2) + "\n")
+
+
+def read_lock(path: str) -> Dict[str, Any]:
+    """Read a lock file, raising ValueError if it can't be read or isn't one"""
+    try:
+        lock = json.loads(Path(path).read_text())
+    except OSError as e:
+        raise ValueError(f"Couldn't read {path}: {e.strerror}") from None
+    except ValueError:
+        raise ValueError(f"{path} isn't JSON") from None
+    if not isinstance(lock, dict) or not isinstance(lock.get("tools"), list):
+        raise ValueError(f"{path} isn't a taidy run lock")
+    if lock.get("version") != LOCK_VERSION:
+        raise ValueError(f"{path} is a version {lock.get('version')} lock; expected {LOCK_VERSION}")
+    return lock
+
+
+def lock_differences(recorded: Dict[str, Any], current: Dict[str, Any]) -> List[str]:
+    """Describe how the current run differs from the recorded one"""
+    differences = []
+    if recorded.get("taidy_version") != current.get("taidy_version"):
+        differences.append(
+            f"taidy is {current.get('taidy_version')}, was {recorded.get('taidy_version')}"
+        )
+    if recorded.get("mode") != current.get("mode"):
+        differences.append(f"mode is {current.get('mode')}, was {recorded.get('mode')}")
+
+    def by_command(lock: Dict[str, Any]) -> Dict[str, Dict[str, Any]]:
+        return {" ".join([t["tool"]] + t["arguments"]): t for t in lock.get("tools", [])}
+
+    before, after = by_command(recorded), by_command(current)
+    for command in sorted(set(before) | set(after)):
+        if command not in after:
+            differences.append(f"{command} no longer ran")
+        elif command not in before:
+            differences.append(f"{command} ran, and didn't before")
+        else:
+            for field in ["path", "version"]:
+                if before[command].get(field) != after[command].get(field):
+                    differences.append(
+                        f"{command}: {field} is {after[command].get(field)}, "
+                        f"was {before[command].get(field)}"
+                    )
+
+    recorded_files = recorded.get("files", {})
+    current_files = current.get("files", {})
+    for path in sorted(set(recorded_files) | set(current_files)):
+        if path not in current_files:
+            differences.append(f"{path} wasn't processed this time")
+        elif path not in recorded_files:
+            differences.append(f"{path} wasn't processed before")
+        elif recorded_files[path].get("input") != current_files[path].get("input"):
+            differences.append(f"{path} has changed since it was recorded")
+        elif recorded_files[path].get("output") != current_files[path].get("output"):
+            differences.append(f"{path} came out differently from the same input")
+    return differences
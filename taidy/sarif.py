@@ -0,0 +1,78 @@
+"""Convert a run's report into SARIF 2.1.0, for GitHub Code Scanning and similar services."""
+
+import json
+import os
+from pathlib import Path
+from typing import Any, Dict, List
+
+from .report import Report
+from .rules import lookup_rule
+
+SARIF_SCHEMA = "https://json.schemastore.org/sarif-2.1.0.json"
+
+# SARIF result levels for taidy's severities
+SARIF_LEVELS = {"error": "error", "warning": "warning", "info": "note"}
+
+
+def rule_descriptor(rule: str, tool: str) -> Dict[str, Any]:
+    """Describe a rule, linking its documentation when the tool's rule index knows it"""
+    descriptor: Dict[str, Any] = {"id": rule}
+    docs = [doc for doc in lookup_rule(rule) if doc.tool == tool]
+    if docs:
+        descriptor["helpUri"] = docs[0].url
+        if docs[0].name:
+            descriptor["name"] = docs[0].name
+    return descriptor
+
+
+def sarif_result(diagnostic: Dict[str, Any]) -> Dict[str, Any]:
+    """Convert one diagnostic into a SARIF result"""
+    region: Dict[str, Any] = {"startLine": diagnostic["line"]}
+    if diagnostic["column"] is not None:
+        region["startColumn"] = diagnostic["column"]
+    if diagnostic["end_column"] is not None:
+        region["endColumn"] = diagnostic["end_column"]
+
+    # Paths are relative to the directory taidy ran in, normally the checkout root
+    uri = Path(os.path.relpath(os.path.abspath(diagnostic["file"]))).as_posix()
+    result: Dict[str, Any] = {
+        "level": SARIF_LEVELS.get(diagnostic["severity"], "error"),
+        "message": {"text": diagnostic["message"]},
+        "locations": [
+            {
+                "physicalLocation": {
+                    "artifactLocation": {"uri": uri, "uriBaseId": "%SRCROOT%"},
+                    "region": region,
+                }
+            }
+        ],
+    }
+    if diagnostic["rule"]:
+        result["ruleId"] = diagnostic["rule"]
+    return result
+
+
+def to_sarif(report: Report) -> str:
+    """Render a report's diagnostics as a SARIF log, with one run per tool"""
+    tools: List[str] = []
+    for name in [run.tool for run in report.runs] + [d["tool"] for d in report.diagnostics]:
+        if name not in tools:
+            tools.append(name)
+
+    runs = []
+    for tool in tools:
+        diagnostics = [d for d in report.diagnostics if d["tool"] == tool]
+        rules = sorted({d["rule"] for d in diagnostics if d["rule"]})
+        runs.append(
+            {
+                "tool": {
+                    "driver": {
+                        "name": tool,
+                        "rules": [rule_descriptor(rule, tool) for rule in rules],
+                    }
+                },
+                "results": [sarif_result(d) for d in diagnostics],
+            }
+        )
+
+    return json.dumps({"$schema": SARIF_SCHEMA, "version": "2.1.0", "runs": runs}, indent=2)
@@ -0,0 +1,138 @@
+"""Write CI workflows and hook config that run taidy on a project, for `taidy init`:
+
+    taidy init --template github-actions   # .github/workflows/taidy.yml
+    taidy init --template gitlab           # .gitlab-ci.yml
+    taidy init --template pre-commit       # .pre-commit-config.yaml
+
+The caller works out what goes in them from the project: its languages, the taidy image
+that has their tools, and the files its chains cover. The CI templates lint only the
+changed files of merge requests but everything on the default branch, and keep a report
+the platform shows.
+"""
+
+import json
+from typing import Callable, Dict, List, NamedTuple
+
+
+class Project(NamedTuple):
+    """What a template needs to know about the project"""
+
+    # Language names, as in taidy's language registry
+    languages: List[str]
+    # The taidy image with the languages' tools, such as taidy/python
+    image: str
+    # A regex matching the files taidy's chains cover, for hook managers' `files`
+    files_pattern: str
+
+
+def header(name: str, project: Project) -> List[str]:
+    """Comment lines saying where a template came from and what it covers"""
+    languages = ", ".join(project.languages) or "no languages found yet"
+    return [
+        f"# Generated by `taidy init --template {name}` for this project's languages:",
+        f"# {languages}. Run it again after adding a language.",
+    ]
+
+
+def github_actions(project: Project) -> str:
+    """A workflow linting pull requests' changes and the whole of each push, with findings
+    uploaded as SARIF for code scanning"""
+    lines = header("github-actions", project) + [
+        "name: taidy",
+        "",
+        "on:",
+        "  push:",
+        "  pull_request:",
+        "",
+        "permissions:",
+        "  contents: read",
+        "  security-events: write",
+        "",
+        "jobs:",
+        "  lint:",
+        "    runs-on: ubuntu-latest",
+        f"    container: {project.image}",
+        "    steps:",
+        "      - uses: actions/checkout@v4",
+        "        with:",
+        "          # --since needs the base branch's history",
+        "          fetch-depth: 0",
+        "      - name: Trust the checkout",
+        '        run: git config --global --add safe.directory "$GITHUB_WORKSPACE"',
+        "      - name: Lint changed files",
+        "        if: github.event_name == 'pull_request'",
+        '        run: taidy lint --since "origin/$GITHUB_BASE_REF" --report sarif=taidy.sarif',
+        "      - name: Lint everything",
+        "        if: github.event_name != 'pull_request'",
+        "        run: taidy lint --report sarif=taidy.sarif .",
+        "      - name: Upload findings",
+        "        if: always() && hashFiles('taidy.sarif') != ''",
+        "        uses: github/codeql-action/upload-sarif@v3",
+        "        with:",
+        "          sarif_file: taidy.sarif",
+    ]
+    return "\n".join(lines) + "\n"
+
+
+def gitlab(project: Project) -> str:
+    """A job linting merge requests' changes and the whole default branch, with findings
+    kept as a JUnit report for the merge request widget"""
+    lines = header("gitlab", project) + [
+        "taidy:",
+        "  stage: test",
+        "  image:",
+        f"    name: {project.image}",
+        '    entrypoint: [""]',
+        "  variables:",
+        "    # --since needs the target branch's history",
+        "    GIT_DEPTH: 0",
+        "  script:",
+        "    - |",
+        '      if [ -n "$CI_MERGE_REQUEST_TARGET_BRANCH_NAME" ]; then',
+        '        git fetch origin "$CI_MERGE_REQUEST_TARGET_BRANCH_NAME"',
+        '        taidy lint --since "origin/$CI_MERGE_REQUEST_TARGET_BRANCH_NAME" \\',
+        "          --report junit=taidy-junit.xml",
+        "      else",
+        "        taidy lint --report junit=taidy-junit.xml .",
+        "      fi",
+        "  artifacts:",
+        "    when: always",
+        "    reports:",
+        "      junit: taidy-junit.xml",
+        "  rules:",
+        '    - if: $CI_PIPELINE_SOURCE == "merge_request_event"',
+        "    - if: $CI_COMMIT_BRANCH == $CI_DEFAULT_BRANCH",
+    ]
+    return "\n".join(lines) + "\n"
+
+
+def pre_commit(project: Project) -> str:
+    """A local hook running taidy on the staged files its chains cover"""
+    lines = header("pre-commit", project) + [
+        "repos:",
+        "  - repo: local",
+        "    hooks:",
+        "      - id: taidy",
+        "        name: taidy",
+        "        entry: taidy",
+        "        language: system",
+        f"        files: {json.dumps(project.files_pattern)}",
+        "        # taidy runs its tools in parallel itself",
+        "        require_serial: true",
+    ]
+    return "\n".join(lines) + "\n"
+
+
+# Each template, and where in the project it's written
+TEMPLATES: Dict[str, Callable[[Project], str]] = {
+    "github-actions": github_actions,
+    "gitlab": gitlab,
+    "pre-commit": pre_commit,
+}
+TARGETS: Dict[str, str] = {
+    "github-actions": ".github/workflows/taidy.yml",
+    "gitlab": ".gitlab-ci.yml",
+    "pre-commit": ".pre-commit-config.yaml",
+}
+
+USAGE = f"Usage: taidy init --template {'|'.join(TEMPLATES)} [--force]"
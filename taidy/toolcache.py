@@ -0,0 +1,90 @@
+"""Remember where tools are installed and which versions they are, across runs.
+
+Finding a tool through the login shell, or asking it for its version, costs a subprocess,
+which editors, the daemon and git hooks would otherwise pay on every run. Entries are
+keyed by the tool's path and hold the binary's modification time and size, so upgrading
+or reinstalling a tool invalidates them.
+
+The cache is one JSON file, shared by every project. Nothing locks it: each write
+replaces the whole file atomically, so concurrent runs at worst lose each other's
+additions, which are probed again next time.
+"""
+
+import json
+import os
+import tempfile
+from pathlib import Path
+from typing import Any, Dict, Optional
+
+
+def tool_cache_path() -> Path:
+    """Get the cache file, under $XDG_CACHE_HOME or ~/.cache"""
+    root = os.environ.get("XDG_CACHE_HOME") or os.path.join(os.path.expanduser("~"), ".cache")
+    return Path(root) / "taidy" / "tools.json"
+
+
+def binary_signature(path: str) -> Optional[Dict[str, int]]:
+    """Get what identifies a build of a tool, or None if it's gone"""
+    try:
+        stat = os.stat(path)
+    except OSError:
+        return None
+    return {"mtime_ns": stat.st_mtime_ns, "size": stat.st_size}
+
+
+class ToolCache:
+    """Tool paths and versions read from the cache file, and written back on save"""
+
+    def __init__(self, path: Path):
+        self.path = path
+        self.entries: Dict[str, Dict[str, Any]] = {}
+        self.changed = False
+        try:
+            with open(path, encoding="utf-8") as f:
+                data = json.load(f)
+        except (OSError, ValueError):
+            return
+        if isinstance(data, dict) and isinstance(data.get("tools"), dict):
+            self.entries = data["tools"]
+
+    def lookup(self, key: str) -> Optional[Dict[str, Any]]:
+        """Get what's remembered under key, if the binary it describes hasn't changed since"""
+        entry = self.entries.get(key)
+        if not isinstance(entry, dict) or not isinstance(entry.get("path"), str):
+            return None
+        if entry.get("binary") != binary_signature(entry["path"]):
+            return None
+        return entry
+
+    def remember(self, key: str, path: str, **fields: str) -> None:
+        """Record facts about the tool at path, dropping what was known of an older binary"""
+        signature = binary_signature(path)
+        if signature is None:
+            return
+        entry = self.lookup(key)
+        if entry is None or entry["path"] != path:
+            entry = {}
+        entry.update(fields, path=path, binary=signature)
+        self.entries[key] = entry
+        self.changed = True
+
+    def save(self) -> None:
+        """Write the cache if anything was added, merging entries other runs wrote meanwhile"""
+        if not self.changed:
+            return
+        latest = ToolCache(self.path)
+        latest.entries.update(self.entries)
+        try:
+            self.path.parent.mkdir(parents=True, exist_ok=True)
+            fd, temporary = tempfile.mkstemp(dir=str(self.path.parent), suffix=".tmp")
+            try:
+                with os.fdopen(fd, "w", encoding="utf-8") as f:
+                    json.dump({"tools": latest.entries}, f, indent=2, sort_keys=True)
+                os.replace(temporary, self.path)
+            except BaseException:
+                os.unlink(temporary)
+                raise
+        except OSError:
+            # A cache that can't be written only means probing again next run
+            return
+        self.changed = False
@@ -0,0 +1,191 @@
+"""Find staged and changed files through git, Mercurial or Jujutsu."""
+
+import os
+import re
+import subprocess
+from pathlib import Path
+from typing import Dict, List, Optional, Set, Tuple
+
+# A -U0 hunk header, giving the first line of the hunk in the new file
+HUNK_PATTERN = re.compile(r"^@@ -\d+(?:,\d+)? \+(?P<line>\d+)(?:,\d+)? @@")
+
+# Lines shorter than this, once stripped, such as braces and `else:`, turn up everywhere,
+# so they are never taken to have been moved
+MIN_MOVED_LINE_LENGTH = 6
+
+
+class VcsBackend:
+    """A version control system's view of which files are about to be committed or changed.
+
+    File lists are paths relative to the current directory, limited to files that exist,
+    or None when the VCS command fails.
+    """
+
+    command = ""
+    # Whether commits come from a staging area rather than the working copy, as in git
+    has_staging_area = False
+
+    def __init__(self, root: Path):
+        self.root = root
+
+    def run(self, args: List[str]) -> Optional[str]:
+        """Run the VCS from the repository root, returning its output if it succeeds"""
+        try:
+            result = subprocess.run(
+                [self.command] + args,
+                cwd=self.root,
+                capture_output=True,
+                text=True,
+                # Keep file names that aren't valid UTF-8 as they are on disk
+                errors="surrogateescape",
+                timeout=30,
+            )
+        except (OSError, subprocess.SubprocessError):
+            return None
+        return result.stdout if result.returncode == 0 else None
+
+    def to_files(self, output: Optional[str], separator: str) -> Optional[List[str]]:
+        """Turn root-relative names from VCS output into existing files relative to the cwd"""
+        if output is None:
+            return None
+        return [
+            os.path.relpath(self.root / name)
+            for name in output.split(separator)
+            if name and (self.root / name).is_file()
+        ]
+
+    def staged_files(self) -> Optional[List[str]]:
+        """List the files the next commit would include"""
+        raise NotImplementedError
+
+    def changed_since(self, ref: str) -> Optional[List[str]]:
+        """List the files changed since the working copy diverged from a revision"""
+        raise NotImplementedError
+
+    def unstaged_files(self) -> List[str]:
+        """List files with changes the next commit wouldn't include"""
+        return []
+
+    def stage(self, files: List[str]) -> bool:
+        """Add files' current contents to the next commit"""
+        return True
+
+    def moved_lines(self, since: Optional[str] = None) -> Optional[Dict[str, Set[int]]]:
+        """Find the lines of the working copy that were moved rather than written since a
+        revision (or since the last commit), by file relative to the cwd"""
+        return None
+
+
+class GitBackend(VcsBackend):
+    command = "git"
+    has_staging_area = True
+
+    def diff(self, args: List[str]) -> Optional[List[str]]:
+        """List the files added, copied, modified or renamed in a git diff"""
+        output = self.run(["diff", "--name-only", "-z", "--diff-filter=ACMR"] + args)
+        return self.to_files(output, "\0")
+
+    def staged_files(self) -> Optional[List[str]]:
+        return self.diff(["--cached"])
+
+    def changed_since(self, ref: str) -> Optional[List[str]]:
+        return self.diff([f"{ref}...HEAD"])
+
+    def unstaged_files(self) -> List[str]:
+        return self.diff([]) or []
+
+    def stage(self, files: List[str]) -> bool:
+        absolute = [os.path.abspath(file) for file in files]
+        return self.run(["add", "--"] + absolute) is not None
+
+    def moved_lines(self, since: Optional[str] = None) -> Optional[Dict[str, Set[int]]]:
+        base = "HEAD"
+        if since is not None:
+            # Compare with where the branch left the ref, as --since does
+            merge_base = self.run(["merge-base", since, "HEAD"])
+            if merge_base is None:
+                return None
+            base = merge_base.strip()
+        output = self.run(
+            ["-c", "core.quotePath=false", "diff", "-U0", "--no-color", "--no-ext-diff", base]
+        )
+        if output is None:
+            return None
+        return {
+            os.path.normpath(os.path.relpath(self.root / name)): lines
+            for name, lines in moved_lines_from_diff(output).items()
+        }
+
+
+class MercurialBackend(VcsBackend):
+    command = "hg"
+
+    def staged_files(self) -> Optional[List[str]]:
+        # Mercurial commits every modified and added file in the working directory
+        return self.to_files(self.run(["status", "-ma", "--no-status", "--print0"]), "\0")
+
+    def changed_since(self, ref: str) -> Optional[List[str]]:
+        revision = f"ancestor({ref}, .)"
+        output = self.run(["status", "-ma", "--no-status", "--print0", "--rev", revision])
+        return self.to_files(output, "\0")
+
+
+class JujutsuBackend(VcsBackend):
+    command = "jj"
+
+    def staged_files(self) -> Optional[List[str]]:
+        # The working copy is itself a commit, so its changes are what gets committed
+        return self.to_files(self.run(["diff", "--name-only"]), "\n")
+
+    def changed_since(self, ref: str) -> Optional[List[str]]:
+        revision = f"heads(::({ref}) & ::@)"
+        return self.to_files(self.run(["diff", "--name-only", "--from", revision]), "\n")
+
+
+def moved_lines_from_diff(diff: str) -> Dict[str, Set[int]]:
+    """Find the lines a -U0 diff adds whose content it also removes somewhere, from any
+    file and at any indentation, by file name as the diff gives it"""
+    removed: Set[str] = set()
+    added: List[Tuple[str, int, str]] = []
+    name: Optional[str] = None
+    line = 0
+    in_hunk = False
+    for text in diff.splitlines():
+        if text.startswith("diff "):
+            name, in_hunk = None, False
+        elif not in_hunk and text.startswith("+++ "):
+            # Deleted files are compared with /dev/null, and add no lines
+            name = text[len("+++ b/") :] if text.startswith("+++ b/") else None
+        elif text.startswith("@@"):
+            match = HUNK_PATTERN.match(text)
+            in_hunk = match is not None
+            line = int(match.group("line")) if match is not None else 0
+        elif in_hunk and text.startswith("-"):
+            removed.add(text[1:].strip())
+        elif in_hunk and text.startswith("+"):
+            if name is not None:
+                added.append((name, line, text[1:].strip()))
+            line += 1
+
+    moved: Dict[str, Set[int]] = {}
+    for name, number, content in added:
+        if len(content) >= MIN_MOVED_LINE_LENGTH and content in removed:
+            moved.setdefault(name, set()).add(number)
+    return moved
+
+
+def detect_vcs(start: Path, git_root: Optional[Path] = None) -> Optional[VcsBackend]:
+    """Find the repository containing a directory, preferring jj in colocated git repos.
+
+    git_root is the root of the git working tree containing it, as git itself finds it,
+    which is right for repositories set up with GIT_DIR, linked worktrees and submodules.
+    """
+    for directory in [start.resolve()] + list(start.resolve().parents):
+        if (directory / ".jj").is_dir():
+            return JujutsuBackend(directory)
+        if (directory / ".hg").is_dir():
+            return MercurialBackend(directory)
+        if directory == git_root:
+            return GitBackend(directory)
+    # With GIT_DIR and GIT_WORK_TREE, the working tree needn't contain the directory
+    return GitBackend(git_root) if git_root is not None else None
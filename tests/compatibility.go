@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// compatEnvironmentPrefix marks environments holding a single tool at a chosen version,
+// named like "compat/ruff@0.1.2"
+const compatEnvironmentPrefix = "compat/"
+
+// compatTool says how the compatibility matrix installs a tool at a given version
+type compatTool struct {
+	// oldest is the earliest version taidy's invocation templates are known to work with
+	oldest string
+	// latest is the version that installs the newest release
+	latest string
+	// install returns Dockerfile lines installing the version, on top of a base with python3
+	install func(version string) string
+}
+
+// compatTools are the tools the compatibility matrix covers
+var compatTools = map[string]compatTool{
+	// ruff format first appeared in 0.1.2
+	"ruff": {oldest: "0.1.2", latest: "", install: pipInstall("ruff")},
+	// black's first stable release, and the first with --stdin-filename as taidy uses it
+	"black": {oldest: "22.1.0", latest: "", install: pipInstall("black")},
+	"prettier": {oldest: "2.8.8", latest: "latest", install: func(version string) string {
+		return fmt.Sprintf(`RUN apt-get update && apt-get install -y nodejs npm
+RUN npm install -g prettier@%s`, version)
+	}},
+	// shfmt's -filename, used for --stdin, first appeared in 3.2.0
+	"shfmt":      {oldest: "v3.2.0", latest: "latest", install: imageBinary("mvdan/shfmt", "shfmt")},
+	"shellcheck": {oldest: "v0.7.0", latest: "latest", install: imageBinary("koalaman/shellcheck", "shellcheck")},
+}
+
+// pipInstall installs a Python package, pinned unless the version is empty
+func pipInstall(name string) func(string) string {
+	return func(version string) string {
+		if version == "" {
+			return fmt.Sprintf("RUN pip install %s", name)
+		}
+		return fmt.Sprintf("RUN pip install %s==%s", name, version)
+	}
+}
+
+// imageBinary copies a tool's binary out of its upstream image tagged with the version
+func imageBinary(image, binary string) func(string) string {
+	return func(version string) string {
+		return fmt.Sprintf("COPY --from=%s:%s /bin/%s /usr/local/bin/%s", image, version, binary, binary)
+	}
+}
+
+// compatEnvironment names the environment for a tool at "oldest", "latest" or a version
+func compatEnvironment(tool, version string) (string, error) {
+	spec, ok := compatTools[tool]
+	if !ok {
+		return "", fmt.Errorf("the compatibility matrix doesn't cover %s", tool)
+	}
+	switch version {
+	case "oldest":
+		version = spec.oldest
+	case "latest":
+		version = spec.latest
+	}
+	return fmt.Sprintf("%s%s@%s", compatEnvironmentPrefix, tool, version), nil
+}
+
+// compatDockerfile builds the Dockerfile for a compatibility environment
+func compatDockerfile(environment string) (string, error) {
+	tool, version, _ := strings.Cut(strings.TrimPrefix(environment, compatEnvironmentPrefix), "@")
+	spec, ok := compatTools[tool]
+	if !ok {
+		return "", fmt.Errorf("unknown environment: %s", environment)
+	}
+	return fmt.Sprintf(`FROM python:3.11-slim
+%s
+COPY taidy /app/taidy
+ENV PYTHONPATH=/app
+WORKDIR /tmp`, spec.install(version)), nil
+}
+
+// argumentErrors are what tools print when given a flag or subcommand they don't know
+var argumentErrors = []string{
+	"unexpected argument",
+	"unrecognized arguments",
+	"unrecognized subcommand",
+	"No such option",
+	"Unknown option",
+	"unknown flag",
+	"invalid option",
+	"flag provided but not defined",
+}
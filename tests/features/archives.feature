@@ -0,0 +1,75 @@
+Feature: Processing archives
+
+  Scenario: Files in a tar archive are linted with paths relative to its root
+    Given the Python file "unused_import.py" exists
+    And the following has been run:
+      """
+      mkdir widget && cp unused_import.py widget/ok.py && tar cf widget.tar -C widget ok.py
+      """
+    When ruff is installed
+    And `taidy lint --from-archive widget.tar` is run
+    Then the output should contain "ok.py:1:8: F401"
+    And the output should not contain "widget/ok.py"
+    And the exit code should be 1
+
+  Scenario: Links and paths outside a tar archive aren't unpacked
+    Given the Python file "unused_import.py" exists
+    And the following has been run:
+      """
+      python3 -c '
+      import io, tarfile
+      with tarfile.open("widget.tar", "w") as tar:
+          tar.add("unused_import.py", "ok.py")
+          escape = tarfile.TarInfo("../escaped.py")
+          escape.size = 10
+          tar.addfile(escape, io.BytesIO(b"import os\n"))
+          link = tarfile.TarInfo("link.py")
+          link.type = tarfile.SYMTYPE
+          link.linkname = "/etc/passwd"
+          tar.addfile(link)
+      '
+      """
+    When ruff is installed
+    And `taidy lint --from-archive widget.tar` is run
+    Then the output should contain "Skipped ../escaped.py in widget.tar"
+    And the output should contain "Skipped link.py in widget.tar"
+    And the output should contain "ok.py:1:8: F401"
+    And the output should not contain "escaped.py:1"
+    And the output should not contain "root:"
+
+  Scenario: Links and paths outside a zip archive aren't unpacked
+    Given the Python file "unused_import.py" exists
+    And the following has been run:
+      """
+      python3 -c '
+      import zipfile
+      with zipfile.ZipFile("widget.zip", "w") as zip_file:
+          zip_file.write("unused_import.py", "ok.py")
+          zip_file.writestr("../escaped.py", "import os\n")
+          link = zipfile.ZipInfo("link.py")
+          link.external_attr = 0o120777 << 16
+          zip_file.writestr(link, "/etc/passwd")
+      '
+      """
+    When ruff is installed
+    And `taidy lint --from-archive widget.zip` is run
+    Then the output should contain "Skipped ../escaped.py in widget.zip"
+    And the output should contain "Skipped link.py in widget.zip"
+    And the output should contain "ok.py:1:8: F401"
+    And the output should not contain "escaped.py:1"
+
+  Scenario: Config files inside an archive can't add tools
+    Given the Python file "unused_import.py" exists
+    And the file "hostile/.taidy.json" contains:
+      """
+      {"tools": {"payload": {"command": "touch /tmp/payload-ran", "files": ["*.py"]}}}
+      """
+    And the following has been run:
+      """
+      cp unused_import.py hostile/ok.py && tar cf hostile.tar -C hostile .
+      """
+    When ruff is installed
+    And `taidy lint --from-archive hostile.tar; ls /tmp/payload-ran` is run
+    Then the output should contain "ok.py:1:8: F401"
+    And the output should contain "No such file"
+    And the output should not contain "Running: touch"
@@ -10,3 +10,78 @@ Feature: CLI usage and error handling
     When taidy is called with files that don't exist
     Then the exit code should be 0
     And the output should contain "no files were linted"
+
+  Scenario: Rule documentation is looked up from the bundled index
+    When `taidy explain-rule E501 SC2086` is run
+    Then the exit code should be 0
+    And the output should contain "https://docs.astral.sh/ruff/rules/line-too-long/"
+    And the output should contain "https://www.shellcheck.net/wiki/SC2086"
+
+  Scenario: Finding no supported files can be treated as an error
+    When `taidy --error-on-empty lint nonexistent.py` is run
+    Then the exit code should be 3
+    And the output should contain "no files were linted"
+
+  Scenario: A directory named like a command is processed as a path
+    Given the file "tools/build.py" contains:
+      """
+      x = 1
+      """
+    When `taidy tools` is run
+    Then the output should contain "Discovered 1 supported files in tools"
+    And the output should not contain "chosen"
+
+  Scenario: Shards split the files between them, each file in exactly one
+    Given the following has been run:
+      """
+      for name in a b c d e f; do echo "x = 1" > $name.py; done
+      """
+    When `taidy lint --dry-run --shard 1/2 a.py b.py c.py d.py e.py f.py; python3 -m taidy lint --dry-run --shard 2/2 a.py b.py c.py d.py e.py f.py` is run
+    Then the output should contain "-m taidy.syntax b.py d.py e.py f.py"
+    And the output should contain "-m taidy.syntax a.py c.py"
+
+  Scenario: A shard outside the number of shards is an error
+    When `taidy lint --shard 3/2 a.py` is run
+    Then the exit code should be 1
+    And the output should contain "Invalid shard 3/2"
+
+  Scenario: Files matched by .taidyignore are skipped, even when named
+    Given the file ".taidyignore" contains:
+      """
+      generated/
+      *_pb2.py
+      """
+    And the following has been run:
+      """
+      mkdir -p generated
+      echo "x = 1" > app.py
+      echo "x = 1" > api_pb2.py
+      echo "x = 1" > generated/models.py
+      """
+    When `taidy lint --dry-run app.py api_pb2.py generated/models.py` is run
+    Then the output should contain "-m taidy.syntax app.py"
+    And the output should not contain "api_pb2.py"
+    And the output should not contain "models.py"
+
+  Scenario: Files over --max-file-size are skipped
+    Given the following has been run:
+      """
+      python3 -c "open('big.py', 'w').write('x = 1\n' * 1000)"
+      echo "x = 1" > small.py
+      """
+    When `taidy lint --max-file-size 1k big.py small.py` is run
+    Then the output should contain "Skipping big.py (5.9 kB), over the 1.0 kB --max-file-size"
+    And the output should contain "-m taidy.syntax small.py"
+    And the output should not contain "-m taidy.syntax big.py"
+
+  Scenario: The config sets a default --max-file-size
+    Given the file ".taidy.json" contains:
+      """
+      {"max_file_size": "2k"}
+      """
+    And the following has been run:
+      """
+      python3 -c "open('big.py', 'w').write('x = 1\n' * 1000)"
+      """
+    When `taidy lint big.py` is run
+    Then the output should contain "over the 2.0 kB --max-file-size"
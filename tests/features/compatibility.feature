@@ -0,0 +1,87 @@
+@compatibility
+Feature: Compatibility with the oldest and newest supported tool versions
+  taidy builds each tool's command line itself, so an upstream release that renames or
+  drops a flag breaks taidy without any change to taidy. These scenarios run taidy's
+  invocation templates against the oldest version of each tool it supports and against
+  the latest release.
+
+  Scenario Outline: ruff <version> accepts taidy's lint and format commands
+    Given the Python file "unused_import.py" exists
+    And the Python file "poorly_formatted.py" exists
+    When version <version> of ruff is installed
+    And `taidy lint unused_import.py` is run
+    Then the output should contain "F401"
+    And no tool rejected taidy's arguments
+    When `taidy format poorly_formatted.py` is run
+    Then the exit code should be 0
+    And no tool rejected taidy's arguments
+    When `taidy lint --stdin --stdin-filename unused_import.py < unused_import.py` is run
+    Then the output should contain "F401"
+    When `taidy format --stdin --stdin-filename poorly_formatted.py < poorly_formatted.py` is run
+    Then the exit code should be 0
+    And no tool rejected taidy's arguments
+
+    Examples:
+      | version |
+      | oldest  |
+      | latest  |
+
+  Scenario Outline: black <version> accepts taidy's lint and format commands
+    Given the Python file "poorly_formatted.py" exists
+    When version <version> of black is installed
+    And `taidy lint poorly_formatted.py` is run
+    Then the output should contain "Running: black --check"
+    And no tool rejected taidy's arguments
+    When `taidy format poorly_formatted.py` is run
+    Then the exit code should be 0
+    And no tool rejected taidy's arguments
+    When `taidy format --stdin --stdin-filename poorly_formatted.py < poorly_formatted.py` is run
+    Then the exit code should be 0
+    And no tool rejected taidy's arguments
+
+    Examples:
+      | version |
+      | oldest  |
+      | latest  |
+
+  Scenario Outline: prettier <version> accepts taidy's lint and format commands
+    Given the markdown file "poorly_formatted.md" exists
+    When version <version> of prettier is installed
+    And `taidy lint poorly_formatted.md` is run
+    Then the output should contain "Running: prettier"
+    And no tool rejected taidy's arguments
+    When `taidy format poorly_formatted.md` is run
+    Then the exit code should be 0
+    And no tool rejected taidy's arguments
+
+    Examples:
+      | version |
+      | oldest  |
+      | latest  |
+
+  Scenario Outline: shellcheck <version> accepts taidy's lint command
+    Given the shell file "poorly_formatted.sh" exists
+    When version <version> of shellcheck is installed
+    And `taidy lint poorly_formatted.sh` is run
+    Then the output should contain "Running: shellcheck -S warning"
+    And no tool rejected taidy's arguments
+
+    Examples:
+      | version |
+      | oldest  |
+      | latest  |
+
+  Scenario Outline: shfmt <version> accepts taidy's format commands
+    Given the shell file "poorly_formatted.sh" exists
+    When version <version> of shfmt is installed
+    And `taidy format poorly_formatted.sh` is run
+    Then the exit code should be 0
+    And no tool rejected taidy's arguments
+    When `taidy format --stdin --stdin-filename poorly_formatted.sh < poorly_formatted.sh` is run
+    Then the exit code should be 0
+    And no tool rejected taidy's arguments
+
+    Examples:
+      | version |
+      | oldest  |
+      | latest  |
@@ -0,0 +1,48 @@
+Feature: Merge conflict markers
+
+  Scenario: Conflict markers fail the run, whatever the language
+    Given the file "app.py" contains:
+      """
+      def greet():
+      <<<<<<< HEAD
+          return "hello"
+      =======
+          return "hi"
+      >>>>>>> topic
+      """
+    When `taidy lint app.py` is run
+    Then the output should contain "app.py:2:1: merge conflict marker <<<<<<<"
+    And the output should contain "app.py:4:1: merge conflict marker ======="
+    And the output should contain "app.py:6:1: merge conflict marker >>>>>>>"
+    And the exit code should be 1
+
+  Scenario: Files no tool handles are still checked
+    Given the file "notes.txt" contains:
+      """
+      <<<<<<< HEAD
+      ours
+      >>>>>>> topic
+      """
+    When `taidy lint notes.txt` is run
+    Then the output should contain "notes.txt:1:1: merge conflict marker <<<<<<<"
+    And the exit code should be 1
+
+  Scenario: A Markdown heading underline isn't a conflict marker
+    Given the file "README.md" contains:
+      """
+      Title
+      =======
+
+      Some text
+      """
+    When `taidy lint README.md` is run
+    Then the output should not contain "merge conflict marker"
+    And the exit code should be 0
+
+  Scenario: The check can be turned off
+    Given the file "notes.txt" contains:
+      """
+      <<<<<<< HEAD
+      """
+    When `taidy lint --skip-tool taidy.conflicts notes.txt` is run
+    Then the output should not contain "merge conflict marker"
@@ -0,0 +1,19 @@
+Feature: The resident daemon and its client
+
+  Scenario: A client's requests get answers from a running daemon
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy daemon --socket d.sock & sleep 2; python3 -m taidy client --socket d.sock capabilities | grep protocol_version; python3 -m taidy client --socket d.sock lint '{"files": ["unused_import.py"]}' | grep '"rule"'; python3 -m taidy client --socket d.sock shutdown` is run
+    Then the output should contain "Listening on d.sock"
+    And the output should contain "protocol_version"
+    And the output should contain "F401"
+
+  Scenario: A client is told when the daemon doesn't know a method
+    When `taidy daemon --socket d.sock & sleep 2; python3 -m taidy client --socket d.sock fix; echo "exit $?"; python3 -m taidy client --socket d.sock shutdown` is run
+    Then the output should contain "Unknown method: fix (-32601)"
+    And the output should contain "exit 1"
+
+  Scenario: A client is told when no daemon is running
+    When `taidy client --socket missing.sock capabilities` is run
+    Then the output should contain "no daemon answering on missing.sock"
+    And the exit code should be 1
@@ -0,0 +1,34 @@
+Feature: Findings parsed from each tool's output
+
+  Scenario: ruff's JSON output is parsed
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy lint --output plain-verbose unused_import.py` is run
+    Then the output should contain "output-format json"
+    And the output should contain "FINDING ERROR unused_import.py line 1 column 8: F401"
+    And the output should contain "imported but unused, from ruff"
+    And the output should contain "FILE FAIL unused_import.py, 1 finding"
+
+  Scenario: shellcheck's JSON output is parsed with its severities
+    Given the file "quoting.sh" contains:
+      """
+      #!/bin/sh
+      echo $1
+      """
+    When shellcheck is installed
+    And `taidy lint --output plain-verbose quoting.sh` is run
+    Then the output should contain "--format json1"
+    And the output should contain "FINDING INFO quoting.sh line 2 column 6: SC2086"
+    And the output should contain "from shellcheck"
+
+  Scenario: Files gofmt lists as unformatted are findings
+    Given the file "main.go" contains:
+      """
+      package main
+      func main(){}
+      """
+    When gofmt is installed
+    And `taidy lint --output plain-verbose main.go` is run
+    Then the output should contain "FINDING ERROR main.go line 1: File is not formatted with gofmt, from gofmt"
+    And the output should contain "FILE FAIL main.go, 1 finding"
+    And the exit code should be 1
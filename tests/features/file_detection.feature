@@ -0,0 +1,62 @@
+Feature: Detecting the language of files without a useful extension
+
+  Scenario: Well-known file names go to their languages' chains
+    Given the following has been run:
+      """
+      printf 'FROM alpine\n' > Dockerfile
+      printf 'all:\n\techo hi\n' > Makefile
+      printf "source 'https://rubygems.org'\n" > Gemfile
+      printf 'task :default\n' > Rakefile
+      printf 'cc_library(name = "app")\n' > BUILD
+      printf 'pipeline {\n}\n' > Jenkinsfile
+      """
+    When `taidy lint Dockerfile Makefile Gemfile Rakefile BUILD Jenkinsfile` is run
+    Then the output should match the pattern "dockerfile +1 "
+    And the output should match the pattern "makefile +1 "
+    And the output should match the pattern "ruby +2 "
+    And the output should match the pattern "bazel +1 "
+    And the output should match the pattern "jenkinsfile +1 "
+    And the output should not contain "No linter configured"
+
+  Scenario: A #! line picks the language of an extensionless script
+    Given the file "bin/deploy" contains:
+      """
+      #!/usr/bin/env bash
+      greeting=hello
+      """
+    When shellcheck is installed
+    And `taidy lint bin/deploy` is run
+    Then the output should contain "Running: shellcheck"
+    And the output should contain "SC2034"
+
+  Scenario: A python3 #! line sends a script to the Python chain
+    Given the file "scripts/migrate" contains:
+      """
+      #!/usr/bin/env python3
+      def migrate(:
+          pass
+      """
+    When `taidy lint scripts/migrate` is run
+    Then the output should contain "scripts/migrate:2:"
+    And the output should contain "E999"
+    And the exit code should be 1
+
+  Scenario: A Vim modeline picks the language when nothing else does
+    Given the file "settings" contains:
+      """
+      # vim: ft=python
+      def settings(:
+          pass
+      """
+    When `taidy lint settings` is run
+    Then the output should contain "settings:2:"
+    And the output should contain "E999"
+
+  Scenario: An Emacs mode line picks the language when nothing else does
+    Given the file "runme" contains:
+      """
+      # -*- mode: sh -*-
+      echo hi
+      """
+    When `taidy lint runme` is run
+    Then the output should match the pattern "shell +1 "
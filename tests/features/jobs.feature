@@ -0,0 +1,38 @@
+Feature: Limiting how many tools run at once
+
+  Background:
+    Given the file "app.ts" contains:
+      """
+      let greeting = "hello";
+      """
+    And the file "busy.sh" contains:
+      """
+      #!/bin/sh
+      # Takes a lock for a second, saying so when another run already holds it
+      mkdir busy.lock 2>/dev/null || echo "$1 overlapped another run"
+      sleep 1
+      rmdir busy.lock 2>/dev/null
+      exit 0
+      """
+    And the file ".taidy.json" contains:
+      """
+      {"tools": {"first": {"command": "sh busy.sh first", "files": ["*.ts"]}, "second": {"command": "sh busy.sh second", "files": ["*.ts"]}}}
+      """
+
+  Scenario: Tools over the same file type run together by default
+    When `taidy lint -j 2 app.ts` is run
+    Then the output should contain "overlapped another run"
+
+  Scenario: A file type's limit runs its tools one at a time
+    When `taidy lint -j 2,.ts=1 app.ts` is run
+    Then the output should contain "Running: sh busy.sh first app.ts"
+    And the output should contain "Running: sh busy.sh second app.ts"
+    And the output should not contain "overlapped another run"
+
+  Scenario: A language's limit applies to its file types
+    When `taidy lint -j 2,typescript=1 app.ts` is run
+    Then the output should not contain "overlapped another run"
+
+  Scenario: Another file type's limit doesn't hold these tools back
+    When `taidy lint -j 2,.py=1 app.ts` is run
+    Then the output should contain "overlapped another run"
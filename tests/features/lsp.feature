@@ -0,0 +1,46 @@
+Feature: The Language Server Protocol server
+
+  Background:
+    Given the file "session.py" contains:
+      """
+      # Writes an editor session's messages, each framed with its Content-Length
+      import json
+      import sys
+
+      document = {"uri": "file:///tmp/broken.py", "languageId": "python", "version": 1}
+      messages = [
+          {"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {}},
+          {"jsonrpc": "2.0", "method": "initialized", "params": {}},
+          {
+              "jsonrpc": "2.0",
+              "method": "textDocument/didOpen",
+              "params": {"textDocument": dict(document, text=sys.argv[1] + "\n")},
+          },
+          {"jsonrpc": "2.0", "id": 2, "method": "shutdown"},
+          {"jsonrpc": "2.0", "method": "exit"},
+      ]
+      for message in messages:
+          body = json.dumps(message).encode()
+          sys.stdout.buffer.write(b"Content-Length: %d\r\n\r\n" % len(body) + body)
+      """
+
+  Scenario: Findings in an opened document are published
+    Given the following has been run:
+      """
+      python3 session.py "def greet(:" > session.txt
+      """
+    When `taidy lsp < session.txt` is run
+    Then the output should contain "documentFormattingProvider"
+    And the output should contain "textDocument/publishDiagnostics"
+    And the output should match the pattern ".source.: .taidy\.syntax."
+    And the output should match the pattern ".id.: 2, .result.: null"
+    And the exit code should be 0
+
+  Scenario: A clean document is published with no findings
+    Given the following has been run:
+      """
+      python3 session.py "greeting = 1" > session.txt
+      """
+    When `taidy lsp < session.txt` is run
+    Then the output should match the pattern ".diagnostics.: \[\]"
+    And the exit code should be 0
@@ -0,0 +1,20 @@
+Feature: Preferring the fastest of equally capable tools
+
+  Scenario: A slower tool first in the chain gives way once the others are timed
+    Given the file "app.py" contains:
+      """
+      greeting = "hello"
+      """
+    And the following has been run:
+      """
+      mkdir -p /tmp/bin
+      printf '#!/bin/sh\nsleep 1\n' > /tmp/bin/ruff
+      printf '#!/bin/sh\n' > /tmp/bin/flake8
+      chmod +x /tmp/bin/ruff /tmp/bin/flake8
+      """
+    And the environment variable PATH is "/tmp/bin:/usr/local/bin:/usr/bin:/bin"
+    When `taidy lint --prefer-fast app.py; PATH=/tmp/bin:$PATH python3 -m taidy lint --prefer-fast app.py; PATH=/tmp/bin:$PATH python3 -m taidy lint --prefer-fast --dry-run app.py | sed 's/^/next: /'` is run
+    Then the output should contain "Running: ruff check --quiet -- app.py"
+    And the output should contain "Running: flake8 --quiet app.py"
+    And the output should contain "next: flake8 --quiet app.py"
+    And the output should not contain "next: ruff"
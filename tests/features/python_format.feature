@@ -25,3 +25,9 @@ Feature: Formatting Python files
     Then those files get formatted
     But no lint output is emitted
 
+  Scenario: Only imports are organized with taidy imports
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy imports unused_import.py` is run
+    Then the output should contain "Running: ruff check --select I --fix"
+    And the output should not contain "ruff format"
@@ -23,3 +23,99 @@ Feature: Linting Python files
     And `taidy lint poorly_formatted.py` is run
     Then lint output is emitted
     And no formatting happens
+
+  Scenario: Lint findings are shown with their source lines
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy lint --show-context unused_import.py` is run
+    Then the output should contain "unused_import.py:1:8: F401"
+    And the output should contain "1 | import os"
+    And the output should contain "[ruff]"
+
+  Scenario: Content from stdin is linted as the named file
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy lint --stdin --stdin-filename buffer.py < unused_import.py` is run
+    Then the output should contain "buffer.py:1:8: F401"
+    And the exit code should be 1
+
+  Scenario: Only taidy's own checks run with --builtin-only
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy lint --builtin-only unused_import.py` is run
+    Then the output should contain "-m taidy.syntax unused_import.py"
+    And the output should contain "-m taidy.conflicts unused_import.py"
+    And the output should not contain "ruff check"
+    And the output should not contain "F401"
+    And the exit code should be 0
+
+  Scenario: The strict preset adds ruff rules
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy lint --preset strict --dry-run unused_import.py` is run
+    Then the output should contain "ruff check --quiet --extend-select B,UP,SIM,C4,RET -- unused_import.py"
+
+  Scenario: A preset can be chosen in the config
+    Given the Python file "unused_import.py" exists
+    And the file ".taidy.json" contains:
+      """
+      {"preset": "python-ds"}
+      """
+    When ruff is installed
+    And `taidy lint --dry-run unused_import.py` is run
+    Then the output should contain "ruff check --quiet --extend-ignore E402,E501 -- unused_import.py"
+
+  Scenario: An unknown preset is an error
+    Given the Python file "unused_import.py" exists
+    When `taidy lint --preset nope unused_import.py` is run
+    Then the exit code should be 1
+    And the output should contain "Unknown preset: nope (known presets: minimal, standard, strict, frontend, python-ds)"
+
+  Scenario: Findings within a tool's thresholds pass
+    Given the Python file "unused_import.py" exists
+    And the file ".taidy.json" contains:
+      """
+      {"thresholds": {"ruff": {"max_findings": 1}}}
+      """
+    When ruff is installed
+    And `taidy lint --show-context unused_import.py` is run
+    Then the output should contain "ruff: 1 findings, within its thresholds"
+    And the exit code should be 0
+
+  Scenario: Findings over a tool's thresholds fail
+    Given the Python file "unused_import.py" exists
+    And the file ".taidy.json" contains:
+      """
+      {"thresholds": {"ruff": {"max_findings": 0}}}
+      """
+    When ruff is installed
+    And `taidy lint --show-context unused_import.py` is run
+    Then the output should contain "ruff: 1 findings, over the limit of 0"
+    And the exit code should be 1
+
+  Scenario: A tool whose conditions fail gives way to the next in its chain
+    Given the file ".taidy.json" contains:
+      """
+      {"when": {"ruff": ["files < 2"]}}
+      """
+    And the following has been run:
+      """
+      echo "x = 1" > a.py
+      echo "x = 1" > b.py
+      """
+    When ruff is installed
+    And `taidy lint --dry-run a.py b.py; python3 -m taidy lint --dry-run a.py` is run
+    Then the output should contain "python -m py_compile a.py b.py"
+    And the output should contain "ruff check --quiet -- a.py"
+    And the output should not contain "ruff check --quiet -- a.py b.py"
+
+  Scenario: A malformed condition is reported and ignored
+    Given the Python file "unused_import.py" exists
+    And the file ".taidy.json" contains:
+      """
+      {"when": {"ruff": ["files < many"]}}
+      """
+    When ruff is installed
+    And `taidy lint --dry-run unused_import.py` is run
+    Then the output should contain "expected a whole number of files"
+    And the output should contain "ruff check --quiet -- unused_import.py"
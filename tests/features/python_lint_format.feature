@@ -23,3 +23,19 @@ Feature: Linting and formatting Python files
     And `taidy poorly_formatted.py` is run
     Then those files get formatted
     But no lint output is emitted
+
+  Scenario: Config keyed on file names applies to files being formatted
+    Given the file "pyproject.toml" contains:
+      """
+      [tool.ruff.lint.per-file-ignores]
+      "__init__.py" = ["F401"]
+      """
+    And the file "pkg/__init__.py" contains:
+      """
+      import os
+      """
+    When ruff is installed
+    And `taidy pkg/__init__.py` is run
+    Then the output should contain "ruff check"
+    And the output should not contain "F401"
+    And the output should not contain ".taidy-"
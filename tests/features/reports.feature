@@ -0,0 +1,24 @@
+Feature: Machine-readable reports
+
+  Scenario: Findings are reported as SARIF 2.1.0
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy lint --output sarif unused_import.py` is run
+    Then the output should match the pattern ".version.: .2\.1\.0."
+    And the output should match the pattern ".\$schema.: .https://json\.schemastore\.org/sarif-2\.1\.0\.json."
+    And the output should match the pattern ".name.: .ruff."
+    And the output should match the pattern ".helpUri.: .https://docs\.astral\.sh/ruff/rules/unused-import/."
+    And the output should match the pattern ".ruleId.: .F401."
+    And the output should match the pattern ".uri.: .unused_import\.py.,\s+.uriBaseId.: .%SRCROOT%."
+    And the output should match the pattern ".startLine.: 1,\s+.startColumn.: 8"
+    And the exit code should be 1
+
+  Scenario: Each of taidy's own checks is a SARIF run named after the check
+    Given the file "conflicted.py" contains:
+      """
+      <<<<<<< HEAD
+      """
+    When ruff is installed
+    And `taidy lint --output sarif conflicted.py` is run
+    Then the output should match the pattern ".name.: .taidy\.conflicts."
+    And the output should match the pattern ".text.: .merge conflict marker <<<<<<<."
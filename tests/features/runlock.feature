@@ -0,0 +1,32 @@
+Feature: Recording runs and replaying them
+
+  Scenario: A run like the recorded one matches it
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy lint --record run.lock unused_import.py; python3 -m taidy lint --replay run.lock unused_import.py` is run
+    Then the output should contain "The run matches run.lock"
+    And the output should not contain "Differs from run.lock"
+
+  Scenario: A file changed since the recording is reported
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy lint --record run.lock unused_import.py; echo 'import sys' >> unused_import.py; python3 -m taidy lint --replay run.lock unused_import.py` is run
+    Then the output should contain "Differs from run.lock: unused_import.py has changed since it was recorded"
+
+  Scenario: A different tool version is reported
+    Given the Python file "unused_import.py" exists
+    When ruff is installed
+    And `taidy lint --record run.lock unused_import.py; python3 -c 'import json; lock = json.load(open("run.lock")); [tool.update(version="ruff 0.0.1") for tool in lock["tools"] if tool["tool"] == "ruff"]; json.dump(lock, open("run.lock", "w"))'; python3 -m taidy lint --replay run.lock unused_import.py` is run
+    Then the output should contain "Differs from run.lock: ruff check"
+    And the output should contain "was ruff 0.0.1"
+    And the output should not contain "has changed since it was recorded"
+
+  Scenario: The same input formatted differently is reported
+    Given the Python file "poorly_formatted.py" exists
+    And the following has been run:
+      """
+      cp poorly_formatted.py original.py
+      """
+    When ruff is installed
+    And `taidy format --record run.lock poorly_formatted.py; cp original.py poorly_formatted.py; python3 -c 'import json; lock = json.load(open("run.lock")); lock["files"]["poorly_formatted.py"]["output"] = "0"; json.dump(lock, open("run.lock", "w"))'; python3 -m taidy format --replay run.lock poorly_formatted.py` is run
+    Then the output should contain "Differs from run.lock: poorly_formatted.py came out differently from the same input"
@@ -41,4 +41,11 @@ Feature: Linting and formatting shell files
     When shellcheck is installed
     And `taidy poorly_formatted.zsh` is run
     Then those files get linted
-    And those files get formatted
\ No newline at end of file
+    And those files get formatted
+
+  Scenario: Clean tool runs are silent with --quiet-success
+    Given the shell file "poorly_formatted.sh" exists
+    When shfmt is installed
+    And `taidy format --quiet-success poorly_formatted.sh` is run
+    Then the output should not contain "Running:"
+    And the output should contain "All 1 tool runs passed"
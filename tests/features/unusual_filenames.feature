@@ -0,0 +1,27 @@
+Feature: Files with unusual names
+
+  Scenario: Names with spaces and leading dashes reach tools as files
+    Given the Python file "unused_import.py" is also saved as 'my file.py'
+    And the Python file "unused_import.py" is also saved as '-dash.py'
+    When ruff is installed
+    And `taidy lint -- "my file.py" -dash.py` is run
+    Then the output should contain "my file.py:1:8: F401"
+    And the output should contain "-dash.py:1:8: F401"
+    And the output should not contain "unexpected argument"
+    And the exit code should be 1
+
+  Scenario: Names with newlines and bytes that aren't UTF-8 are shown escaped
+    Given the Python file "unused_import.py" is also saved as 'new\nline.py'
+    And the Python file "unused_import.py" is also saved as 'caf\351.py'
+    When ruff is installed
+    And `taidy lint "$(printf 'new\nline.py')" "$(printf 'caf\351.py')"` is run
+    Then the output should contain "Running: ruff check"
+    And the output should contain "new\nline.py caf\xe9.py"
+    And the output should not contain "Traceback"
+
+  Scenario: JSON reports hold unusual names as valid strings
+    Given the Python file "unused_import.py" is also saved as 'new\nline.py'
+    And the Python file "unused_import.py" is also saved as 'caf\351.py'
+    When ruff is installed
+    And `taidy lint --output json "$(printf 'new\nline.py')" "$(printf 'caf\351.py')" | python3 -c 'import json, sys; print(*[f["path"] for f in json.load(sys.stdin)["files"]])'` is run
+    Then the output should contain "caf\xe9.py new\nline.py"
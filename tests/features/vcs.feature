@@ -0,0 +1,127 @@
+Feature: Staged and changed files from version control
+
+  Scenario: Staged files are found in a git repository set up with GIT_DIR
+    Given the file "work/staged.py" contains:
+      """
+      import os
+      """
+    And the file "work/unstaged.py" contains:
+      """
+      import os
+      """
+    And the following has been run:
+      """
+      git init -q --bare repo.git && git --git-dir=repo.git config core.bare false
+      git --git-dir=repo.git --work-tree=work add staged.py
+      """
+    And the environment variable GIT_DIR is "/tmp/repo.git"
+    And the environment variable GIT_WORK_TREE is "/tmp/work"
+    When git is installed
+    And `taidy lint --staged` is run
+    Then the output should contain "work/staged.py:1:8: F401"
+    And the output should not contain "unstaged.py"
+    And the output should not contain "only works inside"
+
+  Scenario: Files Mercurial would commit are read from hg status --print0
+    Given the file "committed.py" contains:
+      """
+      import os
+      """
+    And the following has been run:
+      """
+      hg init && hg add -q committed.py && hg commit -q -u taidy -m first
+      for name in "$(printf 'new\nline.py')" 'with space.py' untracked.py; do
+        printf 'import os\n' > "$name"
+      done
+      hg add -q "$(printf 'new\nline.py')" 'with space.py'
+      """
+    When hg is installed
+    And `taidy lint --staged .` is run
+    Then the output should contain "with space.py:1:8: F401"
+    And the output should contain "new\nline.py"
+    And the output should not contain "untracked.py"
+    And the output should not contain "committed.py"
+
+  Scenario: Files Jujutsu would commit are read from jj diff --name-only
+    Given the file "changed.py" contains:
+      """
+      import os
+      """
+    And the file "sub dir/spaced.py" contains:
+      """
+      import os
+      """
+    And the file "untouched.py" contains:
+      """
+      import os
+      """
+    And the file "jj" contains:
+      """
+      #!/bin/sh
+      # Answers as jj does for a working copy changing two files, one since main
+      case "$*" in
+        "diff --name-only") printf 'changed.py\nsub dir/spaced.py\n' ;;
+        "diff --name-only --from heads(::(main) & ::@)") printf 'changed.py\n' ;;
+        *) exit 1 ;;
+      esac
+      """
+    And the following has been run:
+      """
+      mkdir .jj && install -m 755 jj /usr/local/bin/jj
+      """
+    When ruff is installed
+    And `taidy lint --staged .` is run
+    Then the output should contain "changed.py:1:8: F401"
+    And the output should contain "sub dir/spaced.py:1:8: F401"
+    And the output should not contain "untouched.py"
+
+  Scenario: Files changed since a revision are read from jj diff --from
+    Given the file "changed.py" contains:
+      """
+      import os
+      """
+    And the file "untouched.py" contains:
+      """
+      import os
+      """
+    And the file "jj" contains:
+      """
+      #!/bin/sh
+      case "$*" in
+        "diff --name-only --from heads(::(main) & ::@)") printf 'changed.py\n' ;;
+        *) exit 1 ;;
+      esac
+      """
+    And the following has been run:
+      """
+      mkdir .jj && install -m 755 jj /usr/local/bin/jj
+      """
+    When ruff is installed
+    And `taidy lint --since main .` is run
+    Then the output should contain "changed.py:1:8: F401"
+    And the output should not contain "untouched.py"
+
+  Scenario: install-hooks makes taidy the pre-commit hook, and uninstall removes it
+    Given the following has been run:
+      """
+      git init -q
+      """
+    When git is installed
+    And `taidy install-hooks; cat .git/hooks/pre-commit; python3 -m taidy install-hooks uninstall; test -e .git/hooks/pre-commit || echo "no hook left"` is run
+    Then the output should contain "Installed /tmp/.git/hooks/pre-commit"
+    And the output should contain "-m taidy --staged"
+    And the output should contain "Removed /tmp/.git/hooks/pre-commit"
+    And the output should contain "no hook left"
+
+  Scenario: install-hooks keeps an existing hook unless forced
+    Given the following has been run:
+      """
+      git init -q
+      printf '#!/bin/sh\necho existing hook\n' > .git/hooks/pre-commit
+      """
+    When git is installed
+    And `taidy install-hooks; echo "exit $?"; python3 -m taidy install-hooks --force; cat .git/hooks/pre-commit.taidy-backup` is run
+    Then the output should contain "already exists; use --force to replace it"
+    And the output should contain "exit 1"
+    And the output should contain "Moved the existing hook to /tmp/.git/hooks/pre-commit.taidy-backup"
+    And the output should contain "echo existing hook"
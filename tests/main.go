@@ -18,6 +18,10 @@ var opts = godog.Options{
 	Concurrency: 4, // run scenarios in parallel
 }
 
+// The compatibility matrix builds an image per tool version, so it only runs when asked
+// for, with -tags @compatibility
+var tags = flag.String("tags", "~@compatibility", "only run scenarios matching a tag expression")
+
 func init() {
 	godog.BindCommandLineFlags("", &opts)
 }
@@ -52,6 +56,7 @@ func main() {
 	if len(opts.Paths) == 0 {
 		opts.Paths = []string{"features"}
 	}
+	opts.Tags = *tags
 
 	status := godog.TestSuite{
 		Name:                 "lintair BDD tests",
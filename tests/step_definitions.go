@@ -6,6 +6,7 @@ import (
 	"log"
 	"os/exec"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -17,10 +18,28 @@ type TestContainerTestContext struct {
 	containerManager *TestContainerManager
 	currentContainer *TestContainerContext
 	testFiles        []string
+	fileCopies       []fileCopy
+	fileContents     []fileContent
+	setupScripts     []string
+	environment      []string // NAME=value settings taidy is run with
 	commandResult    *CommandResult
 	scenarioName     string
 	requiredLinters  []string // Linters that must be installed
 	forbiddenLinters []string // Linters that must NOT be installed
+	compatEnv        string   // A compatibility matrix environment, which takes precedence
+}
+
+// fileCopy is a sample file saved under another name, given as a printf format so that
+// names can hold newlines and bytes that aren't valid UTF-8
+type fileCopy struct {
+	source string
+	name   string
+}
+
+// fileContent is a file written out in a scenario, created before taidy runs
+type fileContent struct {
+	name    string
+	content string
 }
 
 // NewTestContainerTestContext creates a new test context using testcontainers
@@ -46,6 +65,10 @@ func (tctx *TestContainerTestContext) Close() error {
 
 // determineEnvironment selects the best environment based on required and forbidden linters
 func (tctx *TestContainerTestContext) determineEnvironment() string {
+	if tctx.compatEnv != "" {
+		return tctx.compatEnv
+	}
+
 	// Check for Python linters
 	hasRuff := contains(tctx.requiredLinters, "ruff")
 	hasBlack := contains(tctx.requiredLinters, "black")
@@ -62,11 +85,15 @@ func (tctx *TestContainerTestContext) determineEnvironment() string {
 	hasShfmt := contains(tctx.requiredLinters, "shfmt")
 	hasBeautysh := contains(tctx.requiredLinters, "beautysh")
 	hasTrufflehog := contains(tctx.requiredLinters, "trufflehog")
+	hasVcs := contains(tctx.requiredLinters, "git") || contains(tctx.requiredLinters, "hg")
 
 	// Python environment selection
 	if hasTrufflehog {
 		return "python311-trufflehog"
 	}
+	if hasVcs {
+		return "vcs"
+	}
 	if hasRuff && !forbidsRuff {
 		return "python311"
 	}
@@ -144,6 +171,31 @@ func (tctx *TestContainerTestContext) thePythonFileExists(filename string) error
 	return nil
 }
 
+func (tctx *TestContainerTestContext) thePythonFileIsAlsoSavedAs(filename, name string) error {
+	if !slices.Contains(tctx.testFiles, filename) {
+		tctx.testFiles = append(tctx.testFiles, filename)
+	}
+	tctx.fileCopies = append(tctx.fileCopies, fileCopy{source: filename, name: name})
+	return nil
+}
+
+func (tctx *TestContainerTestContext) theFileContains(name string, docString *godog.DocString) error {
+	tctx.fileContents = append(tctx.fileContents, fileContent{name: name, content: docString.Content + "\n"})
+	return nil
+}
+
+func (tctx *TestContainerTestContext) theEnvironmentVariableIs(name, value string) error {
+	tctx.environment = append(tctx.environment, fmt.Sprintf("%s=%s", name, value))
+	return nil
+}
+
+// theFollowingHasBeenRun registers a shell script that sets up the scenario, such as by
+// packing files into an archive, run in the container after its files are created
+func (tctx *TestContainerTestContext) theFollowingHasBeenRun(docString *godog.DocString) error {
+	tctx.setupScripts = append(tctx.setupScripts, docString.Content)
+	return nil
+}
+
 func (tctx *TestContainerTestContext) theShellFileExists(filename string) error {
 	// Store the filename for later - don't set up container yet
 	// This allows subsequent steps to determine the correct environment
@@ -240,6 +292,35 @@ func (tctx *TestContainerTestContext) linterIsInstalled(linter string) error {
 	return nil
 }
 
+// versionOfToolIsInstalled picks the compatibility matrix environment for one version of
+// a tool: "oldest", "latest", or a version to pin
+func (tctx *TestContainerTestContext) versionOfToolIsInstalled(version, tool string) error {
+	environment, err := compatEnvironment(tool, version)
+	if err != nil {
+		return err
+	}
+	tctx.compatEnv = environment
+	tctx.requiredLinters = append(tctx.requiredLinters, tool)
+	return nil
+}
+
+// noToolRejectedTaidysArguments checks for the errors tools give for flags they don't know,
+// as when an upstream release renames one taidy passes
+func (tctx *TestContainerTestContext) noToolRejectedTaidysArguments() error {
+	if tctx.commandResult == nil {
+		return fmt.Errorf("no command result available")
+	}
+
+	combinedOutput := tctx.commandResult.Stdout + tctx.commandResult.Stderr
+	for _, message := range argumentErrors {
+		if strings.Contains(combinedOutput, message) {
+			return fmt.Errorf("a tool rejected taidy's arguments (%q).\nActual output: %s",
+				message, combinedOutput)
+		}
+	}
+	return nil
+}
+
 func (tctx *TestContainerTestContext) linterIsNotInstalled(linter string) error {
 	// Track forbidden linters
 	tctx.forbiddenLinters = append(tctx.forbiddenLinters, linter)
@@ -839,6 +920,82 @@ func (tctx *TestContainerTestContext) taidyPoorlyFormattedmdIsRun() error {
 	return nil
 }
 
+// applyScenarioSetup saves sample files under other names, writes the scenario's own
+// files and runs its setup scripts, once the container exists. A step such as "ruff is
+// installed" may have started the container already, so this doesn't wait for a new one.
+func (tctx *TestContainerTestContext) applyScenarioSetup() error {
+	for _, fc := range tctx.fileCopies {
+		sourceFile := fmt.Sprintf("sample_files/%s", fc.source)
+		if err := tctx.currentContainer.CopyFileIntoContainer(sourceFile, fc.source); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", fc.source, err)
+		}
+		cmd := fmt.Sprintf(`cp /tmp/%s "/tmp/$(printf '%s')"`, fc.source, fc.name)
+		if result, err := tctx.currentContainer.ExecuteCommand(cmd); err != nil || result.ExitCode != 0 {
+			return fmt.Errorf("failed to save %s as %q: %v", fc.source, fc.name, err)
+		}
+	}
+
+	for _, fc := range tctx.fileContents {
+		cmd := fmt.Sprintf(`mkdir -p "$(dirname '/tmp/%s')"`, fc.name)
+		if _, err := tctx.currentContainer.ExecuteCommand(cmd); err != nil {
+			return fmt.Errorf("failed to create the directory of %s: %w", fc.name, err)
+		}
+		if err := tctx.currentContainer.CreateFile(fc.name, fc.content); err != nil {
+			return err
+		}
+	}
+
+	for _, script := range tctx.setupScripts {
+		result, err := tctx.currentContainer.ExecuteCommand("cd /tmp && " + script)
+		if err != nil {
+			return fmt.Errorf("failed to run setup: %w", err)
+		}
+		if result.ExitCode != 0 {
+			return fmt.Errorf("setup exited %d: %s", result.ExitCode, result.Stdout)
+		}
+	}
+
+	// Later runs in the same scenario find everything in place already
+	tctx.fileCopies = tctx.fileCopies[:0]
+	tctx.fileContents = tctx.fileContents[:0]
+	tctx.setupScripts = tctx.setupScripts[:0]
+	return nil
+}
+
+// taidyIsRun runs taidy with arbitrary arguments, copying any registered sample files first
+func (tctx *TestContainerTestContext) taidyIsRun(args string) error {
+	if tctx.currentContainer == nil {
+		// Set up container based on accumulated constraints
+		environment := tctx.determineEnvironment()
+		if err := tctx.SetupContainer(environment); err != nil {
+			return err
+		}
+
+		for _, filename := range tctx.testFiles {
+			sourceFile := fmt.Sprintf("sample_files/%s", filename)
+			if err := tctx.currentContainer.CopyFileIntoContainer(sourceFile, filename); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", filename, err)
+			}
+		}
+	}
+
+	if err := tctx.applyScenarioSetup(); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("python3 -m taidy %s", args)
+	if len(tctx.environment) > 0 {
+		cmd = fmt.Sprintf("env %s %s", strings.Join(tctx.environment, " "), cmd)
+	}
+	result, err := tctx.currentContainer.ExecuteCommand(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to execute taidy %s: %w", args, err)
+	}
+
+	tctx.commandResult = result
+	return nil
+}
+
 // Helper functions for executing commands on the host system
 func executeHostCommand(name string, args ...string) (string, error) {
 	cmd := exec.Command(name, args...)
@@ -1040,6 +1197,10 @@ func (tctx *TestContainerTestContext) InitializeScenario(ctx *godog.ScenarioCont
 	// File creation steps
 	ctx.Step(`^the following Python file exists:$`, tctx.theFollowingPythonFileExists)
 	ctx.Step(`^the Python file "([^"]*)" exists$`, tctx.thePythonFileExists)
+	ctx.Step(`^the Python file "([^"]*)" is also saved as '([^']*)'$`, tctx.thePythonFileIsAlsoSavedAs)
+	ctx.Step(`^the file "([^"]*)" contains:$`, tctx.theFileContains)
+	ctx.Step(`^the following has been run:$`, tctx.theFollowingHasBeenRun)
+	ctx.Step(`^the environment variable ([A-Z_]+) is "([^"]*)"$`, tctx.theEnvironmentVariableIs)
 	ctx.Step(`^the shell file "([^"]*)" exists$`, tctx.theShellFileExists)
 	ctx.Step(`^the markdown file "([^"]*)" exists$`, tctx.theMarkdownFileExists)
 	ctx.Step(`^the following JavaScript file exists:$`, tctx.theFollowingJavaScriptFileExists)
@@ -1047,6 +1208,7 @@ func (tctx *TestContainerTestContext) InitializeScenario(ctx *godog.ScenarioCont
 
 	// Linter verification steps
 	ctx.Step(`^([a-zA-Z0-9_-]+) is installed$`, tctx.linterIsInstalled)
+	ctx.Step(`^version ([a-zA-Z0-9_.-]+) of ([a-zA-Z0-9_-]+) is installed$`, tctx.versionOfToolIsInstalled)
 	ctx.Step(`^([a-zA-Z0-9_-]+) is not installed$`, tctx.linterIsNotInstalled)
 	ctx.Step(`^([a-zA-Z0-9_-]+) isn't installed$`, tctx.linterIsNotInstalled)
 	ctx.Step(`^And ([a-zA-Z0-9_-]+) isn't installed$`, tctx.linterIsNotInstalled)
@@ -1069,6 +1231,7 @@ func (tctx *TestContainerTestContext) InitializeScenario(ctx *godog.ScenarioCont
 	ctx.Step(`^the output should contain "([^"]*)"$`, tctx.theOutputShouldContain)
 	ctx.Step(`^the output should not contain "([^"]*)"$`, tctx.theOutputShouldNotContain)
 	ctx.Step(`^the output should match the pattern "([^"]*)"$`, tctx.theOutputShouldMatchThePattern)
+	ctx.Step(`^no tool rejected taidy's arguments$`, tctx.noToolRejectedTaidysArguments)
 	ctx.Step(`^the ([a-zA-Z0-9_-]+) command should be executed$`, tctx.theLinterCommandShouldBeExecuted)
 	ctx.Step(`^the ([a-zA-Z0-9_-]+) command should not be executed$`, tctx.theLinterCommandShouldNotBeExecuted)
 	ctx.Step(`^those files get linted$`, tctx.thoseFilesGetLinted)
@@ -1089,6 +1252,9 @@ func (tctx *TestContainerTestContext) InitializeScenario(ctx *godog.ScenarioCont
 	ctx.Step(`^`+"`"+`taidy lint poorly_formatted\.md`+"`"+` is run$`, tctx.taidyLintPoorlyFormattedmdIsRun)
 	ctx.Step(`^`+"`"+`taidy poorly_formatted\.md`+"`"+` is run$`, tctx.taidyPoorlyFormattedmdIsRun)
 
+	// Generic execution step; registered after the specific steps above so they take precedence
+	ctx.Step(`^`+"`"+`taidy ([^`+"`"+`]+)`+"`"+` is run$`, tctx.taidyIsRun)
+
 	// Security scanning steps
 	ctx.Step(`^`+"`"+`taidy lint with_secret\.py`+"`"+` is run$`, tctx.taidyLintWithSecretPyIsRun)
 	ctx.Step(`^`+"`"+`taidy lint \.`+"`"+` is run in the sample_files directory$`, tctx.taidyLintDotIsRunInTheSampleFilesDirectory)
@@ -1117,6 +1283,10 @@ func (tctx *TestContainerTestContext) InitializeScenario(ctx *godog.ScenarioCont
 			tctx.currentContainer = nil
 		}
 		tctx.testFiles = tctx.testFiles[:0] // Clear slice
+		tctx.fileCopies = tctx.fileCopies[:0]
+		tctx.fileContents = tctx.fileContents[:0]
+		tctx.setupScripts = tctx.setupScripts[:0]
+		tctx.environment = tctx.environment[:0]
 		tctx.commandResult = nil
 		tctx.requiredLinters = tctx.requiredLinters[:0]   // Clear slice
 		tctx.forbiddenLinters = tctx.forbiddenLinters[:0] // Clear slice
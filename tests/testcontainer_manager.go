@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/testcontainers/testcontainers-go"
@@ -155,6 +156,14 @@ WORKDIR /tmp`, nil
 RUN apk add --no-cache python3
 COPY taidy /app/taidy
 ENV PYTHONPATH=/app
+WORKDIR /tmp`, nil
+	case "vcs":
+		return `FROM python:3.11-slim
+RUN apt-get update && apt-get install -y git mercurial
+RUN pip install ruff
+RUN git config --global user.email taidy@example.com && git config --global user.name taidy
+COPY taidy /app/taidy
+ENV PYTHONPATH=/app
 WORKDIR /tmp`, nil
 	case "python311-trufflehog":
 		return `FROM python:3.11-slim
@@ -168,6 +177,9 @@ COPY taidy /app/taidy
 ENV PYTHONPATH=/app
 WORKDIR /tmp`, nil
 	default:
+		if strings.HasPrefix(environment, compatEnvironmentPrefix) {
+			return compatDockerfile(environment)
+		}
 		return "", fmt.Errorf("unknown environment: %s", environment)
 	}
 }